@@ -0,0 +1,27 @@
+(def ^:dynamic *scale* 1)
+(def scaled (fn (n) (* n *scale*)))
+
+(defprotocol Shape (area (this)))
+(defrecord Square [side])
+(extend-type Square Shape (area (this) (scaled (* (:side this) (:side this)))))
+
+(def counter (ref 0))
+(def bump (fn (n) (swap! counter (fn (v) (+ v (scaled n))))))
+
+(println (area (->Square 3)))
+(binding (*scale* 10)
+  (println (area (->Square 3)))
+  (println (bump 2))
+  (println (sort (list 3 1 2))))
+(println (bump 2))
+
+(def checked (fn (n) (if (< n 0) (err "negative") (ok n))))
+(def double (fn (n) (ok (* 2 (? (checked n))))))
+(println (double 4))
+(println (double -1))
+
+(let (m {:a 1 :b 2})
+  (do
+    (println (mget (assoc m :c 3) :c))
+    (println (dissoc m :a))))
+(println (conj [1 2] 3))
@@ -0,0 +1,16 @@
+; Calls in tail position must not grow the stack, built or interpreted
+
+(def down (fn (n) (if (= n 0) :done (down (+ n -1)))))
+(println (down 1000000))
+
+(def sum (fn (n acc) (if (= n 0) acc (let (m (+ n -1)) (sum m (+ acc n))))))
+(println (sum 100000 0))
+
+(def even (fn (n) (if (= n 0) true (odd (+ n -1)))))
+(def odd (fn (n) (if (= n 0) false (even (+ n -1)))))
+(println (even 300001))
+
+; pong is interpreted even when built, as eval builds it at run time
+(def ping (fn (n) (if (= n 0) :ping (do (+ 1 1) (pong (+ n -1))))))
+(def pong (eval (quot (fn (n) (if (= n 0) :pong (ping (+ n -1)))))))
+(println (ping 300001))
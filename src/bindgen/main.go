@@ -0,0 +1,327 @@
+// Generates the Go code binding a Go package as a smack namespace, see
+// interp.RegisterPackage. The exported funcs, constants and variables of the
+// package are read from its type information and registered under go. followed
+// by the import path with / replaced by ., for every exported struct type T a
+// new-T func returns a pointer to a new zero T. Generic funcs and types are left
+// out, as they can not be referred to without instantiating them, and so are funcs
+// taking or returning iterators, which scripts can not range over.
+//
+// Scripts pass converted copies of their collections, so a func changing a slice
+// or interface argument in place (one without results, like sort.Strings) would
+// have no effect. Those taking a single slice are bound to a func returning the
+// slice after the call, the others are left out.
+//
+// The bindings must build with the Go version the module declares, so members the
+// standard library added after it are left out too. They are read from the api
+// files of the toolchain, the version from the go directive of the nearest go.mod
+// unless given with -go.
+//
+//	go run ./src/bindgen -o strings.go strings
+//	go run ./src/bindgen -pkg main -ns json -o json.go encoding/json
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/build"
+	"go/constant"
+	"go/format"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const interp_path = "github.com/MatthewMcDade13/smack/src/interp"
+
+func main() {
+	output := flag.String("o", "", "File to write the bindings to, stdout when empty")
+	pkg_name := flag.String("pkg", "gostd", "Package clause of the generated file")
+	ns := flag.String("ns", "", "Namespace to register the package as, go.<import path> when empty")
+	go_version := flag.String("go", "", "Go version the bindings must build with, like 1.21, that of go.mod when empty")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: bindgen [-o file] [-pkg name] [-ns namespace] [-go version] importpath")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	path := flag.Arg(0)
+	if *ns == "" {
+		*ns = "go." + strings.ReplaceAll(path, "/", ".")
+	}
+	if *go_version == "" {
+		version, err := module_go_version()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		*go_version = version
+	}
+	newer, err := newer_api(path, *go_version)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code, err := generate(path, *pkg_name, *ns, newer)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *output == "" {
+		os.Stdout.Write(code)
+		return
+	}
+	if err := os.WriteFile(*output, code, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// The formatted source of a file in package pkg_name registering the package at
+// path as the namespace ns, without the members in skip
+func generate(path string, pkg_name string, ns string, skip map[string]bool) ([]byte, error) {
+	pkg, err := importer.ForCompiler(token.NewFileSet(), "source", nil).Import(path)
+	if err != nil {
+		return nil, fmt.Errorf("bindgen => Unable to load %s: %s", path, err)
+	}
+	name := pkg.Name()
+	if name == "interp" || name == pkg_name {
+		name = "go_" + name
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by bindgen from %s; DO NOT EDIT.\n\n", path)
+	fmt.Fprintf(&buf, "package %s\n\n", pkg_name)
+	fmt.Fprintf(&buf, "import (\n")
+	if name == pkg.Name() {
+		fmt.Fprintf(&buf, "%q\n\n", path)
+	} else {
+		fmt.Fprintf(&buf, "%s %q\n\n", name, path)
+	}
+	fmt.Fprintf(&buf, "%q\n)\n\n", interp_path)
+	fmt.Fprintf(&buf, "func init() {\n")
+	fmt.Fprintf(&buf, "interp.RegisterPackage(%q, map[string]any{\n", ns)
+	for _, member := range pkg.Scope().Names() {
+		if skip[member] {
+			continue
+		}
+		if expr := binding(pkg.Scope().Lookup(member), name); expr != "" {
+			fmt.Fprintf(&buf, "%s,\n", expr)
+		}
+	}
+	fmt.Fprintf(&buf, "})\n}\n")
+
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("bindgen => Generated invalid code for %s: %s", path, err)
+	}
+	return code, nil
+}
+
+// The "name": value entry binding obj of the package imported as pkg, empty when
+// it can not be bound
+func binding(obj types.Object, pkg string) string {
+	if !obj.Exported() {
+		return ""
+	}
+	ref := pkg + "." + obj.Name()
+	switch obj := obj.(type) {
+	case *types.Func:
+		sig := obj.Type().(*types.Signature)
+		if sig.TypeParams().Len() > 0 || uses_iterators(sig.Params()) || uses_iterators(sig.Results()) {
+			return ""
+		}
+		if sig.Results().Len() == 0 && len(in_place_params(sig)) > 0 {
+			return in_place_binding(obj, sig, pkg)
+		}
+		return fmt.Sprintf("%q: %s", obj.Name(), ref)
+
+	case *types.Var:
+		return fmt.Sprintf("%q: %s", obj.Name(), ref)
+
+	case *types.Const:
+		basic, ok := obj.Type().(*types.Basic)
+		if !ok || basic.Info()&types.IsUntyped == 0 {
+			return fmt.Sprintf("%q: %s", obj.Name(), ref)
+		}
+		// untyped constants take the type they would default to, which may not hold them
+		switch obj.Val().Kind() {
+		case constant.Int:
+			if _, exact := constant.Int64Val(obj.Val()); exact {
+				return fmt.Sprintf("%q: int64(%s)", obj.Name(), ref)
+			}
+			return fmt.Sprintf("%q: float64(%s)", obj.Name(), ref)
+		case constant.Float:
+			return fmt.Sprintf("%q: float64(%s)", obj.Name(), ref)
+		case constant.String, constant.Bool:
+			return fmt.Sprintf("%q: %s", obj.Name(), ref)
+		default:
+			return ""
+		}
+
+	case *types.TypeName:
+		named, ok := obj.Type().(*types.Named)
+		if !ok || obj.IsAlias() || named.TypeParams().Len() > 0 {
+			return ""
+		}
+		if _, ok := named.Underlying().(*types.Struct); !ok {
+			return ""
+		}
+		return fmt.Sprintf("%q: func() *%s { return new(%s) }", "new-"+obj.Name(), ref, ref)
+
+	default:
+		return ""
+	}
+}
+
+// Indexes of the params of sig a func could change in place, slices and interfaces.
+// Variadic args are a fresh slice on every call, so the last param of a variadic
+// func is not one
+func in_place_params(sig *types.Signature) []int {
+	indexes := make([]int, 0)
+	for i := 0; i < sig.Params().Len(); i++ {
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			break
+		}
+		switch sig.Params().At(i).Type().Underlying().(type) {
+		case *types.Slice, *types.Interface:
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// The entry binding the func fn without results that changes its only slice param
+// in place to a func returning that slice, empty when fn takes anything else it
+// could change or types of other packages
+func in_place_binding(fn *types.Func, sig *types.Signature, pkg string) string {
+	indexes := in_place_params(sig)
+	if len(indexes) != 1 {
+		return ""
+	}
+	slice := indexes[0]
+	if _, ok := sig.Params().At(slice).Type().Underlying().(*types.Slice); !ok {
+		return ""
+	}
+	foreign := false
+	qualifier := func(p *types.Package) string {
+		if p != fn.Pkg() {
+			foreign = true
+		}
+		return pkg
+	}
+	params := make([]string, sig.Params().Len())
+	args := make([]string, sig.Params().Len())
+	for i := range params {
+		args[i] = fmt.Sprintf("p%d", i)
+		t := sig.Params().At(i).Type()
+		if sig.Variadic() && i == len(params)-1 {
+			params[i] = args[i] + " ..." + types.TypeString(t.(*types.Slice).Elem(), qualifier)
+			args[i] += "..."
+		} else {
+			params[i] = args[i] + " " + types.TypeString(t, qualifier)
+		}
+	}
+	if foreign {
+		return ""
+	}
+	result := types.TypeString(sig.Params().At(slice).Type(), qualifier)
+	return fmt.Sprintf("%q: func(%s) %s {\n%s.%s(%s)\nreturn %s\n}", fn.Name(), strings.Join(params, ", "), result, pkg, fn.Name(), strings.Join(args, ", "), args[slice])
+}
+
+// Whether any of vars is an iter.Seq or iter.Seq2
+func uses_iterators(vars *types.Tuple) bool {
+	for i := 0; i < vars.Len(); i++ {
+		if named, ok := vars.At(i).Type().(*types.Named); ok {
+			if obj := named.Obj(); obj.Pkg() != nil && obj.Pkg().Path() == "iter" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// The version of the go directive of the go.mod in the working directory or the
+// nearest one above it
+func module_go_version() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if data, err := os.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if version, ok := strings.CutPrefix(strings.TrimSpace(line), "go "); ok {
+					return strings.TrimSpace(version), nil
+				}
+			}
+			return "", fmt.Errorf("bindgen => No go directive in %s", filepath.Join(dir, "go.mod"))
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("bindgen => No go.mod found, pass the Go version with -go")
+		}
+		dir = parent
+	}
+}
+
+// The minor version of a Go version like 1.21.6 or go1.21
+func go_minor(version string) (int, error) {
+	parts := strings.Split(strings.TrimPrefix(version, "go"), ".")
+	if len(parts) < 2 || parts[0] != "1" {
+		return 0, fmt.Errorf("bindgen => Invalid Go version: %s", version)
+	}
+	return strconv.Atoi(parts[1])
+}
+
+// Names of the members of the package at path added to the standard library after
+// the Go version, read from the api files of the toolchain like api/go1.22.txt:
+//
+//	pkg strings, func CutLast(string, string) (string, string, bool) #71151
+func newer_api(path string, version string) (map[string]bool, error) {
+	minor, err := go_minor(version)
+	if err != nil {
+		return nil, err
+	}
+	newer := make(map[string]bool)
+	for release := minor + 1; ; release++ {
+		f, err := os.Open(filepath.Join(build.Default.GOROOT, "api", fmt.Sprintf("go1.%d.txt", release)))
+		if os.IsNotExist(err) {
+			return newer, nil
+		} else if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			pkg, decl, ok := strings.Cut(strings.TrimPrefix(scanner.Text(), "pkg "), ", ")
+			// pkg syscall (linux-386), ...
+			if !ok || strings.Fields(pkg)[0] != path {
+				continue
+			}
+			kind, rest, _ := strings.Cut(decl, " ")
+			switch kind {
+			case "func", "const", "var", "type":
+				if name := strings.FieldsFunc(rest, is_name_end); len(name) > 0 {
+					newer[name[0]] = true
+				}
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func is_name_end(r rune) bool {
+	return r == '(' || r == ' ' || r == '['
+}
@@ -0,0 +1,73 @@
+// Code generated by bindgen from bytes; DO NOT EDIT.
+
+package gostd
+
+import (
+	"bytes"
+
+	"github.com/MatthewMcDade13/smack/src/interp"
+)
+
+func init() {
+	interp.RegisterPackage("go.bytes", map[string]any{
+		"new-Buffer":      func() *bytes.Buffer { return new(bytes.Buffer) },
+		"Clone":           bytes.Clone,
+		"Compare":         bytes.Compare,
+		"Contains":        bytes.Contains,
+		"ContainsAny":     bytes.ContainsAny,
+		"ContainsFunc":    bytes.ContainsFunc,
+		"ContainsRune":    bytes.ContainsRune,
+		"Count":           bytes.Count,
+		"Cut":             bytes.Cut,
+		"CutPrefix":       bytes.CutPrefix,
+		"CutSuffix":       bytes.CutSuffix,
+		"Equal":           bytes.Equal,
+		"EqualFold":       bytes.EqualFold,
+		"ErrTooLarge":     bytes.ErrTooLarge,
+		"Fields":          bytes.Fields,
+		"FieldsFunc":      bytes.FieldsFunc,
+		"HasPrefix":       bytes.HasPrefix,
+		"HasSuffix":       bytes.HasSuffix,
+		"Index":           bytes.Index,
+		"IndexAny":        bytes.IndexAny,
+		"IndexByte":       bytes.IndexByte,
+		"IndexFunc":       bytes.IndexFunc,
+		"IndexRune":       bytes.IndexRune,
+		"Join":            bytes.Join,
+		"LastIndex":       bytes.LastIndex,
+		"LastIndexAny":    bytes.LastIndexAny,
+		"LastIndexByte":   bytes.LastIndexByte,
+		"LastIndexFunc":   bytes.LastIndexFunc,
+		"Map":             bytes.Map,
+		"MinRead":         int64(bytes.MinRead),
+		"NewBuffer":       bytes.NewBuffer,
+		"NewBufferString": bytes.NewBufferString,
+		"NewReader":       bytes.NewReader,
+		"new-Reader":      func() *bytes.Reader { return new(bytes.Reader) },
+		"Repeat":          bytes.Repeat,
+		"Replace":         bytes.Replace,
+		"ReplaceAll":      bytes.ReplaceAll,
+		"Runes":           bytes.Runes,
+		"Split":           bytes.Split,
+		"SplitAfter":      bytes.SplitAfter,
+		"SplitAfterN":     bytes.SplitAfterN,
+		"SplitN":          bytes.SplitN,
+		"Title":           bytes.Title,
+		"ToLower":         bytes.ToLower,
+		"ToLowerSpecial":  bytes.ToLowerSpecial,
+		"ToTitle":         bytes.ToTitle,
+		"ToTitleSpecial":  bytes.ToTitleSpecial,
+		"ToUpper":         bytes.ToUpper,
+		"ToUpperSpecial":  bytes.ToUpperSpecial,
+		"ToValidUTF8":     bytes.ToValidUTF8,
+		"Trim":            bytes.Trim,
+		"TrimFunc":        bytes.TrimFunc,
+		"TrimLeft":        bytes.TrimLeft,
+		"TrimLeftFunc":    bytes.TrimLeftFunc,
+		"TrimPrefix":      bytes.TrimPrefix,
+		"TrimRight":       bytes.TrimRight,
+		"TrimRightFunc":   bytes.TrimRightFunc,
+		"TrimSpace":       bytes.TrimSpace,
+		"TrimSuffix":      bytes.TrimSuffix,
+	})
+}
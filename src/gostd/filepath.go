@@ -0,0 +1,39 @@
+// Code generated by bindgen from path/filepath; DO NOT EDIT.
+
+package gostd
+
+import (
+	"path/filepath"
+
+	"github.com/MatthewMcDade13/smack/src/interp"
+)
+
+func init() {
+	interp.RegisterPackage("go.path.filepath", map[string]any{
+		"Abs":           filepath.Abs,
+		"Base":          filepath.Base,
+		"Clean":         filepath.Clean,
+		"Dir":           filepath.Dir,
+		"ErrBadPattern": filepath.ErrBadPattern,
+		"EvalSymlinks":  filepath.EvalSymlinks,
+		"Ext":           filepath.Ext,
+		"FromSlash":     filepath.FromSlash,
+		"Glob":          filepath.Glob,
+		"HasPrefix":     filepath.HasPrefix,
+		"IsAbs":         filepath.IsAbs,
+		"IsLocal":       filepath.IsLocal,
+		"Join":          filepath.Join,
+		"ListSeparator": int64(filepath.ListSeparator),
+		"Match":         filepath.Match,
+		"Rel":           filepath.Rel,
+		"Separator":     int64(filepath.Separator),
+		"SkipAll":       filepath.SkipAll,
+		"SkipDir":       filepath.SkipDir,
+		"Split":         filepath.Split,
+		"SplitList":     filepath.SplitList,
+		"ToSlash":       filepath.ToSlash,
+		"VolumeName":    filepath.VolumeName,
+		"Walk":          filepath.Walk,
+		"WalkDir":       filepath.WalkDir,
+	})
+}
@@ -0,0 +1,19 @@
+// Package gostd binds common packages of the Go standard library as smack
+// namespaces (go.strings, go.strconv, go.math, go.time, go.os, go.path.filepath,
+// go.sort and go.bytes), see interp.RegisterPackage. Importing it registers them:
+//
+//	import _ "github.com/MatthewMcDade13/smack/src/gostd"
+//
+// The bindings are generated by src/bindgen from the packages of the Go toolchain
+// running go generate, without what was added to it after the go version of
+// go.mod. Regenerate them after upgrading either.
+package gostd
+
+//go:generate go run ../bindgen -o strings.go strings
+//go:generate go run ../bindgen -o strconv.go strconv
+//go:generate go run ../bindgen -o math.go math
+//go:generate go run ../bindgen -o time.go time
+//go:generate go run ../bindgen -o os.go os
+//go:generate go run ../bindgen -o filepath.go path/filepath
+//go:generate go run ../bindgen -o sort.go sort
+//go:generate go run ../bindgen -o bytes.go bytes
@@ -0,0 +1,43 @@
+package gostd
+
+import (
+	"testing"
+
+	"github.com/MatthewMcDade13/smack/src/interp"
+)
+
+func TestBindings(t *testing.T) {
+	it, err := interp.NewInterpreter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(go.strings/ToUpper "abc")`, `"ABC"`},
+		{`(go.strings/Split "a,b" ",")`, `["a" "b"]`},
+		{`(go.strconv/Itoa 5)`, `"5"`},
+		{`(go.math/Sqrt 16)`, "4"},
+		{`go.math/MaxInt8`, "127"},
+		{`(require '[go.path.filepath :as filepath]) (filepath/Join "a" "b")`, `"a/b"`},
+		{`(go.bytes/Equal "ab" "ab")`, "true"},
+		{`(go.time/ParseDuration "1s")`, "1e+09"},
+		// in place sorts return the sorted slice
+		{`(go.sort/Strings ["b" "c" "a"])`, `["a" "b" "c"]`},
+		{`(go.sort/Ints [3 1 2])`, "[1 2 3]"},
+		{`(go.sort/Float64s [0.5 -1])`, "[-1 0.5]"},
+		{`(go.sort/SearchInts [1 2 3] 2)`, "1"},
+	}
+	for _, tt := range tests {
+		if v, err := it.EvalString(tt.source); err != nil || interp.PrStr(v, true) != tt.want {
+			t.Errorf("%s => %s, %v, expected %s", tt.source, interp.PrStr(v, true), err, tt.want)
+		}
+	}
+	// these only change their argument in place, which scripts never see
+	for _, source := range []string{`go.sort/Sort`, `go.sort/Stable`, `go.sort/Slice`, `go.sort/SliceStable`} {
+		if _, err := it.EvalString(source); err == nil {
+			t.Errorf("%s is bound", source)
+		}
+	}
+}
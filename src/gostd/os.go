@@ -0,0 +1,123 @@
+// Code generated by bindgen from os; DO NOT EDIT.
+
+package gostd
+
+import (
+	"os"
+
+	"github.com/MatthewMcDade13/smack/src/interp"
+)
+
+func init() {
+	interp.RegisterPackage("go.os", map[string]any{
+		"Args":                os.Args,
+		"Chdir":               os.Chdir,
+		"Chmod":               os.Chmod,
+		"Chown":               os.Chown,
+		"Chtimes":             os.Chtimes,
+		"Clearenv":            os.Clearenv,
+		"Create":              os.Create,
+		"CreateTemp":          os.CreateTemp,
+		"DevNull":             os.DevNull,
+		"DirFS":               os.DirFS,
+		"Environ":             os.Environ,
+		"ErrClosed":           os.ErrClosed,
+		"ErrDeadlineExceeded": os.ErrDeadlineExceeded,
+		"ErrExist":            os.ErrExist,
+		"ErrInvalid":          os.ErrInvalid,
+		"ErrNoDeadline":       os.ErrNoDeadline,
+		"ErrNotExist":         os.ErrNotExist,
+		"ErrPermission":       os.ErrPermission,
+		"ErrProcessDone":      os.ErrProcessDone,
+		"Executable":          os.Executable,
+		"Exit":                os.Exit,
+		"Expand":              os.Expand,
+		"ExpandEnv":           os.ExpandEnv,
+		"new-File":            func() *os.File { return new(os.File) },
+		"FindProcess":         os.FindProcess,
+		"Getegid":             os.Getegid,
+		"Getenv":              os.Getenv,
+		"Geteuid":             os.Geteuid,
+		"Getgid":              os.Getgid,
+		"Getgroups":           os.Getgroups,
+		"Getpagesize":         os.Getpagesize,
+		"Getpid":              os.Getpid,
+		"Getppid":             os.Getppid,
+		"Getuid":              os.Getuid,
+		"Getwd":               os.Getwd,
+		"Hostname":            os.Hostname,
+		"Interrupt":           os.Interrupt,
+		"IsExist":             os.IsExist,
+		"IsNotExist":          os.IsNotExist,
+		"IsPathSeparator":     os.IsPathSeparator,
+		"IsPermission":        os.IsPermission,
+		"IsTimeout":           os.IsTimeout,
+		"Kill":                os.Kill,
+		"Lchown":              os.Lchown,
+		"Link":                os.Link,
+		"new-LinkError":       func() *os.LinkError { return new(os.LinkError) },
+		"LookupEnv":           os.LookupEnv,
+		"Lstat":               os.Lstat,
+		"Mkdir":               os.Mkdir,
+		"MkdirAll":            os.MkdirAll,
+		"MkdirTemp":           os.MkdirTemp,
+		"ModeAppend":          os.ModeAppend,
+		"ModeCharDevice":      os.ModeCharDevice,
+		"ModeDevice":          os.ModeDevice,
+		"ModeDir":             os.ModeDir,
+		"ModeExclusive":       os.ModeExclusive,
+		"ModeIrregular":       os.ModeIrregular,
+		"ModeNamedPipe":       os.ModeNamedPipe,
+		"ModePerm":            os.ModePerm,
+		"ModeSetgid":          os.ModeSetgid,
+		"ModeSetuid":          os.ModeSetuid,
+		"ModeSocket":          os.ModeSocket,
+		"ModeSticky":          os.ModeSticky,
+		"ModeSymlink":         os.ModeSymlink,
+		"ModeTemporary":       os.ModeTemporary,
+		"ModeType":            os.ModeType,
+		"NewFile":             os.NewFile,
+		"NewSyscallError":     os.NewSyscallError,
+		"O_APPEND":            os.O_APPEND,
+		"O_CREATE":            os.O_CREATE,
+		"O_EXCL":              os.O_EXCL,
+		"O_RDONLY":            os.O_RDONLY,
+		"O_RDWR":              os.O_RDWR,
+		"O_SYNC":              os.O_SYNC,
+		"O_TRUNC":             os.O_TRUNC,
+		"O_WRONLY":            os.O_WRONLY,
+		"Open":                os.Open,
+		"OpenFile":            os.OpenFile,
+		"PathListSeparator":   int64(os.PathListSeparator),
+		"PathSeparator":       int64(os.PathSeparator),
+		"Pipe":                os.Pipe,
+		"new-ProcAttr":        func() *os.ProcAttr { return new(os.ProcAttr) },
+		"new-Process":         func() *os.Process { return new(os.Process) },
+		"new-ProcessState":    func() *os.ProcessState { return new(os.ProcessState) },
+		"ReadDir":             os.ReadDir,
+		"ReadFile":            os.ReadFile,
+		"Readlink":            os.Readlink,
+		"Remove":              os.Remove,
+		"RemoveAll":           os.RemoveAll,
+		"Rename":              os.Rename,
+		"SEEK_CUR":            os.SEEK_CUR,
+		"SEEK_END":            os.SEEK_END,
+		"SEEK_SET":            os.SEEK_SET,
+		"SameFile":            os.SameFile,
+		"Setenv":              os.Setenv,
+		"StartProcess":        os.StartProcess,
+		"Stat":                os.Stat,
+		"Stderr":              os.Stderr,
+		"Stdin":               os.Stdin,
+		"Stdout":              os.Stdout,
+		"Symlink":             os.Symlink,
+		"new-SyscallError":    func() *os.SyscallError { return new(os.SyscallError) },
+		"TempDir":             os.TempDir,
+		"Truncate":            os.Truncate,
+		"Unsetenv":            os.Unsetenv,
+		"UserCacheDir":        os.UserCacheDir,
+		"UserConfigDir":       os.UserConfigDir,
+		"UserHomeDir":         os.UserHomeDir,
+		"WriteFile":           os.WriteFile,
+	})
+}
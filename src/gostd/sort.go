@@ -0,0 +1,37 @@
+// Code generated by bindgen from sort; DO NOT EDIT.
+
+package gostd
+
+import (
+	"sort"
+
+	"github.com/MatthewMcDade13/smack/src/interp"
+)
+
+func init() {
+	interp.RegisterPackage("go.sort", map[string]any{
+		"Find": sort.Find,
+		"Float64s": func(p0 []float64) []float64 {
+			sort.Float64s(p0)
+			return p0
+		},
+		"Float64sAreSorted": sort.Float64sAreSorted,
+		"Ints": func(p0 []int) []int {
+			sort.Ints(p0)
+			return p0
+		},
+		"IntsAreSorted":  sort.IntsAreSorted,
+		"IsSorted":       sort.IsSorted,
+		"Reverse":        sort.Reverse,
+		"Search":         sort.Search,
+		"SearchFloat64s": sort.SearchFloat64s,
+		"SearchInts":     sort.SearchInts,
+		"SearchStrings":  sort.SearchStrings,
+		"SliceIsSorted":  sort.SliceIsSorted,
+		"Strings": func(p0 []string) []string {
+			sort.Strings(p0)
+			return p0
+		},
+		"StringsAreSorted": sort.StringsAreSorted,
+	})
+}
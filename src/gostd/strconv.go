@@ -0,0 +1,52 @@
+// Code generated by bindgen from strconv; DO NOT EDIT.
+
+package gostd
+
+import (
+	"strconv"
+
+	"github.com/MatthewMcDade13/smack/src/interp"
+)
+
+func init() {
+	interp.RegisterPackage("go.strconv", map[string]any{
+		"AppendBool":               strconv.AppendBool,
+		"AppendFloat":              strconv.AppendFloat,
+		"AppendInt":                strconv.AppendInt,
+		"AppendQuote":              strconv.AppendQuote,
+		"AppendQuoteRune":          strconv.AppendQuoteRune,
+		"AppendQuoteRuneToASCII":   strconv.AppendQuoteRuneToASCII,
+		"AppendQuoteRuneToGraphic": strconv.AppendQuoteRuneToGraphic,
+		"AppendQuoteToASCII":       strconv.AppendQuoteToASCII,
+		"AppendQuoteToGraphic":     strconv.AppendQuoteToGraphic,
+		"AppendUint":               strconv.AppendUint,
+		"Atoi":                     strconv.Atoi,
+		"CanBackquote":             strconv.CanBackquote,
+		"ErrRange":                 strconv.ErrRange,
+		"ErrSyntax":                strconv.ErrSyntax,
+		"FormatBool":               strconv.FormatBool,
+		"FormatComplex":            strconv.FormatComplex,
+		"FormatFloat":              strconv.FormatFloat,
+		"FormatInt":                strconv.FormatInt,
+		"FormatUint":               strconv.FormatUint,
+		"IntSize":                  int64(strconv.IntSize),
+		"IsGraphic":                strconv.IsGraphic,
+		"IsPrint":                  strconv.IsPrint,
+		"Itoa":                     strconv.Itoa,
+		"new-NumError":             func() *strconv.NumError { return new(strconv.NumError) },
+		"ParseBool":                strconv.ParseBool,
+		"ParseComplex":             strconv.ParseComplex,
+		"ParseFloat":               strconv.ParseFloat,
+		"ParseInt":                 strconv.ParseInt,
+		"ParseUint":                strconv.ParseUint,
+		"Quote":                    strconv.Quote,
+		"QuoteRune":                strconv.QuoteRune,
+		"QuoteRuneToASCII":         strconv.QuoteRuneToASCII,
+		"QuoteRuneToGraphic":       strconv.QuoteRuneToGraphic,
+		"QuoteToASCII":             strconv.QuoteToASCII,
+		"QuoteToGraphic":           strconv.QuoteToGraphic,
+		"QuotedPrefix":             strconv.QuotedPrefix,
+		"Unquote":                  strconv.Unquote,
+		"UnquoteChar":              strconv.UnquoteChar,
+	})
+}
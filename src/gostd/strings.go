@@ -0,0 +1,69 @@
+// Code generated by bindgen from strings; DO NOT EDIT.
+
+package gostd
+
+import (
+	"strings"
+
+	"github.com/MatthewMcDade13/smack/src/interp"
+)
+
+func init() {
+	interp.RegisterPackage("go.strings", map[string]any{
+		"new-Builder":    func() *strings.Builder { return new(strings.Builder) },
+		"Clone":          strings.Clone,
+		"Compare":        strings.Compare,
+		"Contains":       strings.Contains,
+		"ContainsAny":    strings.ContainsAny,
+		"ContainsFunc":   strings.ContainsFunc,
+		"ContainsRune":   strings.ContainsRune,
+		"Count":          strings.Count,
+		"Cut":            strings.Cut,
+		"CutPrefix":      strings.CutPrefix,
+		"CutSuffix":      strings.CutSuffix,
+		"EqualFold":      strings.EqualFold,
+		"Fields":         strings.Fields,
+		"FieldsFunc":     strings.FieldsFunc,
+		"HasPrefix":      strings.HasPrefix,
+		"HasSuffix":      strings.HasSuffix,
+		"Index":          strings.Index,
+		"IndexAny":       strings.IndexAny,
+		"IndexByte":      strings.IndexByte,
+		"IndexFunc":      strings.IndexFunc,
+		"IndexRune":      strings.IndexRune,
+		"Join":           strings.Join,
+		"LastIndex":      strings.LastIndex,
+		"LastIndexAny":   strings.LastIndexAny,
+		"LastIndexByte":  strings.LastIndexByte,
+		"LastIndexFunc":  strings.LastIndexFunc,
+		"Map":            strings.Map,
+		"NewReader":      strings.NewReader,
+		"NewReplacer":    strings.NewReplacer,
+		"new-Reader":     func() *strings.Reader { return new(strings.Reader) },
+		"Repeat":         strings.Repeat,
+		"Replace":        strings.Replace,
+		"ReplaceAll":     strings.ReplaceAll,
+		"new-Replacer":   func() *strings.Replacer { return new(strings.Replacer) },
+		"Split":          strings.Split,
+		"SplitAfter":     strings.SplitAfter,
+		"SplitAfterN":    strings.SplitAfterN,
+		"SplitN":         strings.SplitN,
+		"Title":          strings.Title,
+		"ToLower":        strings.ToLower,
+		"ToLowerSpecial": strings.ToLowerSpecial,
+		"ToTitle":        strings.ToTitle,
+		"ToTitleSpecial": strings.ToTitleSpecial,
+		"ToUpper":        strings.ToUpper,
+		"ToUpperSpecial": strings.ToUpperSpecial,
+		"ToValidUTF8":    strings.ToValidUTF8,
+		"Trim":           strings.Trim,
+		"TrimFunc":       strings.TrimFunc,
+		"TrimLeft":       strings.TrimLeft,
+		"TrimLeftFunc":   strings.TrimLeftFunc,
+		"TrimPrefix":     strings.TrimPrefix,
+		"TrimRight":      strings.TrimRight,
+		"TrimRightFunc":  strings.TrimRightFunc,
+		"TrimSpace":      strings.TrimSpace,
+		"TrimSuffix":     strings.TrimSuffix,
+	})
+}
@@ -0,0 +1,84 @@
+// Code generated by bindgen from time; DO NOT EDIT.
+
+package gostd
+
+import (
+	"time"
+
+	"github.com/MatthewMcDade13/smack/src/interp"
+)
+
+func init() {
+	interp.RegisterPackage("go.time", map[string]any{
+		"ANSIC":                  time.ANSIC,
+		"After":                  time.After,
+		"AfterFunc":              time.AfterFunc,
+		"April":                  time.April,
+		"August":                 time.August,
+		"Date":                   time.Date,
+		"DateOnly":               time.DateOnly,
+		"DateTime":               time.DateTime,
+		"December":               time.December,
+		"February":               time.February,
+		"FixedZone":              time.FixedZone,
+		"Friday":                 time.Friday,
+		"Hour":                   time.Hour,
+		"January":                time.January,
+		"July":                   time.July,
+		"June":                   time.June,
+		"Kitchen":                time.Kitchen,
+		"Layout":                 time.Layout,
+		"LoadLocation":           time.LoadLocation,
+		"LoadLocationFromTZData": time.LoadLocationFromTZData,
+		"Local":                  time.Local,
+		"new-Location":           func() *time.Location { return new(time.Location) },
+		"March":                  time.March,
+		"May":                    time.May,
+		"Microsecond":            time.Microsecond,
+		"Millisecond":            time.Millisecond,
+		"Minute":                 time.Minute,
+		"Monday":                 time.Monday,
+		"Nanosecond":             time.Nanosecond,
+		"NewTicker":              time.NewTicker,
+		"NewTimer":               time.NewTimer,
+		"November":               time.November,
+		"Now":                    time.Now,
+		"October":                time.October,
+		"Parse":                  time.Parse,
+		"ParseDuration":          time.ParseDuration,
+		"new-ParseError":         func() *time.ParseError { return new(time.ParseError) },
+		"ParseInLocation":        time.ParseInLocation,
+		"RFC1123":                time.RFC1123,
+		"RFC1123Z":               time.RFC1123Z,
+		"RFC3339":                time.RFC3339,
+		"RFC3339Nano":            time.RFC3339Nano,
+		"RFC822":                 time.RFC822,
+		"RFC822Z":                time.RFC822Z,
+		"RFC850":                 time.RFC850,
+		"RubyDate":               time.RubyDate,
+		"Saturday":               time.Saturday,
+		"Second":                 time.Second,
+		"September":              time.September,
+		"Since":                  time.Since,
+		"Sleep":                  time.Sleep,
+		"Stamp":                  time.Stamp,
+		"StampMicro":             time.StampMicro,
+		"StampMilli":             time.StampMilli,
+		"StampNano":              time.StampNano,
+		"Sunday":                 time.Sunday,
+		"Thursday":               time.Thursday,
+		"Tick":                   time.Tick,
+		"new-Ticker":             func() *time.Ticker { return new(time.Ticker) },
+		"new-Time":               func() *time.Time { return new(time.Time) },
+		"TimeOnly":               time.TimeOnly,
+		"new-Timer":              func() *time.Timer { return new(time.Timer) },
+		"Tuesday":                time.Tuesday,
+		"UTC":                    time.UTC,
+		"Unix":                   time.Unix,
+		"UnixDate":               time.UnixDate,
+		"UnixMicro":              time.UnixMicro,
+		"UnixMilli":              time.UnixMilli,
+		"Until":                  time.Until,
+		"Wednesday":              time.Wednesday,
+	})
+}
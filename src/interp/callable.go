@@ -0,0 +1,70 @@
+package interp
+
+import "fmt"
+
+// Besides functions, keywords and collections can be called like functions:
+//
+//	(:key m)   (:key m default)   => lookup of :key in the hashmap or record m
+//	(m key)    (m key default)    => lookup of key in the hashmap m
+//	(v i)      (v i default)      => element at index i of the array v
+//	(s x)      (s x default)      => x if it is in the set s
+//
+// Missing keys return default, or nil when no default is given.
+
+func (v Value) IsCallable() bool {
+	switch v.Type() {
+	case VAL_FN, VAL_ATOM, VAL_HASHMAP, VAL_ARRAY, VAL_SET:
+		return true
+	default:
+		return false
+	}
+}
+
+func invoke_collection(f Value, args []Value) (Value, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return NoValue(), fmt.Errorf("Invalid arity calling %s. Expected 1 or 2, got: %d", f.TypeString(), len(args))
+	}
+	not_found := NewNilList()
+	if len(args) > 1 {
+		not_found = args[1]
+	}
+
+	switch f.Type() {
+	case VAL_ATOM:
+		if args[0].IsHashMap() {
+			if v, ok := args[0].AsHashMap().Get(f); ok {
+				return v, nil
+			}
+		}
+		if args[0].IsRecord() {
+			if v, ok := args[0].AsRecord().Get(f); ok {
+				return v, nil
+			}
+		}
+		return not_found, nil
+	case VAL_HASHMAP:
+		if v, ok := f.AsHashMap().Get(args[0]); ok {
+			return v, nil
+		}
+		return not_found, nil
+	case VAL_ARRAY:
+		i, err := args[0].TryNumber()
+		if err != nil {
+			return NoValue(), fmt.Errorf("Array index must be a number: %s", err)
+		}
+		if v, ok := f.AsVector().Nth(int(i)); ok {
+			return v, nil
+		}
+		if len(args) > 1 {
+			return not_found, nil
+		}
+		return NoValue(), fmt.Errorf("Index %d out of bounds for array of length %d", int(i), f.Len())
+	case VAL_SET:
+		if v, ok := f.AsSet().Get(args[0]); ok {
+			return v, nil
+		}
+		return not_found, nil
+	default:
+		return NoValue(), fmt.Errorf("Unable to call %s as function", f.TypeString())
+	}
+}
@@ -0,0 +1,44 @@
+package interp
+
+import "testing"
+
+func TestCallableValues(t *testing.T) {
+	it, err := NewInterpreter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := it.EvalString(`(def user {:name "ada"}) (defrecord P [x y])`); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(:name user)`, `"ada"`},
+		{`(user :name)`, `"ada"`},
+		{`(:age user)`, `()`},
+		{`(:age user 36)`, `36`},
+		{`(user :age 36)`, `36`},
+		{`(:a 1)`, `()`},
+		{`(:x (->P 1 2))`, `1`},
+		{`([10 20] 0)`, `10`},
+		{`([10 20] 5 :d)`, `:d`},
+		{`(#{1 2} 1)`, `1`},
+		{`(#{1 2} 3)`, `()`},
+		{`(#{1 2} 3 :d)`, `:d`},
+		// callable values can be passed where fns are
+		{`((fn (f x) (f x)) :name user)`, `"ada"`},
+		{`((fn (f x) (f x)) user :name)`, `"ada"`},
+	}
+	for _, tt := range tests {
+		if v, err := it.EvalString(tt.source); err != nil || PrStr(v, true) != tt.want {
+			t.Errorf("%s => %s, %v, expected %s", tt.source, PrStr(v, true), err, tt.want)
+		}
+	}
+
+	for _, source := range []string{`(:a)`, `(:a {} 1 2)`, `([10 20] 5)`, `(user)`, `((quot (1 2)) 0)`} {
+		if v, err := it.EvalString(source); err == nil && !v.IsError() {
+			t.Errorf("%s => %s, expected an error", source, PrStr(v, true))
+		}
+	}
+}
@@ -0,0 +1,1015 @@
+package interp
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The checker infers types over read forms without evaluating them and reports
+// calls that can not succeed and symbols that are never defined:
+//
+//	(+ "a" 1)                      => + expects Number for argument 1, got String
+//	(def ^Number n "one")          => n is tagged Number, got String
+//	(fn ^String (^Number x) ...)   => parameter and return type annotations
+//
+// Types are named as TypeString reports them (Number, String, Array, Point...)
+// plus Any and Nil, and Go types like *bytes.Buffer are GoObjects. A tag may be a
+// union like ^"Number|String", which is accepted where any of its members is.
+// Untagged params and values of unknown type are Any, which is compatible with
+// everything, so only definite mismatches are reported.
+
+const any_type = "Any"
+
+var builtin_types = map[string]bool{
+	any_type: true, "Nil": true, "Number": true, "String": true, "Boolean": true,
+	"List": true, "Array": true, "HashMap": true, "Set": true, "Symbol": true,
+	"Keyword": true, "Function": true, "Ref": true, "Channel": true, "Error": true,
+	"Protocol": true, "Record": true, "Option": true, "Result": true, "GoObject": true,
+}
+
+// A problem found by the checker
+type Diagnostic struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Msg)
+}
+
+// One arity of a fn, rest is the type of the arguments after & or empty if there are none
+type fn_sig struct {
+	params []string
+	rest   string
+	ret    string
+}
+
+// (sig ret params...), a param starting with & is the type of the rest arguments
+func sig(ret string, params ...string) fn_sig {
+	s := fn_sig{ret: ret}
+	for _, p := range params {
+		if strings.HasPrefix(p, "&") {
+			s.rest = p[1:]
+		} else {
+			s.params = append(s.params, p)
+		}
+	}
+	return s
+}
+
+func (s fn_sig) accepts(n int) bool {
+	return n == len(s.params) || (s.rest != "" && n > len(s.params))
+}
+
+func (s fn_sig) param(i int) string {
+	if i < len(s.params) {
+		return s.params[i]
+	}
+	return s.rest
+}
+
+const coll_type = "List|Array|Set|HashMap|Record|String"
+
+// Signatures of the fns in NewCoreEnv. check_test.go makes sure every core fn has
+// one, a fn missing here would only be checked for its arity by arglist_sigs.
+var core_signatures = map[string][]fn_sig{
+	"+":       {sig("Number", "&Number")},
+	"-":       {sig("Number", "&Number")},
+	"*":       {sig("Number", "&Number")},
+	"/":       {sig("Number", "&Number")},
+	"=":       {sig("Boolean", "Any", "&Any")},
+	"<":       {sig("Boolean", "Any", "&Any")},
+	"<=":      {sig("Boolean", "Any", "&Any")},
+	">":       {sig("Boolean", "Any", "&Any")},
+	">=":      {sig("Boolean", "Any", "&Any")},
+	"compare": {sig("Number", "Any", "Any")},
+
+	"println": {sig("Nil", "&Any")},
+	"prn":     {sig("Nil", "&Any")},
+	"pr-str":  {sig("String", "&Any")},
+	"str":     {sig("String", "&Any")},
+	"pprint":  {sig("Nil", "Any"), sig("Nil", "Any", "HashMap")},
+
+	"list":   {sig("List", "&Any")},
+	"list?":  {sig("Boolean", "Any")},
+	"empty?": {sig("Boolean", coll_type)},
+	"len":    {sig("Number", coll_type)},
+	"err?":   {sig("Boolean", "Any")},
+	"map?":   {sig("Boolean", "Any")},
+	"mget":   {sig(any_type, "HashMap|Record", "Any")},
+	"mset!":  {sig(any_type, "HashMap", "Any", "Any")},
+
+	"vector":    {sig("Array", "&Any")},
+	"vec":       {sig("Array", "List|Array")},
+	"hash-map":  {sig("HashMap", "&Any")},
+	"assoc":     {sig("HashMap|Record|Array", "HashMap|Record|Array", "Any", "Any", "&Any")},
+	"dissoc":    {sig("HashMap|Record", "HashMap|Record", "&Any")},
+	"conj":      {sig("List|Array|Set|HashMap", "List|Array|Set|HashMap", "&Any")},
+	"nth":       {sig(any_type, "List|Array", "Number")},
+	"contains?": {sig("Boolean", "HashMap|Record|Set|List|Array", "Any")},
+
+	"hash-set": {sig("Set", "&Any")},
+	"set":      {sig("Set", "Set|List|Array")},
+	"set?":     {sig("Boolean", "Any")},
+	"disj":     {sig("Set", "Set", "&Any")},
+
+	"meta":      {sig("HashMap|Nil", "Any")},
+	"with-meta": {sig(any_type, "Any", "HashMap|Nil")},
+	"vary-meta": {sig(any_type, "Any", "Function", "&Any")},
+
+	"ref":              {sig("Ref", "Any"), sig("Ref", "Any", "Function")},
+	"deref":            {sig(any_type, "Ref")},
+	"reset!":           {sig(any_type, "Ref", "Any")},
+	"swap!":            {sig(any_type, "Ref", "Function", "&Any")},
+	"compare-and-set!": {sig("Boolean", "Ref", "Any", "Any")},
+	"set-validator!":   {sig("Nil", "Ref", "Function|Nil")},
+	"add-watch":        {sig("Ref", "Ref", "Any", "Function")},
+	"remove-watch":     {sig("Ref", "Ref", "Any")},
+
+	"record?": {sig("Boolean", "Any")},
+
+	"prefer-method": {sig("Function", "Function", "Any", "Any")},
+	"remove-method": {sig("Function", "Function", "Any")},
+	"methods":       {sig("HashMap", "Function")},
+	"get-method":    {sig("Function|Nil", "Function", "Any")},
+
+	"some":       {sig("Option", "Any")},
+	"ok":         {sig("Result", "Any")},
+	"err":        {sig("Result", "Any")},
+	"some?":      {sig("Boolean", "Any")},
+	"none?":      {sig("Boolean", "Any")},
+	"ok?":        {sig("Boolean", "Any")},
+	"option?":    {sig("Boolean", "Any")},
+	"result?":    {sig("Boolean", "Any")},
+	"unwrap":     {sig(any_type, "Option|Result")},
+	"unwrap-or":  {sig(any_type, "Option|Result", "Any")},
+	"unwrap-err": {sig(any_type, "Result")},
+	"parse-num":  {sig("Result", "String")},
+
+	"satisfies?": {sig("Boolean", "Protocol", "Any")},
+	"extenders":  {sig("List", "Protocol")},
+
+	"read-str":  {sig("Result", "String")},
+	"spit":      {sig("Result", "String", "Any")},
+	"slurp":     {sig("Result", "String")},
+	"read-line": {sig("Option")},
+
+	"cons":   {sig("List", "Any", "List|Array")},
+	"concat": {sig("List", "&List|Array")},
+	"sort":   {sig("List|Array", "List|Array"), sig("List|Array", "Function", "List|Array")},
+
+	"go":    {sig("Nil", "Function", "&Any")},
+	"send!": {sig(any_type, "Channel", "Any")},
+	"recv!": {sig(any_type, "Channel")},
+
+	"object?":     {sig("Boolean", "Any")},
+	"go-methods":  {sig("List", "GoObject")},
+	"go-fields":   {sig("List", "GoObject")},
+	"go-packages": {sig("List")},
+
+	"eval":        {sig(any_type, "Any")},
+	"macroexpand": {sig(any_type, "Any")},
+	"apropos":     {sig("List", "String")},
+	"find-doc":    {sig("Nil", "String")},
+
+	"derive":    {sig("Nil", "Any", "Any")},
+	"underive":  {sig("Nil", "Any", "Any")},
+	"isa?":      {sig("Boolean", "Any", "Any")},
+	"parents":   {sig("Set|Nil", "Any")},
+	"ancestors": {sig("Set|Nil", "Any")},
+
+	"in-ns":     {sig("Symbol", "Symbol")},
+	"require":   {sig(any_type, "&Any")},
+	"load-file": {sig(any_type, "String")},
+	"alias":     {sig("Nil", "Symbol", "Symbol")},
+	"ns-name":   {sig("Symbol")},
+	"all-ns":    {sig("List")},
+}
+
+// What is known about a name or an expression
+type check_var struct {
+	ty string
+	// arities when it is a fn, nil when unknown
+	sigs []fn_sig
+}
+
+var any_var = check_var{ty: any_type}
+
+type check_scope struct {
+	vars  map[string]check_var
+	outer *check_scope
+}
+
+func (s *check_scope) child() *check_scope {
+	return &check_scope{vars: make(map[string]check_var), outer: s}
+}
+
+type checker struct {
+	file    string
+	diags   []Diagnostic
+	globals map[string]check_var
+	macros  map[string]bool
+	records map[string]bool
+	// names declared by a def that has not been checked yet, which only fn bodies
+	// can refer to
+	pending map[string]bool
+	// depth of the fn bodies being checked
+	fn_depth int
+	// names may be defined by code that is not checked (load-file, :refer :all),
+	// so unknown symbols are not reported
+	open bool
+}
+
+func new_checker(file string) *checker {
+	c := &checker{
+		file:    file,
+		globals: make(map[string]check_var),
+		macros:  make(map[string]bool),
+		records: make(map[string]bool),
+		pending: make(map[string]bool),
+	}
+	core := NewCoreEnv().ns.registry.core
+	for name, v := range core.data {
+		if sigs, ok := core_signatures[name]; ok {
+			c.globals[name] = check_var{ty: "Function", sigs: sigs}
+		} else if sigs, ok := arglist_sigs(v); ok {
+			c.globals[name] = check_var{ty: "Function", sigs: sigs}
+		} else {
+			c.globals[name] = check_var{ty: v.TypeString()}
+		}
+	}
+	return c
+}
+
+// Signatures of the fn v from its :arglists, for core fns without an entry in
+// core_signatures. Only the arity is known, every type is Any.
+func arglist_sigs(v Value) ([]fn_sig, bool) {
+	if !v.IsFn() || v.meta == nil {
+		return nil, false
+	}
+	arglists, ok := v.meta.Get(NewAtom(":arglists"))
+	if !ok || !arglists.IsListLike() {
+		return nil, false
+	}
+	sigs := make([]fn_sig, 0)
+	for _, params := range arglists.AsList() {
+		if !params.IsListLike() {
+			return nil, false
+		}
+		sigs = append(sigs, params_sig(params.AsList(), any_type))
+	}
+	return sigs, len(sigs) > 0
+}
+
+// Checks every form of source, file names it in diagnostics. The error is for
+// source that can not be read
+func Check(source string, file string) ([]Diagnostic, error) {
+	forms, err := ReadAllPositions(source)
+	if err != nil {
+		return nil, err
+	}
+	c := new_checker(file)
+	for _, form := range forms {
+		c.declare(form)
+	}
+	for _, form := range forms {
+		c.infer(form, nil, form)
+	}
+	sort.SliceStable(c.diags, func(i, j int) bool {
+		a, b := c.diags[i], c.diags[j]
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+	return c.diags, nil
+}
+
+func CheckFile(path string) ([]Diagnostic, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Check(string(source), path)
+}
+
+// Line and column the reader attached to form, 0 when it has none
+func form_position(form Value) (int, int) {
+	meta := form.Meta()
+	if meta == nil {
+		return 0, 0
+	}
+	line, _ := meta.Get(NewAtom(":line"))
+	col, _ := meta.Get(NewAtom(":column"))
+	if !line.IsNumber() || !col.IsNumber() {
+		return 0, 0
+	}
+	return int(line.AsNumber()), int(col.AsNumber())
+}
+
+// Reports msg at form, or at the closest enclosing form with a position
+func (c *checker) report(form Value, at Value, msg string, args ...interface{}) {
+	line, col := form_position(form)
+	if line == 0 {
+		line, col = form_position(at)
+	}
+	c.diags = append(c.diags, Diagnostic{c.file, line, col, fmt.Sprintf(msg, args...)})
+}
+
+func (c *checker) lookup(name string, sc *check_scope) (check_var, bool) {
+	for s := sc; s != nil; s = s.outer {
+		if v, ok := s.vars[name]; ok {
+			return v, true
+		}
+	}
+	v, ok := c.globals[name]
+	return v, ok
+}
+
+func (c *checker) is_local(name string, sc *check_scope) bool {
+	for s := sc; s != nil; s = s.outer {
+		if _, ok := s.vars[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *checker) is_macro(name string, sc *check_scope) bool {
+	return !c.is_local(name, sc) && c.macros[name]
+}
+
+func (c *checker) is_type(name string) bool {
+	return builtin_types[name] || c.records[name] || is_go_type_name(name)
+}
+
+// Package qualified Go types, which Go objects report as their type
+func is_go_type_name(name string) bool {
+	return strings.Contains(name, ".")
+}
+
+// expected accepts a value of type actual when any of their members agree
+func (c *checker) accepts(expected string, actual string) bool {
+	if expected == any_type || actual == any_type {
+		return true
+	}
+	for _, a := range strings.Split(actual, "|") {
+		for _, e := range strings.Split(expected, "|") {
+			switch {
+			case e == any_type, a == any_type, e == a:
+				return true
+			// nil is the empty list
+			case a == "Nil" && e == "List":
+				return true
+			case e == "Record" && c.records[a]:
+				return true
+			case e == "GoObject" && is_go_type_name(a), a == "GoObject" && is_go_type_name(e):
+				return true
+			// nil pointers are nil
+			case a == "Nil" && is_go_type_name(e):
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func join_types(a string, b string) string {
+	if a == any_type || b == any_type {
+		return any_type
+	}
+	parts := strings.Split(a, "|")
+	for _, t := range strings.Split(b, "|") {
+		found := false
+		for _, p := range parts {
+			found = found || p == t
+		}
+		if !found {
+			parts = append(parts, t)
+		}
+	}
+	return strings.Join(parts, "|")
+}
+
+// The type form is tagged with, empty when it has none. Unknown types are reported
+func (c *checker) tag_of(form Value, at Value) string {
+	meta := form.Meta()
+	if meta == nil {
+		return ""
+	}
+	tag, ok := meta.Get(NewAtom(":tag"))
+	if !ok {
+		return ""
+	}
+	var name string
+	switch {
+	case tag.IsSymbol():
+		name = tag.AsSymbol().Name()
+	case tag.IsString():
+		name = tag.AsString()
+	default:
+		return ""
+	}
+	for _, t := range strings.Split(name, "|") {
+		if !c.is_type(t) {
+			c.report(form, at, "unknown type %s", t)
+			return any_type
+		}
+	}
+	return name
+}
+
+// Tag of form without reporting, for declarations made before records are known
+func quiet_tag(form Value) string {
+	if meta := form.Meta(); meta != nil {
+		if tag, ok := meta.Get(NewAtom(":tag")); ok {
+			if tag.IsSymbol() {
+				return tag.AsSymbol().Name()
+			}
+			if tag.IsString() {
+				return tag.AsString()
+			}
+		}
+	}
+	return ""
+}
+
+func or_any(ty string) string {
+	if ty == "" {
+		return any_type
+	}
+	return ty
+}
+
+func is_qualified(name string) bool {
+	return len(name) > 1 && strings.Contains(name, "/")
+}
+
+// Declares the names a top level form defines, so they can be used before their
+// definition in fn bodies
+func (c *checker) declare(form Value) {
+	if !form.IsList() || len(form.AsList()) == 0 || !form.AsList()[0].IsSymbol() {
+		return
+	}
+	list := form.AsList()
+	switch list[0].AsSymbol().Name() {
+	case "do":
+		for _, f := range list[1:] {
+			c.declare(f)
+		}
+	case "def":
+		if len(list) < 3 || !list[1].IsSymbol() {
+			return
+		}
+		v := check_var{ty: or_any(quiet_tag(list[1]))}
+		if fn_form := list[len(list)-1]; is_form_named(fn_form, "fn") {
+			if s, ok := declared_fn_sig(fn_form.AsList()[1:]); ok {
+				v = check_var{ty: "Function", sigs: []fn_sig{s}}
+			}
+		}
+		c.globals[list[1].AsSymbol().Name()] = v
+		c.pending[list[1].AsSymbol().Name()] = true
+	case "defmacro":
+		if len(list) > 1 && list[1].IsSymbol() {
+			c.macros[list[1].AsSymbol().Name()] = true
+		}
+	case "defprotocol":
+		if len(list) < 2 || !list[1].IsSymbol() {
+			return
+		}
+		c.globals[list[1].AsSymbol().Name()] = check_var{ty: "Protocol"}
+		for _, m := range list[2:] {
+			pm, err := parse_protocol_method(m)
+			if err != nil {
+				continue
+			}
+			sigs := make([]fn_sig, 0, len(pm.arglists))
+			for _, params := range pm.arglists {
+				sigs = append(sigs, params_sig(params.AsList(), any_type))
+			}
+			c.globals[pm.name] = check_var{ty: "Function", sigs: sigs}
+		}
+	case "defrecord", "defstruct":
+		if len(list) < 3 || !list[1].IsSymbol() {
+			return
+		}
+		name := list[1].AsSymbol().Name()
+		c.records[name] = true
+		fields := list[len(list)-1]
+		if !fields.IsListLike() {
+			return
+		}
+		c.globals["->"+name] = check_var{ty: "Function", sigs: []fn_sig{params_sig(fields.AsList(), name)}}
+		c.globals["map->"+name] = check_var{ty: "Function", sigs: []fn_sig{sig(name, "HashMap")}}
+		c.globals[name+"?"] = check_var{ty: "Function", sigs: []fn_sig{sig("Boolean", "Any")}}
+	case "defmulti":
+		if len(list) > 1 && list[1].IsSymbol() {
+			c.globals[list[1].AsSymbol().Name()] = check_var{ty: "Function"}
+		}
+	case "ns":
+		for _, clause := range list[2:] {
+			if clause.IsList() && len(clause.AsList()) > 0 && is_keyword_named(clause.AsList()[0], ":require") {
+				for _, spec := range clause.AsList()[1:] {
+					c.declare_require(spec)
+				}
+			}
+		}
+	case "require":
+		for _, spec := range list[1:] {
+			if is_form_named(spec, "quot") && len(spec.AsList()) == 2 {
+				c.declare_require(spec.AsList()[1])
+			}
+		}
+	case "load-file":
+		c.open = true
+	}
+}
+
+// Names referred by a require spec, [ns :as alias :refer [names]] or :refer :all
+func (c *checker) declare_require(spec Value) {
+	if !spec.IsListLike() {
+		return
+	}
+	opts := spec.AsList()
+	for i := 1; i+1 < len(opts); i++ {
+		if !is_keyword_named(opts[i], ":refer") {
+			continue
+		}
+		refer := opts[i+1]
+		if refer.IsAtom() && refer.AsAtom().Name() == ":all" {
+			c.open = true
+		}
+		if refer.IsListLike() {
+			for _, name := range refer.AsList() {
+				if name.IsSymbol() {
+					c.globals[name.AsSymbol().Name()] = any_var
+				}
+			}
+		}
+	}
+}
+
+func is_form_named(form Value, name string) bool {
+	return form.IsList() && len(form.AsList()) > 0 && is_symbol_named(form.AsList()[0], name)
+}
+
+func is_keyword_named(v Value, name string) bool {
+	return v.IsAtom() && v.AsAtom().Name() == name
+}
+
+// Signature of a param list, types from their tags
+func params_sig(params []Value, ret string) fn_sig {
+	s := fn_sig{ret: ret}
+	for i := 0; i < len(params); i++ {
+		if is_symbol_named(params[i], "&") {
+			s.rest = any_type
+			break
+		}
+		s.params = append(s.params, or_any(quiet_tag(params[i])))
+	}
+	return s
+}
+
+// Signature of (fn "doc"? params body) from its annotations alone
+func declared_fn_sig(forms []Value) (fn_sig, bool) {
+	if len(forms) > 2 && forms[0].IsString() {
+		forms = forms[1:]
+	}
+	if len(forms) < 2 || !forms[0].IsListLike() {
+		return fn_sig{}, false
+	}
+	return params_sig(forms[0].AsList(), or_any(quiet_tag(forms[0]))), true
+}
+
+// Infers the type of form in scope sc, at is the closest enclosing form with a position
+func (c *checker) infer(form Value, sc *check_scope, at Value) check_var {
+	if line, _ := form_position(form); line > 0 {
+		at = form
+	}
+	switch form.Type() {
+	case VAL_NUMBER, VAL_STRING, VAL_BOOLEAN:
+		return check_var{ty: form.TypeString()}
+	case VAL_ATOM:
+		return check_var{ty: "Keyword"}
+	case VAL_SYMBOL:
+		name := form.AsSymbol().Name()
+		if v, ok := c.lookup(name, sc); ok {
+			if c.fn_depth == 0 && c.pending[name] && !c.is_local(name, sc) {
+				c.report(form, at, "%s is used before its definition", name)
+			}
+			return v
+		}
+		if !c.open && !is_qualified(name) {
+			c.report(form, at, "unknown symbol %s", name)
+		}
+		return any_var
+	case VAL_ARRAY, VAL_HASHMAP, VAL_SET:
+		for _, v := range form.AsList() {
+			c.infer(v, sc, at)
+		}
+		return check_var{ty: form.TypeString()}
+	case VAL_LIST:
+		return c.infer_list(form, sc, at)
+	default:
+		return any_var
+	}
+}
+
+func (c *checker) infer_body(forms []Value, sc *check_scope, at Value) check_var {
+	res := check_var{ty: "Nil"}
+	for _, f := range forms {
+		res = c.infer(f, sc, at)
+	}
+	return res
+}
+
+func (c *checker) infer_list(form Value, sc *check_scope, at Value) check_var {
+	list := form.AsList()
+	if len(list) == 0 {
+		return check_var{ty: "Nil"}
+	}
+	head := list[0]
+	name := ""
+	if head.IsSymbol() {
+		name = head.AsSymbol().Name()
+		if _, shadowed := c.lookup(name, sc); IsSpecialForm(name) && !is_interop_symbol(name) && !shadowed {
+			return c.infer_special(name, list, sc, at)
+		}
+		if c.is_macro(name, sc) {
+			return any_var
+		}
+		if _, shadowed := c.lookup(name, sc); is_interop_symbol(name) && !shadowed {
+			return c.infer_interop(name, list, sc, at)
+		}
+	}
+
+	f := c.infer(head, sc, at)
+	args := make([]check_var, len(list)-1)
+	for i, arg := range list[1:] {
+		args[i] = c.infer(arg, sc, at)
+	}
+	if name == "" {
+		name = PrStr(head, true)
+	}
+
+	switch {
+	case len(f.sigs) > 0:
+		return c.check_call(name, f.sigs, args, list[1:], at)
+	case c.accepts("Function|Keyword|HashMap|Array|Set|Record", f.ty):
+		return any_var
+	default:
+		c.report(head, at, "%s is a %s, not a function", name, f.ty)
+		return any_var
+	}
+}
+
+// Checks a call to name with arguments of types args, returning the type of its result
+func (c *checker) check_call(name string, sigs []fn_sig, args []check_var, forms []Value, at Value) check_var {
+	var s *fn_sig
+	for i := range sigs {
+		if sigs[i].accepts(len(args)) {
+			s = &sigs[i]
+			break
+		}
+	}
+	if s == nil {
+		c.report(at, at, "%s expects %s, got %d", name, describe_arity(sigs), len(args))
+		return any_var
+	}
+	for i, arg := range args {
+		if expected := s.param(i); !c.accepts(expected, arg.ty) {
+			c.report(forms[i], at, "%s expects %s for argument %d, got %s", name, expected, i+1, arg.ty)
+		}
+	}
+	return check_var{ty: s.ret}
+}
+
+func describe_arity(sigs []fn_sig) string {
+	counts := make([]string, 0, len(sigs))
+	for _, s := range sigs {
+		if s.rest != "" {
+			counts = append(counts, fmt.Sprintf("at least %d", len(s.params)))
+		} else {
+			counts = append(counts, fmt.Sprintf("%d", len(s.params)))
+		}
+	}
+	plural := "s"
+	if len(sigs) == 1 && sigs[0].rest == "" && len(sigs[0].params) == 1 {
+		plural = ""
+	}
+	return strings.Join(counts, " or ") + " argument" + plural
+}
+
+func (c *checker) infer_special(name string, list []Value, sc *check_scope, at Value) check_var {
+	switch name {
+	case "def":
+		if len(list) < 3 || !list[1].IsSymbol() {
+			c.report(at, at, "def => Expected a name and a value")
+			return any_var
+		}
+		tag := c.tag_of(list[1], at)
+		v := c.infer(list[len(list)-1], sc, at)
+		if tag != "" && !c.accepts(tag, v.ty) {
+			c.report(list[len(list)-1], at, "%s is tagged %s, got %s", list[1].AsSymbol().Name(), tag, v.ty)
+		}
+		if tag != "" {
+			v.ty = tag
+		}
+		// def defines name in the env it is evaluated in, inside a fn body that is the
+		// env of the call
+		if sc == nil {
+			c.globals[list[1].AsSymbol().Name()] = v
+			delete(c.pending, list[1].AsSymbol().Name())
+		} else {
+			sc.vars[list[1].AsSymbol().Name()] = v
+		}
+		return v
+	case "defmacro":
+		if len(list) > 2 {
+			c.infer_fn(list[2:], sc, at, "")
+		}
+		return check_var{ty: "Function"}
+	case "fn":
+		return c.infer_fn(list[1:], sc, at, "")
+	case "let":
+		if len(list) < 3 || !list[1].IsListLike() {
+			c.report(at, at, "let => Expected bindings and a body")
+			return any_var
+		}
+		let_sc := sc.child()
+		bindings := list[1].AsList()
+		for i := 1; i < len(bindings); i = i + 2 {
+			if !bindings[i-1].IsSymbol() {
+				c.report(bindings[i-1], at, "let => Expected a symbol to bind, got %s", PrStr(bindings[i-1], true))
+				continue
+			}
+			v := c.infer(bindings[i], let_sc, at)
+			if tag := c.tag_of(bindings[i-1], at); tag != "" {
+				if !c.accepts(tag, v.ty) {
+					c.report(bindings[i], at, "%s is tagged %s, got %s", bindings[i-1].AsSymbol().Name(), tag, v.ty)
+				}
+				v.ty = tag
+			}
+			let_sc.vars[bindings[i-1].AsSymbol().Name()] = v
+		}
+		return c.infer_body(list[2:], let_sc, at)
+	case "do":
+		return c.infer_body(list[1:], sc, at)
+	case "if":
+		if len(list) < 3 {
+			c.report(at, at, "if => Expected a condition and a body")
+			return any_var
+		}
+		c.infer(list[1], sc, at)
+		then := c.infer(list[2], sc, at)
+		otherwise := check_var{ty: "Nil"}
+		if len(list) > 3 {
+			otherwise = c.infer(list[3], sc, at)
+		}
+		return check_var{ty: join_types(then.ty, otherwise.ty)}
+	case "quot":
+		if len(list) > 1 {
+			return check_var{ty: quoted_type(list[1])}
+		}
+		return any_var
+	case "quasiquot":
+		if len(list) > 1 {
+			c.infer_unquoted(list[1], sc, at)
+			return check_var{ty: quoted_type(list[1])}
+		}
+		return any_var
+	case "?":
+		if len(list) != 2 {
+			c.report(at, at, "? => Expected exactly 1 form, got: %d", len(list)-1)
+			return any_var
+		}
+		v := c.infer(list[1], sc, at)
+		if c.accepts("Option|Result|Error", v.ty) {
+			return any_var
+		}
+		return v
+	case "match":
+		return c.infer_match(list, sc, at)
+	case "binding":
+		if len(list) < 3 || !list[1].IsListLike() {
+			return any_var
+		}
+		bindings := list[1].AsList()
+		for i := 1; i < len(bindings); i = i + 2 {
+			c.infer(bindings[i-1], sc, at)
+			c.infer(bindings[i], sc, at)
+		}
+		return c.infer_body(list[2:], sc, at)
+	case "defrecord", "defstruct":
+		if fields := list[len(list)-1]; fields.IsListLike() {
+			for _, f := range fields.AsList() {
+				c.tag_of(f, at)
+			}
+		}
+		return check_var{ty: "Symbol"}
+	case "defmulti":
+		for _, f := range list[2:] {
+			if !f.IsString() {
+				c.infer(f, sc, at)
+			}
+		}
+		return check_var{ty: "Function"}
+	case "defmethod":
+		if len(list) < 4 {
+			return any_var
+		}
+		c.infer(list[1], sc, at)
+		c.infer(list[2], sc, at)
+		c.infer_fn(list[3:], sc, at, "")
+		return check_var{ty: "Function"}
+	case "extend-type":
+		if len(list) < 3 || !list[1].IsSymbol() {
+			return any_var
+		}
+		for _, group := range split_groups(list[2:], Value.IsSymbol) {
+			c.infer_impls(group, list[1].AsSymbol().Name(), sc, at)
+		}
+		return check_var{ty: "Nil"}
+	case "extend-protocol":
+		if len(list) < 3 {
+			return any_var
+		}
+		c.check_protocol(list[1], sc, at)
+		for _, group := range split_groups(list[2:], Value.IsSymbol) {
+			if group[0].IsSymbol() {
+				c.infer_impls(append([]Value{list[1]}, group[1:]...), group[0].AsSymbol().Name(), sc, at)
+			}
+		}
+		return check_var{ty: "Nil"}
+	case "defprotocol":
+		return check_var{ty: "Protocol"}
+	case "set!":
+		if len(list) != 3 || !list[1].IsList() || len(list[1].AsList()) != 2 || !list[1].AsList()[0].IsSymbol() ||
+			!strings.HasPrefix(list[1].AsList()[0].AsSymbol().Name(), ".-") {
+			c.report(at, at, "set! => Expected (set! (.-Field obj) val)")
+			return any_var
+		}
+		place := list[1].AsList()
+		c.infer_interop(place[0].AsSymbol().Name(), place, sc, at)
+		return c.infer(list[2], sc, at)
+	default:
+		// doc and ns name their arguments without evaluating them
+		return any_var
+	}
+}
+
+// (.Method obj args...) or (.-Field obj), what they return is only known at runtime
+func (c *checker) infer_interop(name string, list []Value, sc *check_scope, at Value) check_var {
+	if len(list) < 2 {
+		c.report(at, at, "%s => Expected a Go object", name)
+		return any_var
+	}
+	if obj := c.infer(list[1], sc, at); !c.accepts("GoObject", obj.ty) {
+		c.report(list[1], at, "%s expects a GoObject, got %s", name, obj.ty)
+	}
+	for _, arg := range list[2:] {
+		c.infer(arg, sc, at)
+	}
+	return any_var
+}
+
+// (fn "doc"? params body), this_type is the type of the first param when it is not tagged
+func (c *checker) infer_fn(forms []Value, sc *check_scope, at Value, this_type string) check_var {
+	if len(forms) > 2 && forms[0].IsString() {
+		forms = forms[1:]
+	}
+	if len(forms) < 2 || !forms[0].IsListLike() {
+		c.report(at, at, "fn => Expected a parameter list and a body")
+		return check_var{ty: "Function"}
+	}
+	fn_sc := sc.child()
+	params := forms[0].AsList()
+	s := fn_sig{}
+	for i := 0; i < len(params); i++ {
+		if !params[i].IsSymbol() {
+			c.report(params[i], at, "fn => Expected a parameter name, got %s", PrStr(params[i], true))
+			continue
+		}
+		if is_symbol_named(params[i], "&") {
+			if i+1 < len(params) && params[i+1].IsSymbol() {
+				fn_sc.vars[params[i+1].AsSymbol().Name()] = check_var{ty: "List"}
+			}
+			s.rest = any_type
+			break
+		}
+		ty := or_any(c.tag_of(params[i], at))
+		if i == 0 && this_type != "" && ty == any_type {
+			ty = this_type
+		}
+		s.params = append(s.params, ty)
+		fn_sc.vars[params[i].AsSymbol().Name()] = check_var{ty: ty}
+	}
+
+	c.fn_depth++
+	body := c.infer_body(forms[1:], fn_sc, at)
+	c.fn_depth--
+	s.ret = body.ty
+	if tag := c.tag_of(forms[0], at); tag != "" {
+		if !c.accepts(tag, body.ty) {
+			c.report(forms[1], at, "fn is tagged to return %s, got %s", tag, body.ty)
+		}
+		s.ret = tag
+	}
+	return check_var{ty: "Function", sigs: []fn_sig{s}}
+}
+
+// Protocol (method (this args...) body)... implemented for type_name
+func (c *checker) infer_impls(group []Value, type_name string, sc *check_scope, at Value) {
+	c.check_protocol(group[0], sc, at)
+	this := type_name
+	switch {
+	case type_name == object_type_name:
+		this = any_type
+	case !c.is_type(type_name):
+		if !c.open {
+			c.report(group[0], at, "unknown type %s", type_name)
+		}
+		this = any_type
+	}
+	for _, impl := range group[1:] {
+		if !impl.IsList() || len(impl.AsList()) < 3 || !impl.AsList()[0].IsSymbol() {
+			c.report(impl, at, "Expected a method implementation (name (this args...) body), got: %s", PrStr(impl, true))
+			continue
+		}
+		c.infer(impl.AsList()[0], sc, impl)
+		c.infer_fn(impl.AsList()[1:], sc, at, this)
+	}
+}
+
+func (c *checker) check_protocol(form Value, sc *check_scope, at Value) {
+	if v := c.infer(form, sc, at); !c.accepts("Protocol", v.ty) {
+		c.report(form, at, "%s is a %s, not a protocol", PrStr(form, true), v.ty)
+	}
+}
+
+// Checks the unquoted forms of a quasiquoted one
+func (c *checker) infer_unquoted(form Value, sc *check_scope, at Value) {
+	if !form.IsListLike() && !form.IsHashMap() && !form.IsSet() {
+		return
+	}
+	list := form.AsList()
+	if form.IsList() && len(list) == 2 && (is_symbol_named(list[0], "unquot") || is_symbol_named(list[0], "splice-unquot")) {
+		c.infer(list[1], sc, at)
+		return
+	}
+	for _, f := range list {
+		c.infer_unquoted(f, sc, at)
+	}
+}
+
+func quoted_type(form Value) string {
+	if form.IsList() && len(form.AsList()) == 0 {
+		return "Nil"
+	}
+	return form.TypeString()
+}
+
+func (c *checker) infer_match(list []Value, sc *check_scope, at Value) check_var {
+	if len(list) < 2 {
+		c.report(at, at, "match => Expected an expression and clauses")
+		return any_var
+	}
+	c.infer(list[1], sc, at)
+	clauses, err := parse_match_clauses(list[2:])
+	if err != nil {
+		c.report(at, at, "%s", err)
+		return any_var
+	}
+	if err := check_match_clauses(clauses); err != nil {
+		c.report(at, at, "%s", err)
+	}
+	res := ""
+	for _, clause := range clauses {
+		clause_sc := sc.child()
+		bind_pattern_names(clause.pat, clause_sc)
+		if clause.guard.IsSome() {
+			c.infer(clause.guard, clause_sc, at)
+		}
+		body := c.infer(clause.body, clause_sc, at)
+		if res == "" {
+			res = body.ty
+		} else {
+			res = join_types(res, body.ty)
+		}
+	}
+	return check_var{ty: or_any(res)}
+}
+
+func bind_pattern_names(p *pattern, sc *check_scope) {
+	if p == nil {
+		return
+	}
+	if p.kind == pat_bind {
+		sc.vars[p.name] = any_var
+	}
+	for _, sub := range p.elems {
+		bind_pattern_names(sub, sc)
+	}
+	bind_pattern_names(p.rest, sc)
+}
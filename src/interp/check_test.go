@@ -0,0 +1,54 @@
+package interp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCoreSignatures(t *testing.T) {
+	core := NewCoreEnv().ns.registry.core
+	for name, v := range core.data {
+		if !v.IsFn() {
+			continue
+		}
+		if _, ok := core_signatures[name]; !ok {
+			t.Errorf("core fn %s has no entry in core_signatures", name)
+		}
+	}
+	for name := range core_signatures {
+		if _, ok := core.data[name]; !ok {
+			t.Errorf("core_signatures has an entry for %s, which is not a core fn", name)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		source string
+		// substring of the only diagnostic, none when empty
+		diag string
+	}{
+		{`(+ 1 2)`, ""},
+		{`(+ "a" 1)`, "+ expects Number for argument 1, got String"},
+		{`(def ^Number n "one")`, "n is tagged Number, got String"},
+		{`(len)`, "len"},
+		{`(println y)`, "unknown symbol y"},
+		{`(def f (fn () (g))) (def g (fn () 1))`, ""},
+		{`(def safe-div (fn (a b) (ok (/ a b)))) (fn (a b) (do (def x (? (safe-div a b))) x))`, ""},
+		{`(def f (fn () (do (def x 1) x))) (println x)`, "unknown symbol x"},
+		{`(let (a 1) (+ a "b"))`, "+ expects Number for argument 2, got String"},
+	}
+	for _, tt := range tests {
+		diags, err := Check(tt.source, "test.smk")
+		if err != nil {
+			t.Errorf("Check(%q) => %s", tt.source, err)
+			continue
+		}
+		switch {
+		case tt.diag == "" && len(diags) > 0:
+			t.Errorf("Check(%q) => unexpected %s", tt.source, diags[0])
+		case tt.diag != "" && (len(diags) != 1 || !strings.Contains(diags[0].Msg, tt.diag)):
+			t.Errorf("Check(%q) => %v, expected %q", tt.source, diags, tt.diag)
+		}
+	}
+}
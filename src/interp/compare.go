@@ -0,0 +1,66 @@
+package interp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Total ordering for the comparable value types. Returns -1, 0 or 1.
+// Numbers, strings, keywords, symbols and booleans (false < true) compare
+// by value, lists and arrays compare element by element with shorter
+// sequences first when one is a prefix of the other. Comparing
+// any other types, or values of different types, is an error.
+func Compare(left Value, right Value) (int, error) {
+	if left.IsListLike() && right.IsListLike() {
+		l := left.AsList()
+		r := right.AsList()
+		for i := 0; i < len(l) && i < len(r); i++ {
+			if c, err := Compare(l[i], r[i]); err != nil || c != 0 {
+				return c, err
+			}
+		}
+		return compare_ints(len(l), len(r)), nil
+	}
+
+	if left.Type() != right.Type() {
+		return 0, fmt.Errorf("Cannot compare %s with %s", left.TypeString(), right.TypeString())
+	}
+
+	switch left.Type() {
+	case VAL_NUMBER:
+		l := left.AsNumber()
+		r := right.AsNumber()
+		if l < r {
+			return -1, nil
+		} else if l > r {
+			return 1, nil
+		}
+		return 0, nil
+	case VAL_STRING:
+		return strings.Compare(left.AsString(), right.AsString()), nil
+	case VAL_ATOM:
+		return strings.Compare(left.AsAtom().Name(), right.AsAtom().Name()), nil
+	case VAL_SYMBOL:
+		return strings.Compare(left.AsSymbol().Name(), right.AsSymbol().Name()), nil
+	case VAL_BOOLEAN:
+		l := left.AsBool()
+		r := right.AsBool()
+		if l == r {
+			return 0, nil
+		} else if r {
+			return -1, nil
+		}
+		return 1, nil
+	default:
+		return 0, fmt.Errorf("Values of type %s are not comparable", left.TypeString())
+	}
+}
+
+func compare_ints(l int, r int) int {
+	if l < r {
+		return -1
+	} else if l > r {
+		return 1
+	}
+	return 0
+}
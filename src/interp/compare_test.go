@@ -0,0 +1,83 @@
+package interp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEquality(t *testing.T) {
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{`(= (list 1 2) [1 2])`, true},
+		{`(= [1 2] (list 1 2))`, true},
+		{`(= (list 1 2) (list 1))`, false},
+		{`(= (list 1) (list 1 2))`, false},
+		{`(= {:a 1 :b [1 2]} {:b (list 1 2) :a 1})`, true},
+		{`(= {:a 1} {:a 2})`, false},
+		{`(= {:a 1} {:a 1 :b 2})`, false},
+		{`(= #{1 2} #{2 1})`, true},
+		{`(= #{1} #{1 2})`, false},
+		{`(= 1 "1")`, false},
+		{`(= :a "a")`, false},
+		{`(= (quot a) :a)`, false},
+		{`(= nil (list))`, true},
+		{`(= + +)`, true},
+		{`(= (fn (x) x) (fn (x) x))`, false},
+		{`(= ##NaN ##NaN)`, false},
+		{`(= 0 -0)`, true},
+		{`(= (some 1) (some 1))`, true},
+		{`(= (ok 1) (err 1))`, false},
+		{`(= 1 1 1)`, true},
+		{`(= 1 1 2)`, false},
+	}
+	it, err := NewInterpreter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		v, err := it.EvalString(tt.source)
+		if err != nil || !v.IsBool() || v.AsBool() != tt.want {
+			t.Errorf("%s => %s, %v, expected %v", tt.source, PrStr(v, true), err, tt.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(compare 1 2)`, "-1"},
+		{`(compare 2 2)`, "0"},
+		{`(compare "b" "a")`, "1"},
+		{`(compare :a :b)`, "-1"},
+		{`(compare [1 2] [1 3])`, "-1"},
+		{`(compare [1 2] [1 2 0])`, "-1"},
+		{`(compare (list 2) [1 9])`, "1"},
+		{`(< 1 2 3)`, "true"},
+		{`(< 1 3 2)`, "false"},
+		{`(<= "a" "a" "b")`, "true"},
+		{`(> 3 2 1)`, "true"},
+		{`(>= :b :a)`, "true"},
+		{`(sort [3 1 2])`, "[1 2 3]"},
+		{`(sort (list "b" "a"))`, `("a" "b")`},
+		{`(sort [[2 1] [1 5] [1]])`, "[[1] [1 5] [2 1]]"},
+		{`(sort (fn (a b) (compare b a)) [1 3 2])`, "[3 2 1]"},
+	}
+	it, err := NewInterpreter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if v, err := it.EvalString(tt.source); err != nil || PrStr(v, true) != tt.want {
+			t.Errorf("%s => %s, %v, expected %s", tt.source, PrStr(v, true), err, tt.want)
+		}
+	}
+	for _, source := range []string{`(compare 1 "a")`, `(sort [1 "a"])`, `(< :a 1)`, `(compare {:a 1} {:a 1})`} {
+		if _, err := it.EvalString(source); err == nil || !strings.Contains(strings.ToLower(err.Error()), "compar") {
+			t.Errorf("%s => %v, expected an error", source, err)
+		}
+	}
+}
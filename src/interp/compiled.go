@@ -0,0 +1,145 @@
+package interp
+
+import (
+	"fmt"
+)
+
+// Support for programs transpiled to Go by smack build, see src/transpile. The
+// generated code evaluates the forms it compiles itself and leaves the rest to Eval,
+// so both share the same environments, fns and error messages.
+
+// The body of a fn compiled to Go, env binds its params to the arguments
+type NativeBody func(env *Env) (Value, error)
+
+// A compiled top level form
+type CompiledForm func(env *Env) (Value, error)
+
+// (fn "doc"? params body) with body compiled to Go. forms are the forms following
+// fn, they give the fn its params and metadata exactly as Eval would
+func NewCompiledFn(forms []Value, env *Env, form_meta *PersistentHashMap, body NativeBody) (Value, error) {
+	return make_user_fn(forms, env, form_meta, body)
+}
+
+// Binds sym to value in env as (def sym doc? value) does. doc is NoValue() when
+// there is none. Returns value
+func Define(env *Env, sym Value, doc Value, value Value) Value {
+	name := sym.AsSymbol().Name()
+	meta := sym.meta
+	if doc.IsString() {
+		meta = merge_meta(meta, EmptyHashMap().Assoc(NewAtom(":doc"), doc))
+	}
+	if value.IsFn() {
+		if len(value.AsFn().name) == 0 {
+			value.AsFn().name = name
+		}
+		if meta != nil {
+			value.meta = merge_meta(value.meta, meta)
+		}
+		meta = value.meta
+	}
+	env.Set(name, value)
+	env.SetMeta(name, meta)
+	env.SetDynamic(name, is_dynamic_meta(meta))
+	return value
+}
+
+// Calls f with already evaluated args, as Eval calls the head of a list, outside
+// of any binding
+func Call(f Value, args []Value) (Value, error) {
+	return call(f, no_bindings, args)
+}
+
+// Calls f as Call does, with the dynamic bindings in effect in env
+func CallIn(env *Env, f Value, args []Value) (Value, error) {
+	return call(f, env.dyn_frame(), args)
+}
+
+func call(f Value, frame *dyn_frame, args []Value) (Value, error) {
+	switch f.Type() {
+	case VAL_FN:
+		return apply_fn(f.AsFn(), frame, args)
+	case VAL_ATOM, VAL_HASHMAP, VAL_ARRAY, VAL_SET:
+		return invoke_collection(f, args)
+	default:
+		return NoValue(), fmt.Errorf("Unable to call symbol %s as function: Unknown symbol or not a function", f)
+	}
+}
+
+// Calls f as CallIn does from the tail position of a compiled fn body. Calls of
+// user fns are returned as a tail_call, for the caller of the body to make once
+// the body has returned
+func TailCall(env *Env, f Value, args []Value) (Value, error) {
+	if f.IsFn() && !f.AsFn().IsCoreFn() && !f.AsFn().is_macro {
+		return NoValue(), &tail_call{f: f.AsFn(), frame: env.dyn_frame(), args: args}
+	}
+	return CallIn(env, f, args)
+}
+
+// Returned as the error of a compiled fn body by TailCall, so a compiled fn calling
+// itself in tail position runs in constant stack like an interpreted one does
+type tail_call struct {
+	f     *SmackFn
+	frame *dyn_frame
+	args  []Value
+}
+
+func (tc *tail_call) Error() string {
+	return "fn => Tail call escaped outside of a function"
+}
+
+// The env the body of the called fn is evaluated in
+func (tc *tail_call) call_env() *Env {
+	return tc.f.call_env(tc.frame, tc.args)
+}
+
+// Runs the compiled fn body native in env, then the compiled bodies of the fns
+// it tail calls in turn. A tail call of an interpreted fn is returned for the
+// caller to evaluate the fn's body
+func run_native(native NativeBody, env *Env) (Value, *tail_call, error) {
+	for {
+		res, err := native(env)
+		tc, ok := err.(*tail_call)
+		if !ok {
+			return res, nil, err
+		}
+		if tc.f.native == nil {
+			return NoValue(), tc, nil
+		}
+		native = tc.f.native
+		env = tc.call_env()
+	}
+}
+
+// Runs the compiled fn body native in env along with every tail call it makes
+func call_native(native NativeBody, env *Env) (Value, error) {
+	res, tc, err := run_native(native, env)
+	if tc != nil {
+		return Eval(tc.f.body, tc.call_env())
+	}
+	return res, err
+}
+
+func IsMacro(v Value) bool {
+	return v.IsFn() && v.AsFn().is_macro
+}
+
+// Runs the compiled forms of the file at path as LoadFile would evaluate them,
+// requires and load-file resolve relative to path
+func RunCompiled(path string, env *Env, forms []CompiledForm) (Value, error) {
+	if env.ns == nil {
+		return NoValue(), fmt.Errorf("load => No namespace registry in this environment")
+	}
+	path = env.ns.registry.sys.abs(path)
+	return env.ns.registry.run_file(path, "", func(env *Env) (Value, error) {
+		res := NewNilList()
+		for _, form := range forms {
+			env = top_level_env(env)
+			evaled, err := form(env)
+			if err != nil {
+				return NoValue(), err
+			}
+			res = evaled
+		}
+		return res, nil
+	})
+}
@@ -0,0 +1,399 @@
+package interp
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// Conversions between Values and Go values of a given reflect.Type, used to call
+// Go funcs from scripts (see RegisterFunc):
+//
+//	Number          <=> ints, uints and floats, ints must be whole and in range
+//	String          <=> string, keywords convert to their name without the :
+//	Boolean         <=> bool
+//	List/Array/Set   => slices and arrays, slices and arrays => Array
+//	HashMap/Record  <=> maps, keys and values converted
+//	HashMap/Record  <=> structs, exported fields keyed by :FieldName or their smack
+//	                    tag, see ToValue
+//	nil             <=> nil pointers, slices, maps and interfaces
+//	fn              <=> funcs, see go_func and new_go_fn
+//	Error            => error
+//	any             <=> Value as is, or any Value converted to its natural Go type
+//	Go object       <=> pointers to structs, structs with methods or unexported
+//	                    fields and any other Go value, see NewObject
+//
+// A Value param or result is passed through unconverted, a Go object is passed as
+// is wherever its type fits.
+
+var value_type = reflect.TypeOf(Value{})
+var error_type = reflect.TypeOf((*error)(nil)).Elem()
+
+// Converts v to a Go value of type t
+func to_go(v Value, t reflect.Type) (reflect.Value, error) {
+	if t == value_type {
+		return reflect.ValueOf(v), nil
+	}
+	if v.IsObject() {
+		if rv, ok := v.AsObject().assignable_to(t); ok {
+			return rv, nil
+		}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		if !v.IsBool() {
+			return reflect.Value{}, convert_error(v, t)
+		}
+		return reflect.ValueOf(v.AsBool()).Convert(t), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !v.IsNumber() {
+			return reflect.Value{}, convert_error(v, t)
+		}
+		// n is checked before converting, int64(n) is undefined when out of range
+		n := v.AsNumber()
+		limit := math.Ldexp(1, t.Bits()-1)
+		if n != math.Trunc(n) || n < -limit || n >= limit {
+			return reflect.Value{}, fmt.Errorf("Expected a whole number in the range of %s, got: %s", t, PrStr(v, true))
+		}
+		res := reflect.New(t).Elem()
+		res.SetInt(int64(n))
+		return res, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if !v.IsNumber() {
+			return reflect.Value{}, convert_error(v, t)
+		}
+		n := v.AsNumber()
+		if n != math.Trunc(n) || n < 0 || n >= math.Ldexp(1, t.Bits()) {
+			return reflect.Value{}, fmt.Errorf("Expected a whole number in the range of %s, got: %s", t, PrStr(v, true))
+		}
+		res := reflect.New(t).Elem()
+		res.SetUint(uint64(n))
+		return res, nil
+
+	case reflect.Float32, reflect.Float64:
+		if !v.IsNumber() {
+			return reflect.Value{}, convert_error(v, t)
+		}
+		return reflect.ValueOf(v.AsNumber()).Convert(t), nil
+
+	case reflect.String:
+		switch {
+		case v.IsString():
+			return reflect.ValueOf(v.AsString()).Convert(t), nil
+		case v.IsAtom():
+			return reflect.ValueOf(strings.TrimPrefix(v.AsAtom().Name(), ":")).Convert(t), nil
+		default:
+			return reflect.Value{}, convert_error(v, t)
+		}
+
+	case reflect.Slice:
+		if is_nil(v) {
+			return reflect.Zero(t), nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 && v.IsString() {
+			return reflect.ValueOf([]byte(v.AsString())).Convert(t), nil
+		}
+		elems, ok := go_elements(v)
+		if !ok {
+			return reflect.Value{}, convert_error(v, t)
+		}
+		res := reflect.MakeSlice(t, len(elems), len(elems))
+		for i, e := range elems {
+			ev, err := to_go(e, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("Element %d: %s", i, err)
+			}
+			res.Index(i).Set(ev)
+		}
+		return res, nil
+
+	case reflect.Array:
+		elems, ok := go_elements(v)
+		if !ok {
+			return reflect.Value{}, convert_error(v, t)
+		}
+		if len(elems) != t.Len() {
+			return reflect.Value{}, fmt.Errorf("Expected %d elements for %s, got: %d", t.Len(), t, len(elems))
+		}
+		res := reflect.New(t).Elem()
+		for i, e := range elems {
+			ev, err := to_go(e, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("Element %d: %s", i, err)
+			}
+			res.Index(i).Set(ev)
+		}
+		return res, nil
+
+	case reflect.Map:
+		if is_nil(v) {
+			return reflect.Zero(t), nil
+		}
+		if !is_map_like(v) {
+			return reflect.Value{}, convert_error(v, t)
+		}
+		res := reflect.MakeMap(t)
+		var err error
+		each_entry(v, func(key Value, val Value) bool {
+			var kv, vv reflect.Value
+			if kv, err = to_go(key, t.Key()); err != nil {
+				err = fmt.Errorf("Key %s: %s", PrStr(key, true), err)
+				return false
+			}
+			if vv, err = to_go(val, t.Elem()); err != nil {
+				err = fmt.Errorf("Value of %s: %s", PrStr(key, true), err)
+				return false
+			}
+			res.SetMapIndex(kv, vv)
+			return true
+		})
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return res, nil
+
+	case reflect.Struct:
+		if !is_map_like(v) {
+			return reflect.Value{}, convert_error(v, t)
+		}
+		res := reflect.New(t).Elem()
+		for _, f := range struct_fields(t) {
+			val, ok := field_lookup(v, f)
+			if !ok {
+				continue
+			}
+			field := res.FieldByIndex(f.index)
+			fv, err := to_go(val, field.Type())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("Field %s: %s", f.name, err)
+			}
+			field.Set(fv)
+		}
+		return res, nil
+
+	case reflect.Pointer:
+		if is_nil(v) {
+			return reflect.Zero(t), nil
+		}
+		ev, err := to_go(v, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(ev)
+		return ptr, nil
+
+	case reflect.Func:
+		if !v.IsCallable() {
+			return reflect.Value{}, convert_error(v, t)
+		}
+		return go_func(v, t), nil
+
+	case reflect.Interface:
+		if is_nil(v) {
+			return reflect.Zero(t), nil
+		}
+		if v.IsError() && error_type.AssignableTo(t) && v.val != nil {
+			return reflect.ValueOf(v.AsError()).Convert(t), nil
+		}
+		if t.NumMethod() != 0 {
+			return reflect.Value{}, convert_error(v, t)
+		}
+		natural, err := natural_go(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if natural == nil {
+			return reflect.Zero(t), nil
+		}
+		return reflect.ValueOf(natural), nil
+
+	default:
+		return reflect.Value{}, fmt.Errorf("Values can not be converted to %s", t)
+	}
+}
+
+func convert_error(v Value, t reflect.Type) error {
+	return fmt.Errorf("Expected a value convertible to %s, got: %s", t, v.TypeString())
+}
+
+func go_elements(v Value) ([]Value, bool) {
+	switch {
+	case v.IsListLike():
+		return v.AsList(), true
+	case v.IsSet():
+		return v.AsSet().Slice(), true
+	default:
+		return nil, false
+	}
+}
+
+func map_lookup(m Value, key Value) (Value, bool) {
+	if m.IsRecord() {
+		return m.AsRecord().Get(key)
+	}
+	return m.AsHashMap().Get(key)
+}
+
+// The Go value v converts to as an any: float64, string, bool, []any,
+// map[any]any, funcs stay Values. nil for nil
+func natural_go(v Value) (any, error) {
+	switch {
+	case is_nil(v):
+		return nil, nil
+	case v.IsNumber():
+		return v.AsNumber(), nil
+	case v.IsString():
+		return v.AsString(), nil
+	case v.IsBool():
+		return v.AsBool(), nil
+	case v.IsAtom():
+		return strings.TrimPrefix(v.AsAtom().Name(), ":"), nil
+	case v.IsObject():
+		return v.AsObject().Interface(), nil
+	case v.IsListLike() || v.IsSet():
+		elems, _ := go_elements(v)
+		res := make([]any, len(elems))
+		for i, e := range elems {
+			n, err := natural_go(e)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = n
+		}
+		return res, nil
+	case is_map_like(v):
+		res := make(map[any]any)
+		var err error
+		each_entry(v, func(key Value, val Value) bool {
+			var k, n any
+			if k, err = natural_go(key); err != nil {
+				return false
+			}
+			if n, err = natural_go(val); err != nil {
+				return false
+			}
+			if k != nil && !reflect.TypeOf(k).Comparable() {
+				err = fmt.Errorf("Key %s can not be a Go map key", PrStr(key, true))
+				return false
+			}
+			res[k] = n
+			return true
+		})
+		return res, err
+	default:
+		return v, nil
+	}
+}
+
+// Converts the Go value rv to a Value
+func from_go(rv reflect.Value) (Value, error) {
+	if !rv.IsValid() {
+		return NewNilList(), nil
+	}
+	if rv.Type() == value_type {
+		return rv.Interface().(Value), nil
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		return NewBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewNumber(float64(rv.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return NewNumber(float64(rv.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return NewNumber(rv.Float()), nil
+	case reflect.String:
+		return NewString(rv.String()), nil
+
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return NewNilList(), nil
+		}
+		vec := EmptyVector()
+		for i := 0; i < rv.Len(); i++ {
+			e, err := from_go(rv.Index(i))
+			if err != nil {
+				return NoValue(), fmt.Errorf("Element %d: %s", i, err)
+			}
+			vec = vec.Conj(e)
+		}
+		return NewVector(vec), nil
+
+	case reflect.Map:
+		if rv.IsNil() {
+			return NewNilList(), nil
+		}
+		m := EmptyHashMap()
+		iter := rv.MapRange()
+		for iter.Next() {
+			k, err := from_go(iter.Key())
+			if err != nil {
+				return NoValue(), err
+			}
+			v, err := from_go(iter.Value())
+			if err != nil {
+				return NoValue(), fmt.Errorf("Value of %s: %s", PrStr(k, true), err)
+			}
+			m = m.Assoc(k, v)
+		}
+		return NewHashMap(m), nil
+
+	case reflect.Struct:
+		t := rv.Type()
+		if !plain_struct(t) {
+			return new_object(rv), nil
+		}
+		m := EmptyHashMap()
+		for _, f := range struct_fields(t) {
+			fv := rv.FieldByIndex(f.index)
+			if f.omitempty && fv.IsZero() {
+				continue
+			}
+			v, err := from_go(fv)
+			if err != nil {
+				return NoValue(), fmt.Errorf("Field %s: %s", f.name, err)
+			}
+			m = m.Assoc(f.key, v)
+		}
+		return NewHashMap(m), nil
+
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return NewNilList(), nil
+		}
+		if rv.Type().Implements(error_type) {
+			return NewError(rv.Interface().(error)), nil
+		}
+		if rv.Kind() == reflect.Pointer && rv.Elem().Kind() == reflect.Struct {
+			return new_object(rv), nil
+		}
+		return from_go(rv.Elem())
+
+	case reflect.Func:
+		if rv.IsNil() {
+			return NewNilList(), nil
+		}
+		return new_go_fn("", rv), nil
+
+	default:
+		return new_object(rv), nil
+	}
+}
+
+// Structs whose fields are all exported and that have no methods, which convert to
+// a hashmap without losing anything
+func plain_struct(t reflect.Type) bool {
+	if reflect.PointerTo(t).NumMethod() > 0 {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package interp
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestToGoNumbers(t *testing.T) {
+	tests := []struct {
+		n  float64
+		t  reflect.Type
+		ok bool
+	}{
+		{127, reflect.TypeOf(int8(0)), true},
+		{-128, reflect.TypeOf(int8(0)), true},
+		{128, reflect.TypeOf(int8(0)), false},
+		{1.5, reflect.TypeOf(0), false},
+		{-9223372036854775808, reflect.TypeOf(int64(0)), true},
+		{9223372036854775807, reflect.TypeOf(int64(0)), false},
+		{1e19, reflect.TypeOf(int64(0)), false},
+		{255, reflect.TypeOf(uint8(0)), true},
+		{256, reflect.TypeOf(uint8(0)), false},
+		{-1, reflect.TypeOf(uint(0)), false},
+		{1e19, reflect.TypeOf(uint64(0)), true},
+		{18446744073709551615, reflect.TypeOf(uint64(0)), false},
+	}
+	for _, tt := range tests {
+		rv, err := to_go(NewNumber(tt.n), tt.t)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("to_go(%g, %s) => %v, %v", tt.n, tt.t, rv, err)
+			continue
+		}
+		if tt.ok && reflect.ValueOf(tt.n).Convert(tt.t).Interface() != rv.Interface() {
+			t.Errorf("to_go(%g, %s) => %v", tt.n, tt.t, rv)
+		}
+	}
+}
+
+func TestGoFuncs(t *testing.T) {
+	it, err := NewInterpreter(WithGlobals(map[string]any{
+		"id": func(n int64) int64 { return n },
+		"sum": func(ns ...int) int {
+			t := 0
+			for _, n := range ns {
+				t += n
+			}
+			return t
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(id 5)`, "5"},
+		{`(sum 1 2 3)`, "6"},
+		{`(sum)`, "0"},
+	}
+	for _, tt := range tests {
+		if v, err := it.EvalString(tt.source); err != nil || PrStr(v, true) != tt.want {
+			t.Errorf("%s => %s, %v, expected %s", tt.source, PrStr(v, true), err, tt.want)
+		}
+	}
+	if _, err := it.EvalString(`(id)`); err == nil || err.Error() != "id => Invalid arity. Expected 1, got: 0" {
+		t.Errorf("(id) => %v", err)
+	}
+	if _, err := it.EvalString(`(id 1e19)`); err == nil {
+		t.Errorf("(id 1e19) succeeded")
+	}
+}
+
+func TestRegisterFuncNilInterfaces(t *testing.T) {
+	it, err := NewInterpreter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	describe := func(err error, s fmt.Stringer) string {
+		return fmt.Sprintf("%v %v", err == nil, s == nil)
+	}
+	if err := RegisterFunc(it.Env(), "describe", describe); err != nil {
+		t.Fatal(err)
+	}
+	v, err := it.EvalString(`(describe nil nil)`)
+	if err != nil || PrStr(v, true) != `"true true"` {
+		t.Errorf("(describe nil nil) => %s, %v", PrStr(v, true), err)
+	}
+	if _, err := it.EvalString(`(describe 1 nil)`); err == nil {
+		t.Errorf("(describe 1 nil) succeeded")
+	}
+}
@@ -2,100 +2,312 @@ package interp
 
 import (
 	"fmt"
-	"os"
+	"io"
+	"sort"
 	"strings"
+	"unicode/utf8"
 )
 
-// TODO :: Implement Mutable Types => Channel & Ref
-
-// Global interned atoms
-var smack_atoms = make(map[string]Atom, 32)
+// TODO :: Implement Mutable Types => Channel
 
+// Returns the root env of the user namespace, the core functions live in the
+// smack.core namespace every other namespace falls back to. Scripts use the
+// process' streams and files, see NewInterpreter to configure them.
 func NewCoreEnv() *Env {
+	return new_core_env(os_system())
+}
+
+func new_core_env(sys *system) *Env {
 	env := NewEnv(nil, nil, nil)
 
 	// Operators
-	env.Set("+", new_core_fn(eval_add))
-	env.Set("-", new_core_fn(eval_sub))
-	env.Set("*", new_core_fn(eval_mul))
-	env.Set("/", new_core_fn(eval_div))
-	env.Set("=", new_core_fn(eval_isequal))
-	env.Set("<", new_core_fn(eval_lt))
-	env.Set("<=", new_core_fn(eval_lte))
-	env.Set(">", new_core_fn(eval_gt))
-	env.Set(">=", new_core_fn(eval_gte))
+	env.Set("+", new_core_fn(eval_add, "((& xs))", "Returns the sum of xs."))
+	env.Set("-", new_core_fn(eval_sub, "((& xs))", "Subtracts the rest of xs from the first."))
+	env.Set("*", new_core_fn(eval_mul, "((& xs))", "Returns the product of xs."))
+	env.Set("/", new_core_fn(eval_div, "((& xs))", "Divides the first of xs by the rest."))
+	env.Set("=", new_core_fn(eval_isequal, "((x & more))", "Returns true if all arguments are structurally equal. Lists and arrays with equal elements are equal."))
+	env.Set("<", new_core_fn(eval_lt, "((x & more))", "Returns true if the arguments are in strictly increasing order, see compare."))
+	env.Set("<=", new_core_fn(eval_lte, "((x & more))", "Returns true if the arguments are in non-decreasing order, see compare."))
+	env.Set(">", new_core_fn(eval_gt, "((x & more))", "Returns true if the arguments are in strictly decreasing order, see compare."))
+	env.Set(">=", new_core_fn(eval_gte, "((x & more))", "Returns true if the arguments are in non-increasing order, see compare."))
+	env.Set("compare", new_core_fn(eval_compare, "((x y))", "Returns -1, 0 or 1 when x is less than, equal to or greater than y. Numbers, strings, keywords, symbols, booleans and sequences can be compared."))
 
 	// Stdio
-	env.Set("println", new_core_fn(eval_println))
+	{
+		println := func(vs ...Value) Value {
+			return eval_println(sys.stdout, vs...)
+		}
+		env.Set("println", new_core_fn(println, "((& xs))", "Prints xs for display separated by spaces, followed by a newline. Returns nil."))
 
-	// Stdlib
-	env.Set("list", new_core_fn(eval_listfn))
-	env.Set("list?", new_core_fn(eval_islist))
-	env.Set("empty?", new_core_fn(eval_isempty))
-	env.Set("len", new_core_fn(eval_len))
-	env.Set("err?", new_core_fn(eval_iserror))
-	env.Set("map?", new_core_fn(eval_ismap))
+		prn := func(vs ...Value) Value {
+			return eval_prn(sys.stdout, vs...)
+		}
+		env.Set("prn", new_core_fn(prn, "((& xs))", "Prints xs readably separated by spaces, followed by a newline. Returns nil."))
 
-	env.Set("mget", new_core_fn(eval_mapget))
-	env.Set("mset!", new_core_fn(eval_mapset_mut))
+		pprint := func(vs ...Value) Value {
+			return eval_pprint(sys.stdout, vs...)
+		}
+		env.Set("pprint", new_core_fn(pprint, "((x) (x opts))", "Pretty prints x within a column width. opts is a map of :width, :max-length and :max-depth. Returns nil."))
+
+		read_line := func(vs ...Value) Value {
+			return eval_read_line(sys, vs...)
+		}
+		env.Set("read-line", new_core_fn(read_line, "(())", "Reads a line from stdin. Returns (some line) without the line ending, or none at the end of input."))
+	}
+	env.Set("pr-str", new_core_fn(eval_prstr, "((& xs))", "Returns xs printed readably and separated by spaces as a string. The result can be read back with read-str."))
+	env.Set("str", new_core_fn(eval_str, "((& xs))", "Returns the display form of xs concatenated into a string."))
+
+	// Stdlib
+	env.Set("list", new_core_fn(eval_listfn, "((& xs))", "Returns a new list of xs."))
+	env.Set("list?", new_core_fn(eval_islist, "((x))", "Returns true if x is a list."))
+	env.Set("empty?", new_core_fn(eval_isempty, "((coll))", "Returns true if coll has no elements."))
+	env.Set("len", new_core_fn(eval_len, "((coll))", "Returns the number of elements in a list, array, set, hashmap or record, or of characters in a string. An error for other values."))
+	env.Set("err?", new_core_fn(eval_iserror, "((x))", "Returns true if x is an error or an (err e) Result."))
+	env.Set("map?", new_core_fn(eval_ismap, "((x))", "Returns true if x is a hashmap."))
+
+	env.Set("mget", new_core_fn(eval_mapget, "((m key))", "Returns the value mapped to key in the hashmap m, or nil if key is not present."))
+	env.Set("mset!", new_core_fn(eval_mapset_mut, "((m key val))", "Maps key to val in the hashmap m in place, every holder of m sees the change. Safe across goroutines. Returns val. Prefer assoc."))
+
+	// Stdlib :: Persistent Collections
+	env.Set("vector", new_core_fn(eval_vector, "((& xs))", "Returns a new array of xs."))
+	env.Set("vec", new_core_fn(eval_vec, "((coll))", "Returns an array with the elements of the list or array coll."))
+	env.Set("hash-map", new_core_fn(eval_hashmap, "((& kvs))", "Returns a new hashmap from alternating keys and values."))
+	env.Set("assoc", new_core_fn(eval_assoc, "((coll key val & kvs))", "Returns a new hashmap (or array) with key (or index) mapped to val. coll is unchanged."))
+	env.Set("dissoc", new_core_fn(eval_dissoc, "((m & keys))", "Returns a new hashmap without keys. m is unchanged."))
+	env.Set("conj", new_core_fn(eval_conj, "((coll & xs))", "Returns coll with xs added, at the end of arrays, the front of lists, into sets and as [key val] entries of hashmaps."))
+	env.Set("nth", new_core_fn(eval_nth, "((coll index))", "Returns the element at index in a list or array."))
+	env.Set("contains?", new_core_fn(eval_contains, "((coll key))", "Returns true if key is a key of the hashmap, an element of the set or an index of the list or array coll."))
+
+	// Stdlib :: Sets
+	env.Set("hash-set", new_core_fn(eval_hash_set, "((& xs))", "Returns a new set of xs."))
+	env.Set("set", new_core_fn(eval_set, "((coll))", "Returns a set of the elements of the list or array coll."))
+	env.Set("set?", new_core_fn(eval_isset, "((x))", "Returns true if x is a set."))
+	env.Set("disj", new_core_fn(eval_disj, "((s & xs))", "Returns a new set without xs. s is unchanged."))
+
+	// Stdlib :: Metadata
+	env.Set("meta", new_core_fn(eval_meta, "((obj))", "Returns the metadata map of obj, or nil."))
+	env.Set("with-meta", new_core_fn(eval_with_meta, "((obj m))", "Returns obj with its metadata replaced by the map m. Only collections, symbols and functions can have metadata."))
+	env.Set("vary-meta", new_core_frame_fn(eval_vary_meta, "((obj f & args))", "Returns obj with its metadata set to (f (meta obj) args...)."))
+
+	// Stdlib :: Refs
+	env.Set("ref", new_core_frame_fn(eval_ref, "((x) (x validator))", "Returns a new mutable reference holding x, safe to share between goroutines. validator is called with every new value and must return truthy."))
+	env.Set("deref", new_core_fn(eval_deref, "((ref))", "Returns the current value of ref. @ref is shorthand for (deref ref)."))
+	env.Set("reset!", new_core_frame_fn(eval_reset, "((ref val))", "Sets the value of ref to val regardless of its current value. Returns val."))
+	env.Set("swap!", new_core_frame_fn(eval_swap, "((ref f & args))", "Atomically sets the value of ref to (f current args...), retrying if another goroutine changed it first. f may be called more than once. Returns the new value."))
+	env.Set("compare-and-set!", new_core_frame_fn(eval_compare_and_set, "((ref old new))", "Sets the value of ref to new only if its current value equals old. Returns true if the value was set."))
+	env.Set("set-validator!", new_core_frame_fn(eval_set_validator, "((ref f))", "Sets the validator of ref, nil removes it. The current value must be valid. Returns nil."))
+	env.Set("add-watch", new_core_fn(eval_add_watch, "((ref key f))", "Calls f with (key ref old new) after every change to ref. A watch with the same key is replaced. Returns ref."))
+	env.Set("remove-watch", new_core_fn(eval_remove_watch, "((ref key))", "Removes the watch on ref under key. Returns ref."))
+
+	// Records
+	env.Set("record?", new_core_fn(eval_isrecord, "((x))", "Returns true if x is a record, see defrecord."))
+
+	// Multimethods
+	env.Set("prefer-method", new_core_fn(eval_prefer_method, "((multi x y))", "Prefers the method of multi for dispatch value x over the one for y when both match. Returns multi."))
+	env.Set("remove-method", new_core_fn(eval_remove_method, "((multi dispatch-val))", "Removes the method of multi for dispatch-val. Returns multi."))
+	env.Set("methods", new_core_fn(eval_methods, "((multi))", "Returns a hashmap of the dispatch values of multi to their methods."))
+	env.Set("get-method", new_core_fn(eval_get_method, "((multi dispatch-val))", "Returns the method multi would call for dispatch-val, or nil."))
+
+	// Option/Result
+	env.Set("none", NewNone())
+	env.SetMeta("none", EmptyHashMap().Assoc(NewAtom(":doc"), NewString("The empty Option.")))
+	env.Set("some", new_core_fn(eval_some, "((x))", "Returns the Option (some x)."))
+	env.Set("ok", new_core_fn(eval_ok, "((x))", "Returns the successful Result (ok x)."))
+	env.Set("err", new_core_fn(eval_err, "((e))", "Returns the failed Result (err e)."))
+	env.Set("some?", new_core_fn(eval_issome, "((x))", "Returns true if x is (some v)."))
+	env.Set("none?", new_core_fn(eval_isnone, "((x))", "Returns true if x is none."))
+	env.Set("ok?", new_core_fn(eval_isok, "((x))", "Returns true if x is (ok v)."))
+	env.Set("option?", new_core_fn(eval_isoption, "((x))", "Returns true if x is (some v) or none."))
+	env.Set("result?", new_core_fn(eval_isresult, "((x))", "Returns true if x is (ok v) or (err e)."))
+	env.Set("unwrap", new_core_fn(eval_unwrap, "((x))", "Returns v of (some v) or (ok v), an error for none and (err e)."))
+	env.Set("unwrap-or", new_core_fn(eval_unwrap_or, "((x default))", "Returns v of (some v) or (ok v), default for none and (err e)."))
+	env.Set("unwrap-err", new_core_fn(eval_unwrap_err, "((x))", "Returns e of (err e), an error for other values."))
+	env.Set("parse-num", new_core_fn(eval_parse_num, "((s))", "Parses the string s as a number. Returns (ok n) or (err msg)."))
+
+	// Protocols
+	env.Set("satisfies?", new_core_fn(eval_satisfies, "((protocol x))", "Returns true if protocol is extended to the type of x."))
+	env.Set("extenders", new_core_fn(eval_extenders, "((protocol))", "Returns a sorted list of the names of the types protocol is extended to."))
 
 	// Stdlib :: File IO
-	env.Set("read-str", new_core_fn(eval_read_str))
-	env.Set("slurp", new_core_fn(eval_slurp))
+	{
+		spit := func(vs ...Value) Value {
+			return eval_spit(sys, vs...)
+		}
+		env.Set("spit", new_core_fn(spit, "((filename x))", "Writes the display form of x to filename. Returns (ok nil) or (err msg)."))
+
+		slurp := func(vs ...Value) Value {
+			return eval_slurp(sys, vs...)
+		}
+		env.Set("slurp", new_core_fn(slurp, "((filename))", "Returns (ok contents) with the contents of filename as a string, or (err msg)."))
+	}
 
 	// Stdlib :: List Operations
-	env.Set("cons", new_core_fn(eval_cons))
-	env.Set("concat", new_core_fn(eval_concat))
+	env.Set("cons", new_core_fn(eval_cons, "((x coll))", "Returns a new list of x followed by the elements of coll."))
+	env.Set("concat", new_core_fn(eval_concat, "((& colls))", "Returns a new list of the elements of every coll in order."))
+	env.Set("sort", new_core_frame_fn(eval_sort, "((coll) (comparator coll))", "Returns the elements of coll sorted by compare, or by comparator which returns a number like compare or true when its first argument sorts first."))
 
 	// Stdlib :: Go runtime
-	env.Set("go", new_core_fn(eval_goroutine))
-	env.Set("send!", new_core_fn(eval_send))
-	env.Set("recv!", new_core_fn(eval_recv))
+	env.Set("go", new_core_frame_fn(eval_goroutine, "((f & args))", "Calls f with args in a new goroutine, which starts with the current dynamic bindings. Returns nil."))
+	env.Set("send!", new_core_fn(eval_send, "((ch val))", "Sends val on the channel ch, blocking until it is received. Returns val."))
+	env.Set("recv!", new_core_fn(eval_recv, "((ch))", "Receives a value from the channel ch, blocking until one is sent."))
 
-	// Stdlib :: Macros / Meta
-	env.Set("quasiquot", new_core_fn(eval_quasiquot))
+	// Go interop, see object.go
+	env.Set("object?", new_core_fn(eval_isobject, "((x))", "Returns true if x is a Go object."))
+	env.Set("go-methods", new_core_fn(eval_go_methods, "((obj))", "Returns a sorted list of the signatures of the methods callable on the Go object obj with (.Method obj args...)."))
+	{
+		go_packages := func(vs ...Value) Value {
+			return eval_go_packages(sys, vs...)
+		}
+		env.Set("go-packages", new_core_fn(go_packages, "(())", "Returns a sorted list of the names of the Go packages scripts may refer to as namespaces, see RegisterPackage."))
+	}
+	env.Set("go-fields", new_core_fn(eval_go_fields, "((obj))", "Returns a list of the names and types of the exported fields of the Go object obj, read with (.-Field obj)."))
 
+	namespaces := new_namespaces(env, sys)
 	{
 		eval := func(vs ...Value) Value {
 			ast := vs[0]
-			if evaled, err := Eval(ast, env); err == nil {
+			if evaled, err := Eval(ast, namespaces.Current().env); err == nil {
 				return evaled
 			} else {
 				return NewError(err)
 			}
 		}
-		env.Set("eval", new_core_fn(eval))
+		env.Set("eval", new_core_fn(eval, "((form))", "Evaluates form in the current namespace."))
+
+		macroexpand_fn := func(vs ...Value) Value {
+			if expanded, err := macroexpand(vs[0], namespaces.Current().env); err == nil {
+				return expanded
+			} else {
+				return NewError(err)
+			}
+		}
+		env.Set("macroexpand", new_core_fn(macroexpand_fn, "((form))", "Expands form until its head is no longer a macro."))
+
+		apropos := func(vs ...Value) Value {
+			return eval_apropos(namespaces.Current().env, vs...)
+		}
+		env.Set("apropos", new_core_fn(apropos, "((s))", "Returns a sorted list of the symbols whose names contain the string s."))
+
+		find_doc := func(vs ...Value) Value {
+			return eval_find_doc(namespaces.Current().env, vs...)
+		}
+		env.Set("find-doc", new_core_fn(find_doc, "((pattern))", "Prints the documentation of every name or docstring matching the regular expression pattern. Returns nil."))
 	}
-	return env
-}
 
-func eval_quasiquot(vs ...Value) Value {
-	if len(vs) == 0 {
-		return NewError(fmt.Errorf("Cannot call quasiquot function with no parameters."))
+	// Hierarchy, shared by every namespace
+	{
+		h := namespaces.hierarchy
+		derive := func(vs ...Value) Value {
+			return eval_derive(h, vs...)
+		}
+		env.Set("derive", new_core_fn(derive, "((child parent))", "Derives the tag child from parent, so that (isa? child parent) is true. Returns nil."))
+
+		underive := func(vs ...Value) Value {
+			return eval_underive(h, vs...)
+		}
+		env.Set("underive", new_core_fn(underive, "((child parent))", "Removes the derivation of child from parent. Returns nil."))
+
+		isa := func(vs ...Value) Value {
+			return eval_isa(h, vs...)
+		}
+		env.Set("isa?", new_core_fn(isa, "((child parent))", "Returns true if child equals parent or was derived from it, directly or through its ancestors. Arrays isa? arrays of the same length element by element."))
+
+		parents := func(vs ...Value) Value {
+			return eval_parents(h, vs...)
+		}
+		env.Set("parents", new_core_fn(parents, "((tag))", "Returns the set of tags tag was directly derived from."))
+
+		ancestors := func(vs ...Value) Value {
+			return eval_ancestors(h, vs...)
+		}
+		env.Set("ancestors", new_core_fn(ancestors, "((tag))", "Returns the set of every tag tag was derived from, directly or indirectly."))
 	}
 
-	ast := vs[0]
-	if ast.IsList() {
+	// Namespaces
+	{
+		in_ns := func(vs ...Value) Value {
+			return eval_in_ns(namespaces, vs...)
+		}
+		env.Set("in-ns", new_core_fn(in_ns, "((name))", "Switches to the namespace called name, creating it if needed. Returns name."))
+
+		require := func(vs ...Value) Value {
+			return eval_require(namespaces, vs...)
+		}
+		env.Set("require", new_core_fn(require, "((& specs))", "Makes namespaces available to the current one, loading them from the search path (SMACK_PATH) once. A spec is a namespace symbol or [ns :as alias :refer [names]], :refer :all refers every name. :reload loads them again."))
+
+		load_file := func(vs ...Value) Value {
+			return eval_load_file(namespaces, vs...)
+		}
+		env.Set("load-file", new_core_fn(load_file, "((path))", "Evaluates every form of the file at path, relative to the file being loaded. Returns the value of the last form."))
+
+		alias := func(vs ...Value) Value {
+			return eval_alias(namespaces, vs...)
+		}
+		env.Set("alias", new_core_fn(alias, "((alias ns))", "Makes alias/name resolve in the namespace ns from the current namespace. Returns nil."))
+
+		ns_name := func(vs ...Value) Value {
+			return NewSymbol(Symbol(namespaces.Current().name))
+		}
+		env.Set("ns-name", new_core_fn(ns_name, "(())", "Returns the name of the current namespace as a symbol."))
+
+		read_str := func(vs ...Value) Value {
+			return eval_read_str(namespaces, vs...)
+		}
+		env.Set("read-str", new_core_fn(read_str, "((s))", "Reads the first form in the string s without evaluating it. A record printed as #Name{...} is read as a record of the type Name of the current namespace. Returns (ok form) or (err msg)."))
+
+		all_ns := func(vs ...Value) Value {
+			return eval_all_ns(namespaces, vs...)
+		}
+		env.Set("all-ns", new_core_fn(all_ns, "(())", "Returns a sorted list of the names of every namespace."))
+	}
+
+	for name, v := range env.data {
+		if v.IsFn() {
+			v.AsFn().name = name
+			env.SetMeta(name, v.meta)
+		}
+	}
+	return namespaces.Current().env
+}
+
+// Expands a quasiquoted form into the cons/concat calls that build it
+func quasiquot(ast Value) Value {
+	switch ast.Type() {
+	case VAL_LIST:
 		list := ast.AsList()
-		if len(list) == 0 {
-			return NewError(fmt.Errorf("Cannot call quasiquot function with no parameters."))
-		}
-		head := list[0]
-		if head.IsSymbol() {
-			h := head.AsSymbol().Name()
-			if h == "unquot" {
-				return list[1]
-			}
-		} else {
-			res := make([]Value, 0)
-			for i := len(list) - 1; i >= 0; i-- {
-				elt := list[i]
-				if l, err := elt.TryList(); err == nil {
+		if len(list) > 1 && is_symbol_named(list[0], "unquot") {
+			return list[1]
+		}
+		return quasiquot_list(list)
+	case VAL_ARRAY:
+		return NewList([]Value{NewSymbol("vec"), quasiquot_list(ast.AsList())})
+	case VAL_SYMBOL:
+		fallthrough
+	case VAL_HASHMAP:
+		return NewList([]Value{NewSymbol("quot"), ast})
+	default:
+		return ast
+	}
+}
 
-				}
+func quasiquot_list(list []Value) Value {
+	res := NewNilList()
+	for i := len(list) - 1; i >= 0; i-- {
+		elt := list[i]
+		if elt.IsList() {
+			inner := elt.AsList()
+			if len(inner) > 1 && is_symbol_named(inner[0], "splice-unquot") {
+				res = NewList([]Value{NewSymbol("concat"), inner[1], res})
+				continue
 			}
 		}
+		res = NewList([]Value{NewSymbol("cons"), quasiquot(elt), res})
 	}
+	return res
+}
+
+func is_symbol_named(v Value, name string) bool {
+	return v.IsSymbol() && v.AsSymbol().Name() == name
 }
 
 func eval_ismap(vs ...Value) Value {
@@ -109,19 +321,14 @@ func eval_mapget(vs ...Value) Value {
 	if len(vs) < 2 {
 		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
 	}
+	if vs[0].IsRecord() {
+		if v, ok := vs[0].AsRecord().Get(vs[1]); ok {
+			return v
+		}
+		return NewNilList()
+	}
 	if m, err := vs[0].TryHashMap(); err == nil {
-		var key string
-		switch vs[1].Type() {
-		case VAL_SYMBOL:
-			key = vs[1].AsSymbol().Name()
-		case VAL_STRING:
-			key = vs[1].AsString()
-		case VAL_ATOM:
-			key = vs[1].AsAtom().Name()
-		default:
-			return NewError(fmt.Errorf("Invalid type for map key. Expected: symbol|atom|string, got: %T", vs[1].val))
-		}
-		if v, ok := m[key]; ok {
+		if v, ok := m.Get(vs[1]); ok {
 			return v
 		} else {
 			return NewNilList()
@@ -131,31 +338,185 @@ func eval_mapget(vs ...Value) Value {
 	}
 }
 
+// NOTE :: The map nodes themselves are never mutated, mset! swaps the
+// root of the map handle so every holder of this map sees the new entry.
+// Prefer assoc, which leaves the original map untouched.
 func eval_mapset_mut(vs ...Value) Value {
 
 	if len(vs) < 3 {
 		return NewError(fmt.Errorf("Invalid arity. Expected 3, got: %d", len(vs)))
 	}
 	if m, err := vs[0].TryHashMap(); err == nil {
-		var key string
-		switch vs[1].Type() {
-		case VAL_SYMBOL:
-			key = vs[1].AsSymbol().Name()
-		case VAL_STRING:
-			key = vs[1].AsString()
-		case VAL_ATOM:
-			key = vs[1].AsAtom().Name()
-		default:
-			return NewError(fmt.Errorf("Invalid type for map key. Expected: symbol|atom|string, got: %T", vs[1].val))
-		}
 		v := vs[2]
-		m[key] = v
+		m.AssocInPlace(vs[1], v)
 		return v
 	} else {
 		return NewError(err)
 	}
 }
 
+func eval_vector(vs ...Value) Value {
+	return NewArray(vs)
+}
+
+func eval_vec(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	if vs[0].IsArray() {
+		return vs[0]
+	}
+	if list, err := vs[0].TryList(); err == nil {
+		return NewArray(list)
+	} else {
+		return NewError(err)
+	}
+}
+
+func eval_hashmap(vs ...Value) Value {
+	if len(vs)%2 != 0 {
+		return NewError(fmt.Errorf("hash-map expects an even number of arguments, got: %d", len(vs)))
+	}
+	m := EmptyHashMap()
+	for i := 1; i < len(vs); i = i + 2 {
+		m = m.Assoc(vs[i-1], vs[i])
+	}
+	return NewHashMap(m)
+}
+
+func eval_assoc(vs ...Value) Value {
+	if len(vs) < 3 || len(vs)%2 == 0 {
+		return NewError(fmt.Errorf("Invalid arity. Expected coll followed by key value pairs, got: %d arguments", len(vs)))
+	}
+	coll := vs[0]
+	switch coll.Type() {
+	case VAL_HASHMAP:
+		m := coll.AsHashMap()
+		for i := 2; i < len(vs); i = i + 2 {
+			m = m.Assoc(vs[i-1], vs[i])
+		}
+		return NewHashMap(m)
+	case VAL_RECORD:
+		r := coll.AsRecord()
+		for i := 2; i < len(vs); i = i + 2 {
+			r = r.Assoc(vs[i-1], vs[i])
+		}
+		res := NewValue(VAL_RECORD, r)
+		res.meta = coll.meta
+		return res
+	case VAL_ARRAY:
+		vec := coll.AsVector()
+		for i := 2; i < len(vs); i = i + 2 {
+			idx, err := vs[i-1].TryNumber()
+			if err != nil {
+				return NewError(err)
+			}
+			next, ok := vec.Assoc(int(idx), vs[i])
+			if !ok {
+				return NewError(fmt.Errorf("assoc => Index %d out of bounds for array of length %d", int(idx), vec.Len()))
+			}
+			vec = next
+		}
+		return NewVector(vec)
+	default:
+		return NewError(fmt.Errorf("assoc => Expected HashMap, Record or Array, got: %s", coll.TypeString()))
+	}
+}
+
+func eval_dissoc(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected at least 1, got 0"))
+	}
+	if vs[0].IsRecord() {
+		res := vs[0]
+		for i, k := range vs[1:] {
+			if !res.IsRecord() {
+				return eval_dissoc(append([]Value{res}, vs[1+i:]...)...)
+			}
+			res = res.AsRecord().Dissoc(k)
+		}
+		return res
+	}
+	if m, err := vs[0].TryHashMap(); err == nil {
+		for _, k := range vs[1:] {
+			m = m.Dissoc(k)
+		}
+		return NewHashMap(m)
+	} else {
+		return NewError(err)
+	}
+}
+
+// conj adds to the end of arrays and the front of lists
+func eval_conj(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected at least 1, got 0"))
+	}
+	coll := vs[0]
+	switch coll.Type() {
+	case VAL_ARRAY:
+		vec := coll.AsVector()
+		for _, v := range vs[1:] {
+			vec = vec.Conj(v)
+		}
+		return NewVector(vec)
+	case VAL_LIST:
+		list := coll.AsList()
+		new_list := make([]Value, 0, len(list)+len(vs)-1)
+		for i := len(vs) - 1; i > 0; i-- {
+			new_list = append(new_list, vs[i])
+		}
+		new_list = append(new_list, list...)
+		return NewList(new_list)
+	case VAL_SET:
+		set := coll.AsSet()
+		for _, v := range vs[1:] {
+			set = set.Conj(v)
+		}
+		return NewSet(set)
+	case VAL_HASHMAP:
+		// entries are conj'd as [key value] arrays
+		m := coll.AsHashMap()
+		for _, v := range vs[1:] {
+			pair, err := v.TryVector()
+			if err != nil || pair.Len() != 2 {
+				return NewError(fmt.Errorf("conj => Expected [key value] array to add to HashMap, got: %s", v.TypeString()))
+			}
+			key, _ := pair.Nth(0)
+			val, _ := pair.Nth(1)
+			m = m.Assoc(key, val)
+		}
+		return NewHashMap(m)
+	default:
+		return NewError(fmt.Errorf("conj => Expected Array, List, Set or HashMap, got: %s", coll.TypeString()))
+	}
+}
+
+func eval_nth(vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
+	}
+	idx, err := vs[1].TryNumber()
+	if err != nil {
+		return NewError(err)
+	}
+	i := int(idx)
+	switch vs[0].Type() {
+	case VAL_ARRAY:
+		if v, ok := vs[0].AsVector().Nth(i); ok {
+			return v
+		}
+	case VAL_LIST:
+		list := vs[0].AsList()
+		if i >= 0 && i < len(list) {
+			return list[i]
+		}
+	default:
+		return NewError(fmt.Errorf("nth => Expected List or Array, got: %s", vs[0].TypeString()))
+	}
+	return NewError(fmt.Errorf("nth => Index %d out of bounds", i))
+}
+
 // func eval_quasiquot(vs ...Value) Value {
 // 	if len(vs) < 1 {
 // 		return NewError(fmt.Errorf("Inavlid number of parameters to quasiquot. Expected 1, got %d", len(vs)))
@@ -206,13 +567,14 @@ func eval_concat(vs ...Value) Value {
 	return NewList(new_list)
 }
 
-func eval_goroutine(vs ...Value) Value {
+func eval_goroutine(frame *dyn_frame, vs ...Value) Value {
 	if fn, err := vs[0].TryFn(); err == nil {
-		if len(vs) > 1 {
-			go fn.Apply(vs[1:]...)
-		} else {
-			go fn.Apply()
-		}
+		args := vs[1:]
+		go func() {
+			if _, err := apply_fn(fn, frame, args); err != nil {
+				fmt.Fprintf(fn.env.system().stderr, "WARN => Failed to eval fn body: %s\n", err)
+			}
+		}()
 		return NewNilList()
 	} else {
 		return NewError(err)
@@ -238,187 +600,211 @@ func eval_send(vs ...Value) Value {
 	}
 }
 
-func eval_slurp(vs ...Value) Value {
-
+// (slurp filename) => (ok contents) or (err msg)
+func eval_slurp(sys *system, vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
 	v := vs[0]
 	if !v.IsString() {
 		e := fmt.Errorf("TYPE_ERROR => Expected String, got: %s", v.TypeString())
-		return NewError(e)
+		return NewErrFrom(e)
 	}
 	filename := v.AsString()
 
-	if buf, err := os.ReadFile(filename); err == nil {
-		return NewString(string(buf))
+	if buf, err := sys.read_file(filename); err == nil {
+		return NewOk(NewString(string(buf)))
 	} else {
-		return NewError(err)
+		return NewErrFrom(err)
 	}
-
 }
 
-func eval_read_str(vs ...Value) Value {
-
+// (read-str s) => (ok form) or (err msg)
+func eval_read_str(r *Namespaces, vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
 	v := vs[0]
 	if !v.IsString() {
 		e := fmt.Errorf("TYPE_ERROR => Expected String, got: %s", v.TypeString())
-		return NewError(e)
+		return NewErrFrom(e)
 	}
 
-	if ast, err := Read(v.AsString()); err == nil {
-		return ast
+	p := new_parser(v.AsString())
+	p.records = func(name string) *RecordType {
+		return find_record_type(r.Current().env, name)
+	}
+	if ast, err := read_first(p); err == nil {
+		return NewOk(ast)
 	} else {
-		return NewError(err)
+		return NewErrFrom(err)
 	}
 }
 
 func eval_iserror(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
 	v := vs[0]
-	return NewBool(v.IsError())
+	return NewBool(v.IsError() || v.IsErr())
 }
 
-func eval_lt(vs ...Value) Value {
-	left := vs[0]
-	right := vs[1]
-	if !left.IsNumber() || !right.IsNumber() {
-		return NewBool(false)
+// Checks every adjacent pair of arguments with pred applied to their Compare result
+func eval_compare_chain(name string, vs []Value, pred func(int) bool) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. %s expects at least 1 argument, got 0", name))
 	}
-	l := left.AsNumber()
-	r := right.AsNumber()
-	return NewBool(l < r)
+	for i := 1; i < len(vs); i++ {
+		c, err := Compare(vs[i-1], vs[i])
+		if err != nil {
+			return NewError(err)
+		}
+		if !pred(c) {
+			return NewBool(false)
+		}
+	}
+	return NewBool(true)
+}
+
+func eval_lt(vs ...Value) Value {
+	return eval_compare_chain("<", vs, func(c int) bool { return c < 0 })
 }
 
 func eval_lte(vs ...Value) Value {
-	left := vs[0]
-	right := vs[1]
-	if !left.IsNumber() || !right.IsNumber() {
-		return NewBool(false)
-	}
-	l := left.AsNumber()
-	r := right.AsNumber()
-	return NewBool(l <= r)
+	return eval_compare_chain("<=", vs, func(c int) bool { return c <= 0 })
 }
 
 func eval_gt(vs ...Value) Value {
-	left := vs[0]
-	right := vs[1]
-	if !left.IsNumber() || !right.IsNumber() {
-		return NewBool(false)
-	}
-	l := left.AsNumber()
-	r := right.AsNumber()
-	return NewBool(l > r)
+	return eval_compare_chain(">", vs, func(c int) bool { return c > 0 })
 }
 
 func eval_gte(vs ...Value) Value {
-	left := vs[0]
-	right := vs[1]
-	if !left.IsNumber() || !right.IsNumber() {
-		return NewBool(false)
-	}
-	l := left.AsNumber()
-	r := right.AsNumber()
-	return NewBool(l >= r)
+	return eval_compare_chain(">=", vs, func(c int) bool { return c >= 0 })
 }
 
 func eval_isequal(vs ...Value) Value {
-	left := vs[0]
-	right := vs[1]
-
-	switch left.Type() {
-	case VAL_NUMBER:
-		if !right.IsNumber() {
-			return NewBool(false)
-		}
-		left := left.AsNumber()
-		right := right.AsNumber()
-		return NewBool(left == right)
-	case VAL_STRING:
-		if !right.IsString() {
-			return NewBool(false)
-		}
-		left := left.AsString()
-		right := right.AsString()
-		return NewBool(left == right)
-	case VAL_BOOLEAN:
-		if !right.IsBool() {
-			return NewBool(false)
-		}
-		left := left.AsBool()
-		right := right.AsBool()
-		return NewBool(left == right)
-	case VAL_ARRAY:
-		if !right.IsArray() {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. = expects at least 1 argument, got 0"))
+	}
+	for i := 1; i < len(vs); i++ {
+		if !vs[i-1].Equals(vs[i]) {
 			return NewBool(false)
 		}
-		fallthrough
-	case VAL_LIST:
-		if !right.IsList() && !right.IsArray() {
-			return NewBool(false)
+	}
+	return NewBool(true)
+}
+
+func eval_compare(vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
+	}
+	if c, err := Compare(vs[0], vs[1]); err == nil {
+		return NewNumber(float64(c))
+	} else {
+		return NewError(err)
+	}
+}
+
+// (sort coll) or (sort comparator coll). The comparator can return a number like
+// compare, or a boolean that is true when the first argument sorts before the second.
+// Arrays sort into arrays, everything else into a list.
+func eval_sort(frame *dyn_frame, vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1 or 2, got 0"))
+	}
+	coll := vs[len(vs)-1]
+	list, err := coll.TryList()
+	if err != nil {
+		return NewError(err)
+	}
+
+	cmp := Compare
+	if len(vs) > 1 {
+		fn, err := vs[0].TryFn()
+		if err != nil {
+			return NewError(err)
 		}
-		left := left.AsList()
-		right := right.AsList()
-		for i, v := range left {
-			lv := v
-			rv := right[i]
-			res := eval_isequal(lv, rv).AsBool()
-			if !res {
-				return NewBool(false)
+		cmp = func(left Value, right Value) (int, error) {
+			res, err := apply_fn(fn, frame, []Value{left, right})
+			if err != nil {
+				return 0, err
+			}
+			switch res.Type() {
+			case VAL_NUMBER:
+				return int(res.AsNumber()), nil
+			case VAL_BOOLEAN:
+				if res.AsBool() {
+					return -1, nil
+				}
+				flipped, err := apply_fn(fn, frame, []Value{right, left})
+				if err != nil {
+					return 0, err
+				}
+				if flipped.IsTruthy() {
+					return 1, nil
+				}
+				return 0, nil
+			case VAL_ERROR:
+				return 0, res.AsError()
+			default:
+				return 0, fmt.Errorf("sort => Comparator must return a Number or Boolean, got: %s", res.TypeString())
 			}
 		}
-		return NewBool(true)
+	}
 
-	case VAL_HASHMAP:
-		if !right.IsHashMap() {
-			return NewBool(false)
+	sorted := make([]Value, len(list))
+	copy(sorted, list)
+	var sort_err error
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sort_err != nil {
+			return false
 		}
-		panic("TODO :: HASHMAPS NOT YET IMPLEMENTED")
-	case VAL_SYMBOL:
-		if !right.IsSymbol() {
-			return NewBool(false)
-		}
-		left := left.AsSymbol()
-		right := right.AsSymbol()
-		return NewBool(left.String() == right.String())
-	case VAL_ATOM:
-		if !right.IsAtom() {
-			return NewBool(false)
+		c, err := cmp(sorted[i], sorted[j])
+		if err != nil {
+			sort_err = err
 		}
-		left := left.AsAtom().String()
-		right := right.AsAtom().String()
-		return NewBool(left == right)
-	case VAL_FN:
-		if !right.IsFn() {
-			return NewBool(false)
-		}
-		left := left.AsFn().String()
-		right := right.AsFn().String()
-		return NewBool(left == right)
+		return c < 0
+	})
+	if sort_err != nil {
+		return NewError(sort_err)
+	}
+
+	if coll.IsArray() {
+		return NewArray(sorted)
 	}
-	return NewBool(false)
+	return NewList(sorted)
 }
 
 func eval_len(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
 	v := vs[0]
 	switch v.Type() {
+	case VAL_STRING:
+		return NewNumber(float64(utf8.RuneCountInString(v.AsString())))
 	case VAL_LIST:
 		fallthrough
 	case VAL_ARRAY:
-
-		count := float64(len(v.AsList()))
-		return NewNumber(count)
+		fallthrough
+	case VAL_SET:
+		fallthrough
+	case VAL_RECORD:
+		fallthrough
 	case VAL_HASHMAP:
-		count := float64(len(v.AsHashMap()))
+		count := float64(v.Len())
 		return NewNumber(count)
 	default:
-
-		return NewNumber(-1.0)
+		return NewError(fmt.Errorf("len => Expected a collection or String, got: %s", v.TypeString()))
 	}
-
 }
 
 func eval_isempty(vs ...Value) Value {
-	count := eval_len(vs...).AsNumber()
-
-	return NewBool(count == 0.0)
+	count := eval_len(vs...)
+	if count.IsError() {
+		return count
+	}
+	return NewBool(count.AsNumber() == 0.0)
 }
 
 func eval_islist(vs ...Value) Value {
@@ -433,18 +819,51 @@ func eval_listfn(vs ...Value) Value {
 	return NewList(list)
 }
 
-func eval_println(vs ...Value) Value {
-
+func join_values(vs []Value, sep string, readably bool) string {
 	sb := strings.Builder{}
-	for _, v := range vs {
-		str := fmt.Sprintf("%s ", v)
-		sb.WriteString(str)
+	for i, v := range vs {
+		if i > 0 {
+			sb.WriteString(sep)
+		}
+		write_value(&sb, v, readably)
 	}
-	fmt.Println(sb.String())
+	return sb.String()
+}
+
+func eval_println(w io.Writer, vs ...Value) Value {
+	fmt.Fprintln(w, join_values(vs, " ", false))
+	return NewNilList()
+}
 
+func eval_prn(w io.Writer, vs ...Value) Value {
+	fmt.Fprintln(w, join_values(vs, " ", true))
+	return NewNilList()
+}
+
+// (pprint x) or (pprint x {:width 80 :max-length 100 :max-depth 12})
+func eval_pprint(w io.Writer, vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1 or 2, got 0"))
+	}
+	opts := DefaultPPrintOptions
+	if len(vs) > 1 {
+		var err error
+		if opts, err = pprint_options(vs[1]); err != nil {
+			return NewError(err)
+		}
+	}
+	fmt.Fprintln(w, PPrint(vs[0], opts))
 	return NewNilList()
 }
 
+func eval_prstr(vs ...Value) Value {
+	return NewString(join_values(vs, " ", true))
+}
+
+func eval_str(vs ...Value) Value {
+	return NewString(join_values(vs, "", false))
+}
+
 func eval_ast(ast Value, env *Env) (Value, error) {
 	switch ast.Type() {
 	case VAL_SYMBOL:
@@ -0,0 +1,113 @@
+package interp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Runtime documentation. Docstrings and arglists live in the :doc and :arglists
+// metadata of a def'd name (see Env.SetMeta), and of fn values themselves.
+
+func doc_meta(name string, env *Env) *PersistentHashMap {
+	if meta := env.FindMeta(name); meta != nil {
+		return meta
+	}
+	if v := env.Find(name); v.IsSome() {
+		return v.meta
+	}
+	return nil
+}
+
+func format_doc(name string, meta *PersistentHashMap, is_macro bool) string {
+	sb := strings.Builder{}
+	sb.WriteString("-------------------------\n")
+	sb.WriteString(name)
+	sb.WriteRune('\n')
+	if meta != nil {
+		if arglists, ok := meta.Get(NewAtom(":arglists")); ok {
+			sb.WriteString(PrStr(arglists, true))
+			sb.WriteRune('\n')
+		}
+	}
+	if is_macro {
+		sb.WriteString("Macro\n")
+	}
+	if meta != nil {
+		if doc, ok := meta.Get(NewAtom(":doc")); ok {
+			sb.WriteString("  ")
+			sb.WriteString(doc.String())
+			sb.WriteRune('\n')
+		}
+	}
+	return sb.String()
+}
+
+func is_macro_value(v Value) bool {
+	return v.IsFn() && v.AsFn().is_macro
+}
+
+// (doc name) special form, name is not evaluated
+func eval_doc(list []Value, env *Env) (Value, error) {
+	if len(list) < 2 || !list[1].IsSymbol() {
+		return NoValue(), fmt.Errorf("doc => Expected a symbol")
+	}
+	name := list[1].AsSymbol().Name()
+	v := env.Find(name)
+	if v.IsNone() {
+		return NoValue(), fmt.Errorf("doc => Unable to resolve symbol: %s", name)
+	}
+	fmt.Fprint(env.system().stdout, format_doc(name, doc_meta(name, env), is_macro_value(v)))
+	return NewNilList(), nil
+}
+
+func eval_apropos(env *Env, vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	s, err := vs[0].TryString()
+	if err != nil {
+		return NewError(err)
+	}
+
+	names := env.Names()
+	sort.Strings(names)
+	res := make([]Value, 0)
+	for _, name := range names {
+		if strings.Contains(name, s) {
+			res = append(res, NewSymbol(Symbol(name)))
+		}
+	}
+	return NewList(res)
+}
+
+func eval_find_doc(env *Env, vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	pattern, err := vs[0].TryString()
+	if err != nil {
+		return NewError(err)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return NewError(err)
+	}
+
+	names := env.Names()
+	sort.Strings(names)
+	for _, name := range names {
+		meta := doc_meta(name, env)
+		doc := ""
+		if meta != nil {
+			if d, ok := meta.Get(NewAtom(":doc")); ok {
+				doc = d.String()
+			}
+		}
+		if re.MatchString(name) || re.MatchString(doc) {
+			fmt.Fprint(env.system().stdout, format_doc(name, meta, is_macro_value(env.Find(name))))
+		}
+	}
+	return NewNilList()
+}
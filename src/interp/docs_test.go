@@ -0,0 +1,72 @@
+package interp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDocs(t *testing.T) {
+	var out bytes.Buffer
+	it, err := NewInterpreter(WithStdout(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := it.EvalString(`
+		(def x "The answer" 42)
+		(def inc (fn "Adds one" (a) (+ a 1)))
+		(defmacro unless "Runs body when c is false" (c body) (list (quot if) c nil body))`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(doc x)`, "x\n  The answer\n"},
+		{`(doc inc)`, "inc\n((a))\n  Adds one\n"},
+		{`(doc unless)`, "unless\n((c body))\nMacro\n  Runs body when c is false\n"},
+		{`(doc mget)`, "mget\n((m key))\n  Returns the value mapped to key in the hashmap m, or nil if key is not present.\n"},
+		{`(find-doc "Adds one")`, "inc\n((a))\n  Adds one\n"},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		if _, err := it.EvalString(tt.source); err != nil {
+			t.Errorf("%s => %s", tt.source, err)
+			continue
+		}
+		if want := "-------------------------\n" + tt.want; out.String() != want {
+			t.Errorf("%s wrote %q, expected %q", tt.source, out.String(), want)
+		}
+	}
+
+	if v, err := it.EvalString(`(apropos "mset")`); err != nil || PrStr(v, true) != "(mset!)" {
+		t.Errorf(`(apropos "mset") => %s, %v`, PrStr(v, true), err)
+	}
+	if v, err := it.EvalString(`(apropos "no such name")`); err != nil || PrStr(v, true) != "()" {
+		t.Errorf(`(apropos "no such name") => %s, %v`, PrStr(v, true), err)
+	}
+	if _, err := it.EvalString(`(doc no-such-name)`); err == nil || !strings.Contains(err.Error(), "Unable to resolve symbol") {
+		t.Errorf("(doc no-such-name) => %v", err)
+	}
+}
+
+// Every fn in NewCoreEnv has a docstring and arglists
+func TestCoreDocs(t *testing.T) {
+	env := NewCoreEnv()
+	for _, name := range env.Names() {
+		if !env.Find(name).IsFn() {
+			continue
+		}
+		meta := doc_meta(name, env)
+		if meta == nil {
+			t.Errorf("%s has no metadata", name)
+			continue
+		}
+		for _, key := range []string{":doc", ":arglists"} {
+			if _, ok := meta.Get(NewAtom(key)); !ok {
+				t.Errorf("%s has no %s", name, key)
+			}
+		}
+	}
+}
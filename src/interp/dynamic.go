@@ -0,0 +1,98 @@
+package interp
+
+import (
+	"fmt"
+)
+
+// Dynamic vars are names def'd with ^:dynamic metadata. (binding [name val ...] body...)
+// rebinds them for the extent of body, visible to everything body calls. Like that
+// of let, body is evaluated in a scope of its own. The frame of bindings is carried
+// by the env body is evaluated in and handed to the env of every fn called from
+// there, so other goroutines and interpreters never see it. Goroutines started with
+// go inherit the bindings in effect when they were started (the frames are
+// immutable, so rebinding in either goroutine never leaks into the other).
+
+// A var is identified by the Env it was def'd in and its name
+type dyn_var struct {
+	env  *Env
+	name string
+}
+
+type dyn_frame struct {
+	bindings map[dyn_var]Value
+	prev     *dyn_frame
+}
+
+// Frame of code outside of any binding
+var no_bindings = &dyn_frame{}
+
+// The bindings in effect in e, those of the innermost env carrying a frame
+func (e *Env) dyn_frame() *dyn_frame {
+	for env := e; env != nil; env = env.outer {
+		if env.frame != nil {
+			return env.frame
+		}
+	}
+	return no_bindings
+}
+
+func lookup_binding(frame *dyn_frame, v dyn_var) (Value, bool) {
+	for f := frame; f != nil; f = f.prev {
+		if val, ok := f.bindings[v]; ok {
+			return val, true
+		}
+	}
+	return NoValue(), false
+}
+
+func is_dynamic_meta(meta *PersistentHashMap) bool {
+	if meta == nil {
+		return false
+	}
+	v, ok := meta.Get(NewAtom(":dynamic"))
+	return ok && v.IsTruthy()
+}
+
+// (binding [name val ...] body...)
+func eval_binding(list []Value, env *Env) (Value, error) {
+	if len(list) < 2 || !list[1].IsListLike() {
+		return NoValue(), fmt.Errorf("binding => Expected a binding list")
+	}
+	pairs := list[1].AsList()
+	if len(pairs)%2 != 0 {
+		return NoValue(), fmt.Errorf("binding => Expected an even number of forms in binding list")
+	}
+
+	bindings := make(map[dyn_var]Value, len(pairs)/2)
+	for i := 1; i < len(pairs); i = i + 2 {
+		sym, err := pairs[i-1].TrySymbol()
+		if err != nil {
+			return NoValue(), err
+		}
+		def_env, name := env.resolve(sym.Name())
+		if def_env == nil {
+			return NoValue(), fmt.Errorf("binding => Unable to resolve symbol: %s", sym.Name())
+		}
+		if !def_env.IsDynamic(name) {
+			return NoValue(), fmt.Errorf("binding => Can't dynamically bind non-dynamic var: %s", name)
+		}
+		if val, err := Eval(pairs[i], env); err == nil {
+			bindings[dyn_var{def_env, name}] = val
+		} else {
+			return NoValue(), err
+		}
+	}
+
+	body_env := NewEnv(env, nil, nil)
+	body_env.frame = &dyn_frame{bindings, env.dyn_frame()}
+
+	res := NewNilList()
+	for _, form := range list[2:] {
+		if val, err := Eval(form, body_env); err == nil {
+			res = val
+		} else {
+			return NoValue(), err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,71 @@
+package interp
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run with -race, bindings are read from several goroutines at once
+func TestBindingGoroutines(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]string)
+	recorded := make(chan string, 8)
+	inside := make(chan struct{})
+	other_done := make(chan struct{})
+	it, err := NewInterpreter(WithGlobals(map[string]any{
+		"record": func(tag string, v Value) {
+			mu.Lock()
+			seen[tag] = PrStr(v, true)
+			mu.Unlock()
+			if tag == "other" {
+				close(other_done)
+			}
+			recorded <- tag
+		},
+		"enter":       func() { close(inside) },
+		"wait-inside": func() { <-inside },
+		"wait-other":  func() { <-other_done },
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = it.EvalString(`
+(def ^:dynamic *x* :root)
+(def seen (fn (tag) (record tag *x*)))
+(go (fn () (do (wait-inside) (seen :other))))
+(binding [*x* :bound]
+  (enter)
+  (wait-other)
+  (go seen :child)
+  (go (fn () (binding [*x* :inner] (seen :inner))))
+  (seen :main))
+(seen :after)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		// started before the binding, reads while it is in effect in another goroutine
+		"other": ":root",
+		"main":  ":bound",
+		// started inside the binding
+		"child": ":bound",
+		"inner": ":inner",
+		"after": ":root",
+	}
+	for range want {
+		select {
+		case <-recorded:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for goroutines, seen %v", seen)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for tag, v := range want {
+		if seen[tag] != v {
+			t.Errorf("*x* in %s = %s, expected %s", tag, seen[tag], v)
+		}
+	}
+}
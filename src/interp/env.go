@@ -5,39 +5,138 @@ import "fmt"
 type Env struct {
 	outer *Env
 	data  SmackMap
+	// Metadata of def'd names (docstrings etc.), created on first use
+	meta map[string]*PersistentHashMap
+	// Names def'd as ^:dynamic, see binding
+	dynamic map[string]bool
+	// Namespace this env belongs to, inherited from outer
+	ns *Namespace
+	// Dynamic bindings in effect, set on the envs of binding bodies and fn calls
+	frame *dyn_frame
 }
 
+// binds after a & symbol collect the rest of exprs as a list. Missing
+// exprs are bound to nil
 func NewEnv(outer *Env, binds []Value, exprs []Value) *Env {
 	data := make(SmackMap)
 
-	if binds != nil && exprs != nil {
+	if binds != nil {
 		for i, bind := range binds {
 			bind := bind.AsSymbol().Name()
-			data[bind] = exprs[i]
+			if bind == "&" {
+				if i+1 < len(binds) {
+					rest := make([]Value, 0)
+					if i < len(exprs) {
+						rest = append(rest, exprs[i:]...)
+					}
+					data[binds[i+1].AsSymbol().Name()] = NewList(rest)
+				}
+				break
+			}
+			if i < len(exprs) {
+				data[bind] = exprs[i]
+			} else {
+				data[bind] = NewNilList()
+			}
 		}
 	}
 
-	return &Env{
-		outer, data,
+	env := &Env{outer: outer, data: data}
+	if outer != nil {
+		env.ns = outer.ns
 	}
+	return env
 }
 
 func (e *Env) Set(key_sym string, val Value) {
 	e.data[key_sym] = val
 }
 
-// NOTE :: Check returned value for v.Type() != VAL_NONE, as result may be nil
-func (e *Env) Find(key_sym string) Value {
-	v := e.data[key_sym]
-	if !v.IsNone() {
-		return v
+func (e *Env) SetDynamic(key_sym string, dynamic bool) {
+	if !dynamic {
+		delete(e.dynamic, key_sym)
+		return
 	}
+	if e.dynamic == nil {
+		e.dynamic = make(map[string]bool)
+	}
+	e.dynamic[key_sym] = true
+}
 
-	if e.outer == nil {
-		return NoValue()
+func (e *Env) IsDynamic(key_sym string) bool {
+	return e.dynamic[key_sym]
+}
+
+// The innermost env binding key_sym and the name it is bound under there, or nil.
+// Names referred into a namespace are found in the namespace they come from, and
+// qualified names (ns/name or alias/name) in the namespace they name.
+func (e *Env) resolve(key_sym string) (*Env, string) {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.data[key_sym]; ok {
+			return env, key_sym
+		}
+		if env.ns != nil && env == env.ns.env {
+			if src := env.ns.refer_of(key_sym); src != nil {
+				if _, ok := src.env.data[key_sym]; ok {
+					return src.env, key_sym
+				}
+			}
+		}
+	}
+	if ns, name, ok := e.resolve_qualified(key_sym); ok {
+		if _, ok := ns.env.data[name]; ok {
+			return ns.env, name
+		}
 	}
+	return nil, ""
+}
 
-	return e.outer.Find(key_sym)
+func (e *Env) SetMeta(key_sym string, meta *PersistentHashMap) {
+	if meta == nil {
+		delete(e.meta, key_sym)
+		return
+	}
+	if e.meta == nil {
+		e.meta = make(map[string]*PersistentHashMap)
+	}
+	e.meta[key_sym] = meta
+}
+
+// Metadata of the innermost binding of key_sym, nil if it has none
+func (e *Env) FindMeta(key_sym string) *PersistentHashMap {
+	if env, name := e.resolve(key_sym); env != nil {
+		return env.meta[name]
+	}
+	return nil
+}
+
+// Every name bound in this env and its outer envs
+func (e *Env) Names() []string {
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for env := e; env != nil; env = env.outer {
+		for name := range env.data {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// NOTE :: Check returned value for v.Type() != VAL_NONE, as result may be nil
+func (e *Env) Find(key_sym string) Value {
+	env, name := e.resolve(key_sym)
+	if env == nil {
+		return NoValue()
+	}
+	if env.dynamic[name] {
+		if bound, ok := lookup_binding(e.dyn_frame(), dyn_var{env, name}); ok {
+			return bound
+		}
+	}
+	return env.data[name]
 }
 
 func (e *Env) Get(key_sym string) (Value, error) {
@@ -0,0 +1,233 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Go funcs registered with RegisterFunc are called like core fns. Arguments are
+// converted to the types of the func's params and its results back to Values (see
+// convert.go):
+//
+//	func(...) T            => T
+//	func(...) (T, error)   => T, or an error when err is not nil
+//	func(...) error        => nil, or an error when err is not nil
+//	func(...)              => nil
+//	func(...) (T, U, ...)  => [T U ...]
+//
+// Variadic funcs take their trailing arguments converted to the element type. A
+// panic in the func is returned as an error.
+
+// Binds name in env to the Go func fn
+func RegisterFunc(env *Env, name string, fn any) error {
+	rv := reflect.ValueOf(fn)
+	if rv.Kind() != reflect.Func || rv.IsNil() {
+		return fmt.Errorf("RegisterFunc => Expected a func for %s, got: %T", name, fn)
+	}
+	f := new_go_fn(name, rv)
+	env.Set(name, f)
+	env.SetMeta(name, f.meta)
+	return nil
+}
+
+// A core fn calling the Go func rv
+func new_go_fn(name string, rv reflect.Value) Value {
+	t := rv.Type()
+	label := name
+	if label == "" {
+		label = "fn"
+	}
+
+	fixed := t.NumIn()
+	if t.IsVariadic() {
+		fixed--
+	}
+	fn := func(vs ...Value) (res Value) {
+		defer func() {
+			if r := recover(); r != nil {
+				res = NewError(fmt.Errorf("%s => panic: %v", label, r))
+			}
+		}()
+		if len(vs) < fixed || (!t.IsVariadic() && len(vs) > fixed) {
+			if t.IsVariadic() {
+				return NewError(fmt.Errorf("%s => Invalid arity. Expected at least %d, got: %d", label, fixed, len(vs)))
+			}
+			return NewError(fmt.Errorf("%s => Invalid arity. Expected %d, got: %d", label, fixed, len(vs)))
+		}
+		args := make([]reflect.Value, len(vs))
+		for i, v := range vs {
+			pt := t.In(min(i, t.NumIn()-1))
+			if t.IsVariadic() && i >= fixed {
+				pt = pt.Elem()
+			}
+			arg, err := to_go(v, pt)
+			if err != nil {
+				return NewError(fmt.Errorf("%s => Argument %d: %s", label, i+1, err))
+			}
+			args[i] = arg
+		}
+		return smack_results(label, t, rv.Call(args))
+	}
+
+	f := new_core_fn(fn, go_arglists(t), fmt.Sprintf("Go %s", t))
+	f.AsFn().name = name
+	return f
+}
+
+// ((int string & float64)) for func(int, string, ...float64)
+func go_arglists(t reflect.Type) string {
+	params := make([]string, 0, t.NumIn())
+	for i := 0; i < t.NumIn(); i++ {
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			params = append(params, "&", go_symbol_name(t.In(i).Elem()))
+		} else {
+			params = append(params, go_symbol_name(t.In(i)))
+		}
+	}
+	return "((" + strings.Join(params, " ") + "))"
+}
+
+// A type name that reads back as a single symbol
+func go_symbol_name(t reflect.Type) string {
+	return strings.NewReplacer(" ", "", "(", "<", ")", ">", "[", "<", "]", ">", "{", "<", "}", ">").Replace(t.String())
+}
+
+// The Value of the results out of a call to a Go func of type t
+func smack_results(name string, t reflect.Type, out []reflect.Value) Value {
+	if n := len(out); n > 0 && t.Out(n-1) == error_type {
+		if !out[n-1].IsNil() {
+			return NewError(out[n-1].Interface().(error))
+		}
+		out = out[:n-1]
+	}
+	switch len(out) {
+	case 0:
+		return NewNilList()
+	case 1:
+		res, err := from_go(out[0])
+		if err != nil {
+			return NewError(fmt.Errorf("%s => Result: %s", name, err))
+		}
+		return res
+	default:
+		vec := EmptyVector()
+		for i, o := range out {
+			res, err := from_go(o)
+			if err != nil {
+				return NewError(fmt.Errorf("%s => Result %d: %s", name, i+1, err))
+			}
+			vec = vec.Conj(res)
+		}
+		return NewVector(vec)
+	}
+}
+
+// Adapts the callable fn to the Go func type F, so Go code can use it as a
+// comparator, handler or predicate:
+//
+//	less, err := interp.MakeFunc[func(a, b string) bool](fn)
+//	check, err := interp.MakeFunc[func(int, string) (bool, error)](fn)
+//
+// Arguments are converted to Values and the result of fn to the results of F as
+// for RegisterFunc, in reverse. When F has a trailing error result, (ok x) is
+// returned as x and (err e) as an error. When calling fn or converting its result
+// fails, F returns the error as its trailing error result, or panics with it if it
+// has none.
+func MakeFunc[F any](fn Value) (F, error) {
+	var f F
+	t := reflect.TypeOf(&f).Elem()
+	if t.Kind() != reflect.Func {
+		return f, fmt.Errorf("MakeFunc => Expected a func type, got: %s", t)
+	}
+	if !fn.IsCallable() {
+		return f, fmt.Errorf("MakeFunc => Expected a callable value, got: %s", fn.TypeString())
+	}
+	reflect.ValueOf(&f).Elem().Set(go_func(fn, t))
+	return f, nil
+}
+
+// A Go func of type t calling the callable Value f, see RegisterFunc for how
+// results are mapped. Errors are returned through a trailing error result, or
+// panic when t has none
+func go_func(f Value, t reflect.Type) reflect.Value {
+	return reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+		args := make([]Value, 0, len(in))
+		for i, a := range in {
+			if t.IsVariadic() && i == len(in)-1 {
+				for j := 0; j < a.Len(); j++ {
+					v, err := from_go(a.Index(j))
+					if err != nil {
+						return go_results(t, NoValue(), err)
+					}
+					args = append(args, v)
+				}
+				continue
+			}
+			v, err := from_go(a)
+			if err != nil {
+				return go_results(t, NoValue(), err)
+			}
+			args = append(args, v)
+		}
+		res, err := Call(f, args)
+		if err == nil && res.IsError() {
+			err = res.AsError()
+		}
+		return go_results(t, res, err)
+	})
+}
+
+// The results of a Go func of type t that returned res, or failed with err
+func go_results(t reflect.Type, res Value, err error) []reflect.Value {
+	n := t.NumOut()
+	out := make([]reflect.Value, n)
+	for i := range out {
+		out[i] = reflect.Zero(t.Out(i))
+	}
+	has_err := n > 0 && t.Out(n-1) == error_type
+	values := n
+	if has_err {
+		values--
+	}
+	fail := func(err error) []reflect.Value {
+		if !has_err {
+			panic(err)
+		}
+		out[n-1] = reflect.ValueOf(&err).Elem()
+		return out
+	}
+	if err != nil {
+		return fail(err)
+	}
+	// (ok x) and (err e) are Go's x, nil and zero, e
+	if has_err && res.IsOk() {
+		res = res.AsResult().val
+	} else if has_err && res.IsErr() {
+		return fail(errors.New(res.AsResult().val.String()))
+	}
+
+	switch values {
+	case 0:
+	case 1:
+		rv, err := to_go(res, t.Out(0))
+		if err != nil {
+			return fail(fmt.Errorf("Result: %s", err))
+		}
+		out[0] = rv
+	default:
+		elems, ok := go_elements(res)
+		if !ok || len(elems) != values {
+			return fail(fmt.Errorf("Expected a list of %d results, got: %s", values, PrStr(res, true)))
+		}
+		for i, e := range elems {
+			rv, err := to_go(e, t.Out(i))
+			if err != nil {
+				return fail(fmt.Errorf("Result %d: %s", i+1, err))
+			}
+			out[i] = rv
+		}
+	}
+	return out
+}
@@ -0,0 +1,120 @@
+package interp
+
+import (
+	"strings"
+	"testing"
+)
+
+func make_func_fn(t *testing.T, source string) Value {
+	t.Helper()
+	it, err := NewInterpreter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn, err := it.EvalString(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fn
+}
+
+func TestMakeFuncResults(t *testing.T) {
+	check, err := MakeFunc[func(int) (string, error)](make_func_fn(t, `(fn (n) (if (= n 0) (err "zero") (ok (str n))))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := check(5); s != "5" || err != nil {
+		t.Errorf("check(5) => %q, %v", s, err)
+	}
+	if s, err := check(0); s != "" || err == nil || !strings.Contains(err.Error(), "zero") {
+		t.Errorf("check(0) => %q, %v", s, err)
+	}
+
+	// a result that is neither ok nor err is returned as is
+	plain, err := MakeFunc[func() (int, error)](make_func_fn(t, `(fn () 3)`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := plain(); n != 3 || err != nil {
+		t.Errorf("plain() => %d, %v", n, err)
+	}
+
+	// failures are returned as the trailing error
+	bad, err := MakeFunc[func() (int, error)](make_func_fn(t, `(fn () "three")`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bad(); err == nil || !strings.HasPrefix(err.Error(), "Result: ") {
+		t.Errorf("bad() => %v", err)
+	}
+
+	// several results are returned as a list
+	sum_and_b, err := MakeFunc[func(int, int) (int, int, error)](make_func_fn(t, `(fn (a b) (list (+ a b) b))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, b, err := sum_and_b(7, 3); a != 10 || b != 3 || err != nil {
+		t.Errorf("sum_and_b(7, 3) => %d, %d, %v", a, b, err)
+	}
+	pair, err := MakeFunc[func() (string, bool)](make_func_fn(t, `(fn () ["a" true])`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := pair(); s != "a" || !ok {
+		t.Errorf("pair() => %q, %v", s, ok)
+	}
+	short, err := MakeFunc[func() (int, int, error)](make_func_fn(t, `(fn () (list 1))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := short(); err == nil || !strings.Contains(err.Error(), "Expected a list of 2 results") {
+		t.Errorf("short() => %v", err)
+	}
+}
+
+func TestMakeFuncVariadic(t *testing.T) {
+	prepend, err := MakeFunc[func(first string, rest ...string) []string](make_func_fn(t, `(fn (first & rest) (cons first rest))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := prepend("a", "b", "c"); strings.Join(s, " ") != "a b c" {
+		t.Errorf(`prepend("a", "b", "c") => %q`, s)
+	}
+	if s := prepend("a"); strings.Join(s, " ") != "a" {
+		t.Errorf(`prepend("a") => %q`, s)
+	}
+	count, err := MakeFunc[func(...int) int](make_func_fn(t, `(fn (& ns) (len ns))`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 0 {
+		t.Errorf("count() => %d", n)
+	}
+	if n := count(1, 2, 3); n != 3 {
+		t.Errorf("count(1, 2, 3) => %d", n)
+	}
+}
+
+func TestMakeFuncPanics(t *testing.T) {
+	less, err := MakeFunc[func(a, b int) bool](make_func_fn(t, `(fn (a b) "not a bool")`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		r := recover()
+		if err, ok := r.(error); !ok || !strings.HasPrefix(err.Error(), "Result: ") {
+			t.Errorf("less(1, 2) panicked with %v, expected a Result error", r)
+		}
+	}()
+	less(1, 2)
+	t.Errorf("less(1, 2) returned without an error result to report its failure in")
+}
+
+func TestMakeFuncErrors(t *testing.T) {
+	if _, err := MakeFunc[int](make_func_fn(t, `(fn () 1)`)); err == nil {
+		t.Errorf("MakeFunc of a non func type succeeded")
+	}
+	if _, err := MakeFunc[func()](NewNumber(1)); err == nil {
+		t.Errorf("MakeFunc of a number succeeded")
+	}
+}
@@ -0,0 +1,99 @@
+package interp
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Go packages are bound as namespaces of their exported funcs, constants and
+// variables, named go. followed by the import path with / replaced by .:
+//
+//	(go.strings/ToUpper "abc")                       => "ABC"
+//	(require '[go.path.filepath :as filepath])
+//	(filepath/Join "a" "b")                          => "a/b"
+//
+// The namespace of a package is created the first time it is referred to. Funcs
+// are called like the ones bound with RegisterFunc. Bindings are usually generated
+// from a package's type information, see src/bindgen.
+
+const go_package_prefix = "go."
+
+// namespace name => member name => Go value
+var go_packages = struct {
+	mu  sync.RWMutex
+	all map[string]map[string]any
+}{all: make(map[string]map[string]any)}
+
+// Makes members available as the namespace called name, replacing any previous
+// registration. Namespaces created before are not changed.
+func RegisterPackage(name string, members map[string]any) {
+	go_packages.mu.Lock()
+	defer go_packages.mu.Unlock()
+	go_packages.all[name] = members
+}
+
+// Names of the registered packages, sorted
+func GoPackages() []string {
+	go_packages.mu.RLock()
+	defer go_packages.mu.RUnlock()
+	names := make([]string, 0, len(go_packages.all))
+	for name := range go_packages.all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// The namespace of the Go package registered as name, binding it on first use.
+// NOTE :: Returns nil when no package is registered as name
+func (r *Namespaces) go_package(name string) (*Namespace, error) {
+	go_packages.mu.RLock()
+	members, ok := go_packages.all[name]
+	go_packages.mu.RUnlock()
+	if !ok || !r.sys.allows_go_package(name) {
+		return nil, nil
+	}
+	if ns := r.Find(name); ns != nil {
+		return ns, nil
+	}
+
+	env := NewEnv(r.core, nil, nil)
+	for member, x := range members {
+		qualified := name + "/" + member
+		rv := reflect.ValueOf(x)
+		if rv.Kind() == reflect.Func && !rv.IsNil() {
+			f := new_go_fn(qualified, rv)
+			env.Set(member, f)
+			env.SetMeta(member, f.meta)
+			continue
+		}
+		v, err := from_go(rv)
+		if err != nil {
+			return nil, fmt.Errorf("%s => %s", qualified, err)
+		}
+		env.Set(member, v)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// another goroutine may have bound it meanwhile
+	if ns, ok := r.all[name]; ok {
+		return ns, nil
+	}
+	ns := r.new_namespace(name, env)
+	env.ns = ns
+	return ns, nil
+}
+
+// (go-packages) => (go.bytes go.math ...), those s allows
+func eval_go_packages(s *system, vs ...Value) Value {
+	res := make([]Value, 0)
+	for _, name := range GoPackages() {
+		if s.allows_go_package(name) {
+			res = append(res, NewSymbol(Symbol(name)))
+		}
+	}
+	return NewList(res)
+}
@@ -0,0 +1,222 @@
+package interp
+
+import (
+	"hash/fnv"
+	"math"
+	"reflect"
+)
+
+// Hashing and equality protocol used for hashmap keys. Every VAL_* type hashes
+// and compares by value, except functions, channels and refs which use identity.
+// Values that are Equals MUST produce the same Hash.
+
+// Per type seeds, so that e.g. the keyword :a, the string "a" and the
+// symbol a all hash (and compare) differently.
+const (
+	hash_seed_string  = 0x9e3779b9
+	hash_seed_symbol  = 0x7f4a7c15
+	hash_seed_atom    = 0x85ebca6b
+	hash_seed_seq     = 0xc2b2ae35
+	hash_seed_map     = 0x27d4eb2f
+	hash_seed_set     = 0x61c88647
+	hash_seed_error   = 0x165667b1
+	hash_seed_ident   = 0xd3a2646c
+	hash_seed_none    = 0xfd7046c5
+	hash_seed_some    = 0x2545f491
+	hash_seed_ok      = 0x9c6e6877
+	hash_seed_err     = 0x4f1bbcdd
+	hash_true         = 1231
+	hash_false        = 1237
+	hash_seq_multiple = 31
+)
+
+func hash_string(seed uint32, s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return mix_hash(seed ^ h.Sum32())
+}
+
+// Final avalanche step from murmur3, so small differences spread over every bit
+// the HAMT looks at.
+func mix_hash(h uint32) uint32 {
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+func hash_ptr(seed uint32, p interface{}) uint32 {
+	ptr := uint64(reflect.ValueOf(p).Pointer())
+	return mix_hash(seed ^ uint32(ptr) ^ uint32(ptr>>32))
+}
+
+func (v Value) Hash() uint32 {
+	switch v.Type() {
+	case VAL_NUMBER:
+		n := v.AsNumber()
+		if n == 0 {
+			// -0.0 == 0.0
+			n = 0
+		}
+		bits := math.Float64bits(n)
+		return mix_hash(uint32(bits) ^ uint32(bits>>32))
+	case VAL_STRING:
+		return hash_string(hash_seed_string, v.AsString())
+	case VAL_BOOLEAN:
+		if v.AsBool() {
+			return hash_true
+		}
+		return hash_false
+	case VAL_LIST:
+		fallthrough
+	case VAL_ARRAY:
+		// Lists and arrays with the same elements are equal, so they hash the same
+		h := uint32(hash_seed_seq)
+		for _, e := range v.AsList() {
+			h = h*hash_seq_multiple + e.Hash()
+		}
+		return mix_hash(h)
+	case VAL_HASHMAP:
+		// Order independent, entries are combined with a sum
+		h := uint32(hash_seed_map)
+		v.AsHashMap().Each(func(key Value, val Value) bool {
+			h += key.Hash() ^ mix_hash(val.Hash())
+			return true
+		})
+		return mix_hash(h)
+	case VAL_RECORD:
+		h := hash_string(hash_seed_map, v.AsRecord().ty.name)
+		v.AsRecord().Each(func(key Value, val Value) bool {
+			h += key.Hash() ^ mix_hash(val.Hash())
+			return true
+		})
+		return mix_hash(h)
+	case VAL_SET:
+		h := uint32(hash_seed_set)
+		v.AsSet().Each(func(e Value) bool {
+			h += e.Hash()
+			return true
+		})
+		return mix_hash(h)
+	case VAL_SYMBOL:
+		return hash_string(hash_seed_symbol, v.AsSymbol().Name())
+	case VAL_ATOM:
+		return hash_string(hash_seed_atom, v.AsAtom().Name())
+	case VAL_ERROR:
+		if v.val == nil {
+			return hash_seed_error
+		}
+		return hash_string(hash_seed_error, v.AsError().Error())
+	case VAL_FN:
+		return hash_ptr(hash_seed_ident, v.AsFn())
+	case VAL_CHANNEL:
+		return hash_ptr(hash_seed_ident, v.AsChan())
+	case VAL_REF:
+		return hash_ptr(hash_seed_ident, v.AsRef())
+	case VAL_PROTOCOL:
+		return hash_ptr(hash_seed_ident, v.AsProtocol())
+	case VAL_OPTION:
+		if !v.AsOption().is_some {
+			return hash_seed_none
+		}
+		return mix_hash(hash_seed_some ^ v.AsOption().val.Hash())
+	case VAL_RESULT:
+		if v.AsResult().is_ok {
+			return mix_hash(hash_seed_ok ^ v.AsResult().val.Hash())
+		}
+		return mix_hash(hash_seed_err ^ v.AsResult().val.Hash())
+	case VAL_OBJECT:
+		return v.AsObject().hash()
+	default:
+		return hash_seed_none
+	}
+}
+
+// Structural equality. Lists and arrays compare equal to each other when their
+// elements are equal, functions, channels, refs and protocols compare by identity.
+func (v Value) Equals(other Value) bool {
+	if v.IsListLike() && other.IsListLike() {
+		left := v.AsList()
+		right := other.AsList()
+		if len(left) != len(right) {
+			return false
+		}
+		for i := range left {
+			if !left[i].Equals(right[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if v.Type() != other.Type() {
+		return false
+	}
+
+	switch v.Type() {
+	case VAL_NUMBER:
+		return v.AsNumber() == other.AsNumber()
+	case VAL_STRING:
+		return v.AsString() == other.AsString()
+	case VAL_BOOLEAN:
+		return v.AsBool() == other.AsBool()
+	case VAL_HASHMAP:
+		left := v.AsHashMap()
+		right := other.AsHashMap()
+		if left.Len() != right.Len() {
+			return false
+		}
+		equal := true
+		left.Each(func(key Value, val Value) bool {
+			rval, ok := right.Get(key)
+			equal = ok && val.Equals(rval)
+			return equal
+		})
+		return equal
+	case VAL_RECORD:
+		return v.AsRecord().Equals(other.AsRecord())
+	case VAL_SET:
+		left := v.AsSet()
+		right := other.AsSet()
+		if left.Len() != right.Len() {
+			return false
+		}
+		equal := true
+		left.Each(func(e Value) bool {
+			equal = right.Contains(e)
+			return equal
+		})
+		return equal
+	case VAL_SYMBOL:
+		return v.AsSymbol().Name() == other.AsSymbol().Name()
+	case VAL_ATOM:
+		return v.AsAtom().Name() == other.AsAtom().Name()
+	case VAL_ERROR:
+		if v.val == nil || other.val == nil {
+			return v.val == other.val
+		}
+		return v.AsError().Error() == other.AsError().Error()
+	case VAL_FN:
+		return v.AsFn() == other.AsFn()
+	case VAL_CHANNEL:
+		return v.AsChan() == other.AsChan()
+	case VAL_REF:
+		return v.AsRef() == other.AsRef()
+	case VAL_PROTOCOL:
+		return v.AsProtocol() == other.AsProtocol()
+	case VAL_OPTION:
+		l, r := v.AsOption(), other.AsOption()
+		return l.is_some == r.is_some && l.val.Equals(r.val)
+	case VAL_RESULT:
+		l, r := v.AsResult(), other.AsResult()
+		return l.is_ok == r.is_ok && l.val.Equals(r.val)
+	case VAL_OBJECT:
+		return v.AsObject().equals(other.AsObject())
+	case VAL_NONE:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,72 @@
+package interp
+
+import "testing"
+
+func TestValueKeys(t *testing.T) {
+	it, err := NewInterpreter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := it.EvalString(`(def m {:a 1 "a" 2 (quot a) 3 [1 2] :vec 1 :one {:k 1} :map #{1 2} :set nil :nil true :t 0 :zero})`); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		source string
+		want   string
+	}{
+		// keywords, strings and symbols of the same name are different keys
+		{`(len m)`, "10"},
+		{`(mget m :a)`, "1"},
+		{`(mget m "a")`, "2"},
+		{`(mget m (quot a))`, "3"},
+		// equal values are the same key, whatever their concrete type
+		{`(mget m (list 1 2))`, ":vec"},
+		{`(mget m {:k 1})`, ":map"},
+		{`(mget m #{2 1})`, ":set"},
+		{`(mget m nil)`, ":nil"},
+		{`(mget m true)`, ":t"},
+		{`(mget m -0)`, ":zero"},
+		{`(mget m 1)`, ":one"},
+		{`(mget m 2)`, "()"},
+		{`(contains? #{[1 2] :b} (list 1 2))`, "true"},
+		{`(do (def n {}) (mset! n [1] :x) (mget n [1]))`, ":x"},
+	}
+	for _, tt := range tests {
+		if v, err := it.EvalString(tt.source); err != nil || PrStr(v, true) != tt.want {
+			t.Errorf("%s => %s, %v, expected %s", tt.source, PrStr(v, true), err, tt.want)
+		}
+	}
+}
+
+func TestValueHash(t *testing.T) {
+	// values that are Equals must hash alike
+	pairs := [][2]string{
+		{`[1 2]`, `(list 1 2)`},
+		{`{:a 1 :b 2}`, `{:b 2 :a 1}`},
+		{`#{1 2 3}`, `#{3 2 1}`},
+		{`0`, `-0`},
+		{`nil`, `(list)`},
+		{`(some [1])`, `(some (list 1))`},
+	}
+	env := NewCoreEnv()
+	for _, pair := range pairs {
+		var vals [2]Value
+		for i, source := range pair {
+			form, err := Read(source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if vals[i], err = Eval(form, env); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !vals[0].Equals(vals[1]) {
+			t.Errorf("%s and %s are not equal", pair[0], pair[1])
+		} else if vals[0].Hash() != vals[1].Hash() {
+			t.Errorf("%s and %s are equal but hash to %d and %d", pair[0], pair[1], vals[0].Hash(), vals[1].Hash())
+		}
+	}
+	if NewAtom(":a").Hash() == NewString("a").Hash() && NewString("a").Hash() == NewSymbol("a").Hash() {
+		t.Errorf(":a, \"a\" and a all hash alike")
+	}
+}
@@ -0,0 +1,280 @@
+package interp
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+// PersistentHashMap is an immutable hash array mapped trie (HAMT). Each node holds
+// a 32 bit bitmap of occupied slots and a compact array of entries, where an entry is
+// either a key/value pair or a pointer to a child node. Keys whose hashes collide
+// completely are kept together in a collision node. Like PersistentVector,
+// updates only copy the path to the changed entry.
+//
+// Keys can be any Value, see Value.Hash and Value.Equals. The one exception to
+// immutability is mset!, which swaps the root of a map handle with a compare and
+// swap, see AssocInPlace.
+
+const (
+	hamt_bits = 5
+	hamt_mask = (1 << hamt_bits) - 1
+)
+
+type hamt_entry struct {
+	key  Value
+	val  Value
+	node *hamt_node
+}
+
+type hamt_node struct {
+	bitmap  uint32
+	entries []hamt_entry
+
+	// Set when this is a collision node, all entries share this hash
+	collision bool
+	hash      uint32
+}
+
+type PersistentHashMap struct {
+	// replaced as a whole by AssocInPlace, so every read sees one version of the map
+	state atomic.Pointer[hamt_root]
+}
+
+type hamt_root struct {
+	count int
+	root  *hamt_node
+}
+
+// DONT MUTATE ME!!!!!
+var empty_hamt_node = &hamt_node{}
+
+// NOTE :: Always returns a fresh handle, as mset! swaps the root of a handle in place
+func EmptyHashMap() *PersistentHashMap {
+	return new_hash_map(0, empty_hamt_node)
+}
+
+func new_hash_map(count int, root *hamt_node) *PersistentHashMap {
+	m := &PersistentHashMap{}
+	m.state.Store(&hamt_root{count, root})
+	return m
+}
+
+func hamt_bitpos(hash uint32, shift uint) uint32 {
+	return 1 << ((hash >> shift) & hamt_mask)
+}
+
+func (n *hamt_node) index(bit uint32) int {
+	return bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+func (self *PersistentHashMap) Len() int {
+	return self.state.Load().count
+}
+
+func (self *PersistentHashMap) Get(key Value) (Value, bool) {
+	return self.state.Load().root.find(0, key.Hash(), key)
+}
+
+func (n *hamt_node) find(shift uint, hash uint32, key Value) (Value, bool) {
+	if n.collision {
+		for _, e := range n.entries {
+			if e.key.Equals(key) {
+				return e.val, true
+			}
+		}
+		return NoValue(), false
+	}
+
+	bit := hamt_bitpos(hash, shift)
+	if n.bitmap&bit == 0 {
+		return NoValue(), false
+	}
+	e := n.entries[n.index(bit)]
+	if e.node != nil {
+		return e.node.find(shift+hamt_bits, hash, key)
+	}
+	if e.key.Equals(key) {
+		return e.val, true
+	}
+	return NoValue(), false
+}
+
+func (self *PersistentHashMap) Assoc(key Value, val Value) *PersistentHashMap {
+	next := self.state.Load().assoc(key, val)
+	return new_hash_map(next.count, next.root)
+}
+
+// Maps key to val in self itself, every holder of self sees the change. The new
+// root is swapped in with a compare and swap, retried until no other update got
+// in between, so concurrent updates of the same map are never lost
+func (self *PersistentHashMap) AssocInPlace(key Value, val Value) {
+	for {
+		current := self.state.Load()
+		if self.state.CompareAndSwap(current, current.assoc(key, val)) {
+			return
+		}
+	}
+}
+
+func (r *hamt_root) assoc(key Value, val Value) *hamt_root {
+	root, added := r.root.assoc(0, key.Hash(), key, val)
+	count := r.count
+	if added {
+		count++
+	}
+	return &hamt_root{count, root}
+}
+
+func (n *hamt_node) assoc(shift uint, hash uint32, key Value, val Value) (*hamt_node, bool) {
+	if n.collision {
+		if hash == n.hash {
+			for i, e := range n.entries {
+				if e.key.Equals(key) {
+					entries := clone_entries(n.entries)
+					entries[i].val = val
+					return &hamt_node{entries: entries, collision: true, hash: hash}, false
+				}
+			}
+			entries := append(clone_entries(n.entries), hamt_entry{key: key, val: val})
+			return &hamt_node{entries: entries, collision: true, hash: hash}, true
+		}
+		// Different hash, nest the collision node in a bitmap node and retry
+		wrap := &hamt_node{
+			bitmap:  hamt_bitpos(n.hash, shift),
+			entries: []hamt_entry{{node: n}},
+		}
+		return wrap.assoc(shift, hash, key, val)
+	}
+
+	bit := hamt_bitpos(hash, shift)
+	idx := n.index(bit)
+
+	if n.bitmap&bit == 0 {
+		entries := make([]hamt_entry, len(n.entries)+1)
+		copy(entries, n.entries[:idx])
+		entries[idx] = hamt_entry{key: key, val: val}
+		copy(entries[idx+1:], n.entries[idx:])
+		return &hamt_node{bitmap: n.bitmap | bit, entries: entries}, true
+	}
+
+	e := n.entries[idx]
+	entries := clone_entries(n.entries)
+
+	if e.node != nil {
+		child, added := e.node.assoc(shift+hamt_bits, hash, key, val)
+		entries[idx] = hamt_entry{node: child}
+		return &hamt_node{bitmap: n.bitmap, entries: entries}, added
+	}
+
+	if e.key.Equals(key) {
+		entries[idx].val = val
+		return &hamt_node{bitmap: n.bitmap, entries: entries}, false
+	}
+
+	// Slot is taken by a different key, push both down a level
+	child := new_hamt_pair(shift+hamt_bits, e.key.Hash(), e, hash, hamt_entry{key: key, val: val})
+	entries[idx] = hamt_entry{node: child}
+	return &hamt_node{bitmap: n.bitmap, entries: entries}, true
+}
+
+func new_hamt_pair(shift uint, h1 uint32, e1 hamt_entry, h2 uint32, e2 hamt_entry) *hamt_node {
+	if h1 == h2 {
+		return &hamt_node{entries: []hamt_entry{e1, e2}, collision: true, hash: h1}
+	}
+
+	b1 := hamt_bitpos(h1, shift)
+	b2 := hamt_bitpos(h2, shift)
+	if b1 == b2 {
+		child := new_hamt_pair(shift+hamt_bits, h1, e1, h2, e2)
+		return &hamt_node{bitmap: b1, entries: []hamt_entry{{node: child}}}
+	}
+	if b1 < b2 {
+		return &hamt_node{bitmap: b1 | b2, entries: []hamt_entry{e1, e2}}
+	}
+	return &hamt_node{bitmap: b1 | b2, entries: []hamt_entry{e2, e1}}
+}
+
+func (self *PersistentHashMap) Dissoc(key Value) *PersistentHashMap {
+	current := self.state.Load()
+	root, removed := current.root.dissoc(0, key.Hash(), key)
+	if !removed {
+		return new_hash_map(current.count, current.root)
+	}
+	if root == nil {
+		return EmptyHashMap()
+	}
+	return new_hash_map(current.count-1, root)
+}
+
+// Returns the new node (nil if it is now empty) and whether the key was found
+func (n *hamt_node) dissoc(shift uint, hash uint32, key Value) (*hamt_node, bool) {
+	if n.collision {
+		for i, e := range n.entries {
+			if e.key.Equals(key) {
+				if len(n.entries) == 1 {
+					return nil, true
+				}
+				entries := make([]hamt_entry, 0, len(n.entries)-1)
+				entries = append(entries, n.entries[:i]...)
+				entries = append(entries, n.entries[i+1:]...)
+				return &hamt_node{entries: entries, collision: true, hash: n.hash}, true
+			}
+		}
+		return n, false
+	}
+
+	bit := hamt_bitpos(hash, shift)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+	idx := n.index(bit)
+	e := n.entries[idx]
+
+	if e.node != nil {
+		child, removed := e.node.dissoc(shift+hamt_bits, hash, key)
+		if !removed {
+			return n, false
+		}
+		if child != nil {
+			entries := clone_entries(n.entries)
+			entries[idx] = hamt_entry{node: child}
+			return &hamt_node{bitmap: n.bitmap, entries: entries}, true
+		}
+	} else if !e.key.Equals(key) {
+		return n, false
+	}
+
+	// Remove the slot entirely
+	if n.bitmap == bit {
+		return nil, true
+	}
+	entries := make([]hamt_entry, 0, len(n.entries)-1)
+	entries = append(entries, n.entries[:idx]...)
+	entries = append(entries, n.entries[idx+1:]...)
+	return &hamt_node{bitmap: n.bitmap &^ bit, entries: entries}, true
+}
+
+func clone_entries(entries []hamt_entry) []hamt_entry {
+	res := make([]hamt_entry, len(entries))
+	copy(res, entries)
+	return res
+}
+
+// Calls fn for every key/value pair, stopping early if fn returns false.
+// Iteration order is stable for a given map but otherwise unspecified.
+func (self *PersistentHashMap) Each(fn func(key Value, val Value) bool) {
+	self.state.Load().root.each(fn)
+}
+
+func (n *hamt_node) each(fn func(key Value, val Value) bool) bool {
+	for _, e := range n.entries {
+		if e.node != nil {
+			if !e.node.each(fn) {
+				return false
+			}
+		} else if !fn(e.key, e.val) {
+			return false
+		}
+	}
+	return true
+}
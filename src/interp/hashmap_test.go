@@ -0,0 +1,178 @@
+package interp
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Go objects of a comparable non-pointer type all hash alike, see GoObject.hash
+type colliding int
+
+func TestHashMapAssocDissoc(t *testing.T) {
+	const n = 2000
+	m := EmptyHashMap()
+	versions := make([]*PersistentHashMap, 0, n)
+	for i := 0; i < n; i++ {
+		versions = append(versions, m)
+		m = m.Assoc(NewNumber(float64(i)), NewNumber(float64(i*2)))
+	}
+	if m.Len() != n {
+		t.Fatalf("Len() = %d, expected %d", m.Len(), n)
+	}
+	for i := 0; i < n; i++ {
+		if v, ok := m.Get(NewNumber(float64(i))); !ok || v.AsNumber() != float64(i*2) {
+			t.Fatalf("Get(%d) = %s, %t", i, PrStr(v, true), ok)
+		}
+	}
+	// older versions are unchanged
+	for i, old := range versions {
+		if old.Len() != i {
+			t.Fatalf("version %d has Len() %d", i, old.Len())
+		}
+		if _, ok := old.Get(NewNumber(float64(i))); ok {
+			t.Fatalf("version %d holds a key assoc'd after it", i)
+		}
+	}
+
+	// replacing a value keeps the count
+	if replaced := m.Assoc(NewNumber(1), NewString("one")); replaced.Len() != n {
+		t.Fatalf("Len() = %d after replacing a value", replaced.Len())
+	}
+
+	full := m
+	for i := 0; i < n; i++ {
+		m = m.Dissoc(NewNumber(float64(i)))
+		if m.Len() != n-i-1 {
+			t.Fatalf("Len() = %d after %d dissocs", m.Len(), i+1)
+		}
+		if _, ok := m.Get(NewNumber(float64(i))); ok {
+			t.Fatalf("Get(%d) found a dissoc'd key", i)
+		}
+	}
+	if m.Len() != 0 || m.Dissoc(NewNumber(0)).Len() != 0 {
+		t.Fatalf("map is not empty after dissoc'ing every key")
+	}
+	if full.Len() != n {
+		t.Fatalf("Dissoc changed the original to Len() %d", full.Len())
+	}
+	if missing := full.Dissoc(NewString("missing")); missing.Len() != n {
+		t.Fatalf("Dissoc of a missing key changed Len() to %d", missing.Len())
+	}
+}
+
+func TestHashMapCollisions(t *testing.T) {
+	keys := []Value{NewObject(colliding(1)), NewObject(colliding(2)), NewObject(colliding(3))}
+	if keys[0].Hash() != keys[1].Hash() {
+		t.Fatalf("keys do not collide")
+	}
+	m := EmptyHashMap().Assoc(NewNumber(1), NewString("number"))
+	for i, k := range keys {
+		m = m.Assoc(k, NewNumber(float64(i)))
+	}
+	if m.Len() != 4 {
+		t.Fatalf("Len() = %d, expected 4", m.Len())
+	}
+	for i, k := range keys {
+		if v, ok := m.Get(k); !ok || v.AsNumber() != float64(i) {
+			t.Fatalf("Get(%s) = %s, %t", PrStr(k, true), PrStr(v, true), ok)
+		}
+	}
+	if _, ok := m.Get(NewObject(colliding(4))); ok {
+		t.Fatalf("Get found a colliding key that was never assoc'd")
+	}
+
+	m = m.Assoc(keys[1], NewString("replaced"))
+	if v, _ := m.Get(keys[1]); m.Len() != 4 || !v.IsString() {
+		t.Fatalf("replacing a colliding key => Len() %d, value %s", m.Len(), PrStr(v, true))
+	}
+	for i, k := range keys {
+		m = m.Dissoc(k)
+		if m.Len() != 3-i {
+			t.Fatalf("Len() = %d after dissoc'ing %d colliding keys", m.Len(), i+1)
+		}
+		for _, rest := range keys[i+1:] {
+			if _, ok := m.Get(rest); !ok {
+				t.Fatalf("Dissoc(%s) lost %s", PrStr(k, true), PrStr(rest, true))
+			}
+		}
+	}
+	if v, ok := m.Get(NewNumber(1)); !ok || v.AsString() != "number" {
+		t.Fatalf("the other key was lost")
+	}
+}
+
+func TestHashMapKeys(t *testing.T) {
+	keys := []Value{
+		NewAtom(":a"), NewString("a"), NewSymbol(Symbol("a")), NewNumber(1), NewBool(true),
+		NewNilList(), NewArray([]Value{NewNumber(1), NewNumber(2)}),
+		NewHashMap(EmptyHashMap().Assoc(NewAtom(":k"), NewNumber(1))),
+	}
+	m := EmptyHashMap()
+	for i, k := range keys {
+		m = m.Assoc(k, NewNumber(float64(i)))
+	}
+	if m.Len() != len(keys) {
+		t.Fatalf("Len() = %d, expected %d, keys of different types collided", m.Len(), len(keys))
+	}
+	for i, k := range keys {
+		if v, ok := m.Get(k); !ok || v.AsNumber() != float64(i) {
+			t.Errorf("Get(%s) = %s, %t", PrStr(k, true), PrStr(v, true), ok)
+		}
+	}
+	// equal collections are equal keys
+	if v, ok := m.Get(NewArray([]Value{NewNumber(1), NewNumber(2)})); !ok || v.AsNumber() != 6 {
+		t.Errorf("an equal array is not the same key")
+	}
+}
+
+func TestHashMapEach(t *testing.T) {
+	m := EmptyHashMap()
+	for i := 0; i < 100; i++ {
+		m = m.Assoc(NewString(fmt.Sprint(i)), NewNumber(float64(i)))
+	}
+	seen := make(map[string]bool)
+	m.Each(func(key Value, val Value) bool {
+		seen[key.AsString()] = true
+		return true
+	})
+	if len(seen) != 100 {
+		t.Fatalf("Each visited %d entries, expected 100", len(seen))
+	}
+}
+
+func TestHashMapAssocInPlace(t *testing.T) {
+	const goroutines, n = 8, 200
+	m := EmptyHashMap().Assoc(NewAtom(":a"), NewNumber(1))
+	before := m.Assoc(NewAtom(":b"), NewNumber(2))
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				m.AssocInPlace(NewNumber(float64(g*n+i)), NewNumber(float64(i)))
+				m.Len()
+			}
+		}(g)
+	}
+	wg.Wait()
+	if m.Len() != goroutines*n+1 {
+		t.Errorf("Len() = %d after concurrent updates, expected %d", m.Len(), goroutines*n+1)
+	}
+	if before.Len() != 2 {
+		t.Errorf("a map assoc'd from m changed, Len() = %d", before.Len())
+	}
+
+	it, err := NewInterpreter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := it.EvalString(`(def m {:a 1}) (def held [m]) (mset! m :b 2) (mget (nth held 0) :b)`)
+	if err != nil || PrStr(v, true) != "2" {
+		t.Errorf("mset! => %s, %v, expected every holder to see the change", PrStr(v, true), err)
+	}
+	if _, err := it.EvalString(`(mset! [1] 0 2)`); err == nil {
+		t.Errorf("mset! on an array succeeded")
+	}
+}
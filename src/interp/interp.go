@@ -8,33 +8,141 @@ import (
 )
 
 func Read(source string) (Value, error) {
-	p := new_parser(source)
+	return read_first(new_parser(source))
+}
+
+func read_first(p parser) (Value, error) {
+	if len(p.toks) == 0 {
+		return NoValue(), fmt.Errorf("read_form => Unexpected end of input")
+	}
 	return p.read_form()
 }
 
-func Eval(ast Value, env *Env) (Value, error) {
+// Reads every form in source, e.g. the contents of a file
+func ReadAll(source string) ([]Value, error) {
+	return read_all(new_parser(source))
+}
+
+// Like ReadAll, with :line and :column metadata on every form that can carry it
+func ReadAllPositions(source string) ([]Value, error) {
+	p := new_parser(source)
+	p.positions = true
+	return read_all(p)
+}
+
+func read_all(p parser) ([]Value, error) {
+	forms := make([]Value, 0)
+	for int(p.current) < len(p.toks) {
+		if form, err := p.read_form(); err == nil {
+			forms = append(forms, form)
+		} else {
+			return nil, err
+		}
+		p.skip(1)
+	}
+	return forms, nil
+}
+
+// Heads of the forms Eval handles itself instead of calling them
+var special_forms = map[string]bool{
+	"def": true, "defmacro": true, "doc": true, "binding": true, "ns": true,
+	"defprotocol": true, "extend-type": true, "extend-protocol": true, "defrecord": true,
+	"defstruct": true, "defmulti": true, "defmethod": true, "let": true, "do": true,
+	"if": true, "fn": true, "quot": true, "?": true, "match": true, "quasiquot": true,
+	"set!": true,
+}
+
+// Whether a list headed by the symbol name is a special form rather than a call,
+// Go interop like (.Method obj) included. The checker and the transpiler use it to
+// treat forms as Eval does.
+func IsSpecialForm(name string) bool {
+	return special_forms[name] || is_interop_symbol(name)
+}
+
+func Eval(ast Value, env *Env) (res Value, err error) {
+	// (? expr) in the body of a fn called below unwinds to here, see eval_propagate
+	in_fn := false
+	defer func() {
+		if err != nil && in_fn {
+			res, err = catch_propagation(res, err)
+		}
+	}()
+
 	for {
 
 		switch ast.Type() {
 		case VAL_LIST:
+			if expanded, err := macroexpand(ast, env); err == nil {
+				ast = expanded
+			} else {
+				return NoValue(), err
+			}
+			if !ast.IsList() {
+				continue
+			}
+
 			list := ast.AsList()
 			if len(list) == 0 {
 				return ast, nil
 			}
 
 			first := list[0]
-			if first.IsSymbol() {
+			if first.IsSymbol() && IsSpecialForm(first.AsSymbol().Name()) {
 				first_sym := first.AsSymbol()
 				switch first_sym.Name() {
 				case "def":
+					// (def name "doc"? value)
+					var doc Value
+					if len(list) > 3 && list[2].IsString() {
+						doc = list[2]
+					}
+					if value, err := Eval(list[len(list)-1], env); err == nil {
+						return Define(env, list[1], doc, value), nil
+					} else {
+						return NoValue(), err
+					}
+
+				case "defmacro":
+					// (defmacro name "doc"? (params) body)
 					name := list[1].AsSymbol().Name()
-					if value, err := Eval(list[2], env); err == nil {
-						env.Set(name, value)
-						return value, nil
+					if macro, err := new_user_fn(list[2:], env, list[1].meta); err == nil {
+						f := macro.AsFn()
+						f.name = name
+						f.is_macro = true
+						env.Set(name, macro)
+						env.SetMeta(name, macro.meta)
+						return macro, nil
 					} else {
 						return NoValue(), err
 					}
 
+				case "doc":
+					return eval_doc(list, env)
+
+				case "binding":
+					return eval_binding(list, env)
+
+				case "ns":
+					return eval_ns(list, env)
+
+				case "defprotocol":
+					return eval_defprotocol(list, env)
+
+				case "extend-type":
+					return eval_extend_type(list, env)
+
+				case "extend-protocol":
+					return eval_extend_protocol(list, env)
+
+				case "defrecord", "defstruct":
+					return eval_defrecord(list, env)
+
+				case "defmulti":
+					return eval_defmulti(list, env)
+
+				case "defmethod":
+					return eval_defmethod(list, env)
+
 				case "let":
 					let_env := NewEnv(env, nil, nil)
 					bindings := list[1].AsList()
@@ -77,22 +185,29 @@ func Eval(ast Value, env *Env) (Value, error) {
 						return NoValue(), err
 					}
 				case "fn":
-
-					fn := func(vs ...Value) Value {
-						binds := list[1].AsList()
-						fn_env := NewEnv(env, binds, vs)
-						if body, err := Eval(list[2], fn_env); err == nil {
-							return body
-						} else {
-							fmt.Printf("WARN => Failed to eval fn body: %s\n", err)
-							return NewNilList()
-						}
-					}
-					sfn := NewFn(list[2], list[1], env, fn)
-					return sfn, nil
+					return new_user_fn(list[1:], env, ast.meta)
 				case "quot":
-					ast = list[1]
+					return list[1], nil
+				case "?":
+					return eval_propagate(list, env)
+				case "match":
+					if body, clause_env, err := eval_match(list, env); err == nil {
+						ast = body
+						env = clause_env
+						continue
+					} else {
+						return NoValue(), err
+					}
+				case "quasiquot":
+					ast = quasiquot(list[1])
 					continue
+				case "set!":
+					return eval_set_field(list, env)
+				default:
+					if is_interop_symbol(first_sym.Name()) {
+						return eval_interop(list, env)
+					}
+					return NoValue(), fmt.Errorf("%s => Special form is not implemented", first_sym.Name())
 				}
 			}
 
@@ -104,16 +219,28 @@ func Eval(ast Value, env *Env) (Value, error) {
 				case VAL_FN:
 					f := list[0].AsFn()
 					if f.IsCoreFn() {
-						return f.fn(list[1:]...), nil
+						return f.call_core(env.dyn_frame(), list[1:]), nil
+					} else if f.native != nil {
+						v, tc, call_err := run_native(f.native, f.call_env(env.dyn_frame(), list[1:]))
+						if tc == nil {
+							return catch_propagation(v, call_err)
+						}
+						// the compiled body tail called an interpreted fn
+						ast = tc.f.body
+						env = tc.call_env()
+						in_fn = true
+						continue
 					} else {
 						ast = f.body
-						args := list[1:]
-						new_env := NewEnv(f.env, f.params.AsList(), args)
-						env = new_env
+						env = f.call_env(env.dyn_frame(), list[1:])
+						in_fn = true
 						continue
 
 					}
 
+				case VAL_ATOM, VAL_HASHMAP, VAL_ARRAY, VAL_SET:
+					return invoke_collection(list[0], list[1:])
+
 				default:
 					return NoValue(), fmt.Errorf("Unable to call symbol %s as function: Unknown symbol or not a function", list[0])
 				}
@@ -125,28 +252,68 @@ func Eval(ast Value, env *Env) (Value, error) {
 		case VAL_HASHMAP:
 			// If the values type is Hashmap, but the underlying type is still a []Value,
 			// this means we have read a hashmap literal that has not yet been evaluated.
-			// so we go ahead and evaluate it here. (Change the inner val pointer from []Value to SmackMap).
+			// so we go ahead and evaluate it here. (Change the inner val pointer from []Value to *PersistentHashMap).
 			// Otherwise we just forward the ast value to eval_ast as usual
 			switch ast.val.(type) {
 			case []Value:
 				inner_list := ast.AsList()
-				inner_map := make(SmackMap, len(inner_list)/2)
+				inner_map := EmptyHashMap()
 
 				for i := 1; i < len(inner_list); i = i + 2 {
 
-					name := inner_list[i-1].String()
+					key, err := Eval(inner_list[i-1], env)
+					if err != nil {
+						return NoValue(), err
+					}
 					if val, err := Eval(inner_list[i], env); err == nil {
-						inner_map[name] = val
+						inner_map = inner_map.Assoc(key, val)
 
 					} else {
 						return NoValue(), err
 					}
 				}
+				// NOTE :: metadata on the literal carries over as ast is a copy
 				ast.val = inner_map
 				return eval_ast(ast, env)
 			default:
 				return eval_ast(ast, env)
 			}
+		case VAL_ARRAY:
+			// Same as hashmaps, array literals are read as a []Value and are
+			// evaluated into a PersistentVector here.
+			switch ast.val.(type) {
+			case []Value:
+				vec := EmptyVector()
+				for _, v := range ast.AsList() {
+					if val, err := Eval(v, env); err == nil {
+						vec = vec.Conj(val)
+					} else {
+						return NoValue(), err
+					}
+				}
+				res := NewVector(vec)
+				res.meta = ast.meta
+				return res, nil
+			default:
+				return eval_ast(ast, env)
+			}
+		case VAL_SET:
+			switch ast.val.(type) {
+			case []Value:
+				set := EmptySet()
+				for _, v := range ast.AsList() {
+					if val, err := Eval(v, env); err == nil {
+						set = set.Conj(val)
+					} else {
+						return NoValue(), err
+					}
+				}
+				res := NewSet(set)
+				res.meta = ast.meta
+				return res, nil
+			default:
+				return eval_ast(ast, env)
+			}
 		default:
 			return eval_ast(ast, env)
 		}
@@ -154,37 +321,133 @@ func Eval(ast Value, env *Env) (Value, error) {
 
 }
 
-func Print(v Value) string {
-	return v.String()
+// Builds a user fn from the forms following fn: "doc"? (params) body
+func new_user_fn(forms []Value, env *Env, form_meta *PersistentHashMap) (Value, error) {
+	return make_user_fn(forms, env, form_meta, nil)
 }
 
-func Rep(source string, env *Env) (string, error) {
-	lines := strings.Split(source, "\n")
-	// fmt.Printf("%#v", lines)
+// native, when not nil, is called in place of evaluating the body
+func make_user_fn(forms []Value, env *Env, form_meta *PersistentHashMap, native NativeBody) (Value, error) {
+	meta := EmptyHashMap()
+	if len(forms) > 2 && forms[0].IsString() {
+		meta = meta.Assoc(NewAtom(":doc"), forms[0])
+		forms = forms[1:]
+	}
+	if len(forms) < 2 || !forms[0].IsListLike() {
+		return NoValue(), fmt.Errorf("fn => Expected a parameter list and a body")
+	}
+	params := forms[0]
+	body := forms[1]
+	meta = meta.Assoc(NewAtom(":arglists"), NewList([]Value{params}))
+
+	fn := func(vs ...Value) Value {
+		binds := params.AsList()
+		fn_env := NewEnv(env, binds, vs)
+		fn_env.frame = no_bindings
+		eval_body := Eval
+		if native != nil {
+			eval_body = func(_ Value, env *Env) (Value, error) { return call_native(native, env) }
+		}
+		if body, err := catch_propagation(eval_body(body, fn_env)); err == nil {
+			return body
+		} else {
+			fmt.Fprintf(env.system().stderr, "WARN => Failed to eval fn body: %s\n", err)
+			return NewNilList()
+		}
+	}
+	sfn := NewFn(body, params, env, fn)
+	sfn.AsFn().native = native
+	if form_meta != nil {
+		meta = merge_meta(meta, form_meta)
+	}
+	sfn.meta = meta
+	return sfn, nil
+}
 
-	var last_print string
+// Calls f with args and the dynamic bindings of frame. Unlike SmackFn.Apply,
+// errors from evaluating a user fn body are returned instead of printed
+func apply_fn(f *SmackFn, frame *dyn_frame, args []Value) (Value, error) {
+	if f.IsCoreFn() {
+		return f.call_core(frame, args), nil
+	}
+	fn_env := f.call_env(frame, args)
+	if f.native != nil {
+		return catch_propagation(call_native(f.native, fn_env))
+	}
+	return catch_propagation(Eval(f.body, fn_env))
+}
 
-	for _, l := range lines {
-		if len(l) == 0 {
-			continue
-		}
+func is_macro_call(ast Value, env *Env) (*SmackFn, bool) {
+	if !ast.IsList() {
+		return nil, false
+	}
+	list := ast.AsList()
+	if len(list) == 0 || !list[0].IsSymbol() {
+		return nil, false
+	}
+	v := env.Find(list[0].AsSymbol().Name())
+	if !v.IsFn() || !v.AsFn().is_macro {
+		return nil, false
+	}
+	return v.AsFn(), true
+}
 
-		v, err := Read(l)
-		if err != nil {
-			return "", err
+// Expands ast until its head is no longer a macro
+func macroexpand(ast Value, env *Env) (Value, error) {
+	for {
+		macro, ok := is_macro_call(ast, env)
+		if !ok {
+			return ast, nil
 		}
-
-		if evaled, err := Eval(v, env); err == nil {
-			s := Print(evaled)
-			last_print = s
+		if expanded, err := apply_fn(macro, env.dyn_frame(), ast.AsList()[1:]); err == nil {
+			ast = expanded
 		} else {
-			return "", err
+			return NoValue(), err
 		}
 	}
+}
+
+func Print(v Value) string {
+	return PrStr(v, true)
+}
+
+func Rep(source string, env *Env) (string, error) {
+	return rep(source, env, Print)
+}
+
+func rep(source string, env *Env, print func(Value) string) (string, error) {
+	forms, err := ReadAll(source)
+	if err != nil {
+		return "", err
+	}
 
+	var last_print string
+	if _, err := eval_forms(forms, env, func(v Value) {
+		last_print = print(v)
+	}); err != nil {
+		return "", err
+	}
 	return last_print, nil
 }
 
+// Evaluates top level forms in order, following ns/in-ns switches between them.
+// Returns the value of the last form, nil when there are none.
+func eval_forms(forms []Value, env *Env, each func(Value)) (Value, error) {
+	res := NewNilList()
+	for _, form := range forms {
+		env = top_level_env(env)
+		if evaled, err := Eval(form, env); err == nil {
+			res = evaled
+			if each != nil {
+				each(evaled)
+			}
+		} else {
+			return NoValue(), err
+		}
+	}
+	return res, nil
+}
+
 func Repl() error {
 	core_env := NewCoreEnv()
 
@@ -192,7 +455,7 @@ func Repl() error {
 	fmt.Println("type 'exit' or 'quit' to exit REPL")
 
 	for {
-		fmt.Print("smack> ")
+		fmt.Printf("smack:%s> ", current_ns_name(core_env))
 
 		if text, err := read_input(); err == nil {
 			text := strings.TrimSpace(text)
@@ -204,7 +467,7 @@ func Repl() error {
 				fmt.Println("Exiting Smack Repl...")
 				os.Exit(0)
 			}
-			if src, err := Rep(text, core_env); err == nil {
+			if src, err := rep(text, core_env, repl_print); err == nil {
 				fmt.Println(src)
 			} else {
 				fmt.Println(err)
@@ -216,6 +479,10 @@ func Repl() error {
 
 }
 
+func repl_print(v Value) string {
+	return PPrint(v, DefaultPPrintOptions)
+}
+
 func read_input() (string, error) {
 	reader := bufio.NewReader(os.Stdin)
 
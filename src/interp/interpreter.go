@@ -0,0 +1,212 @@
+package interp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"reflect"
+)
+
+// An Interpreter evaluates scripts in namespaces of its own, with the streams,
+// files, builtins and globals it was created with. Interpreters share nothing but
+// the Go packages registered with RegisterPackage, so a service can run one per
+// tenant or request.
+//
+//	it, err := interp.NewInterpreter(
+//		interp.WithStdout(&out),
+//		interp.WithFS(os.DirFS("scripts")),
+//		interp.WithBuiltins("+", "str", "println"),
+//		interp.WithGlobals(map[string]any{"user": name}),
+//	)
+//	_, err = it.EvalString(`(println (str "hello " user))`)
+//	res, err := it.Call("greet", "world")
+//
+// Special forms like def, fn, let and ns are part of the language and always
+// available, WithBuiltins only limits the core fns. Go packages can reach anything
+// the host can, so an Interpreter created WithBuiltins or WithFS has none unless
+// they are allowed WithGoPackages.
+
+type Interpreter struct {
+	// root env of the user namespace
+	env *Env
+}
+
+type interpreter_options struct {
+	sys system
+	// core fns kept, every one when nil
+	builtins map[string]bool
+	globals  map[string]any
+	// set by WithGoPackages
+	go_packages bool
+}
+
+type InterpreterOption func(*interpreter_options)
+
+// Stream read-line reads from, os.Stdin by default
+func WithStdin(r io.Reader) InterpreterOption {
+	return func(o *interpreter_options) {
+		o.sys.stdin = bufio.NewReader(r)
+	}
+}
+
+// Stream println, prn, pprint and doc write to, os.Stdout by default
+func WithStdout(w io.Writer) InterpreterOption {
+	return func(o *interpreter_options) {
+		o.sys.stdout = w
+	}
+}
+
+// Stream warnings are written to, os.Stderr by default
+func WithStderr(w io.Writer) InterpreterOption {
+	return func(o *interpreter_options) {
+		o.sys.stderr = w
+	}
+}
+
+// Files load-file, require, slurp and spit reach, the files of the OS by default.
+// Paths are rooted at the root of fsys, which is also the working directory. spit
+// fails unless fsys is a WriteFileFS.
+func WithFS(fsys fs.FS) InterpreterOption {
+	return func(o *interpreter_options) {
+		o.sys.fsys = fsys
+	}
+}
+
+// Keeps only the core fns called names, every one by default
+func WithBuiltins(names ...string) InterpreterOption {
+	return func(o *interpreter_options) {
+		o.builtins = make(map[string]bool, len(names))
+		for _, name := range names {
+			o.builtins[name] = true
+		}
+	}
+}
+
+// Only the Go packages called names (go.strings...) can be referred to. Every
+// registered one by default, none when the Interpreter is created WithBuiltins or
+// WithFS.
+func WithGoPackages(names ...string) InterpreterOption {
+	return func(o *interpreter_options) {
+		o.go_packages = true
+		o.sys.go_packages = make(map[string]bool, len(names))
+		for _, name := range names {
+			o.sys.go_packages[name] = true
+		}
+	}
+}
+
+// Defines every name of globals in smack.core, visible from every namespace. Values
+// are bound as is, funcs as with RegisterFunc and other Go values converted as
+// their results are.
+func WithGlobals(globals map[string]any) InterpreterOption {
+	return func(o *interpreter_options) {
+		for name, x := range globals {
+			o.globals[name] = x
+		}
+	}
+}
+
+func NewInterpreter(opts ...InterpreterOption) (*Interpreter, error) {
+	o := interpreter_options{sys: *os_system(), globals: make(map[string]any)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !o.go_packages && (o.builtins != nil || o.sys.fsys != nil) {
+		o.sys.go_packages = make(map[string]bool)
+	}
+	sys := o.sys
+	env := new_core_env(&sys)
+	core := env.ns.registry.core
+
+	if o.builtins != nil {
+		for name := range o.builtins {
+			if _, ok := core.data[name]; !ok {
+				return nil, fmt.Errorf("NewInterpreter => Unknown builtin: %s", name)
+			}
+		}
+		for name := range core.data {
+			if !o.builtins[name] {
+				delete(core.data, name)
+				core.SetMeta(name, nil)
+			}
+		}
+	}
+	for name, x := range o.globals {
+		v, err := global_value(name, x)
+		if err != nil {
+			return nil, fmt.Errorf("NewInterpreter => Global %s: %s", name, err)
+		}
+		core.Set(name, v)
+		if v.IsFn() {
+			core.SetMeta(name, v.meta)
+		}
+	}
+	return &Interpreter{env}, nil
+}
+
+// The Value a Go value is bound as, see WithGlobals
+func global_value(name string, x any) (Value, error) {
+	if v, ok := x.(Value); ok {
+		return v, nil
+	}
+	rv := reflect.ValueOf(x)
+	if rv.Kind() == reflect.Func && !rv.IsNil() {
+		return new_go_fn(name, rv), nil
+	}
+	return from_go(rv)
+}
+
+// The root env of the namespace top level forms are evaluated in
+func (it *Interpreter) Env() *Env {
+	return top_level_env(it.env)
+}
+
+// Evaluates every form of source in the current namespace. Returns the value of
+// the last form, an error value is returned as the error. So is a panic.
+func (it *Interpreter) EvalString(source string) (res Value, err error) {
+	defer recover_error(&err)
+	forms, err := ReadAll(source)
+	if err != nil {
+		return NoValue(), err
+	}
+	return value_result(eval_forms(forms, it.env, nil))
+}
+
+// Evaluates every form of the file at path, see LoadFile
+func (it *Interpreter) LoadFile(path string) (res Value, err error) {
+	defer recover_error(&err)
+	return value_result(LoadFile(path, it.env))
+}
+
+// Calls the fn fn_name resolves to in the current namespace with args, which are
+// converted like globals
+func (it *Interpreter) Call(fn_name string, args ...any) (res Value, err error) {
+	defer recover_error(&err)
+	f := it.Env().Find(fn_name)
+	if f.IsNone() {
+		return NoValue(), fmt.Errorf("Call => Unable to resolve symbol: %s", fn_name)
+	}
+	vals := make([]Value, len(args))
+	for i, arg := range args {
+		v, err := global_value("", arg)
+		if err != nil {
+			return NoValue(), fmt.Errorf("Call => Argument %d: %s", i+1, err)
+		}
+		vals[i] = v
+	}
+	return value_result(Call(f, vals))
+}
+
+func recover_error(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("panic: %v", r)
+	}
+}
+
+func value_result(res Value, err error) (Value, error) {
+	if err == nil && res.IsError() && res.val != nil {
+		return NoValue(), res.AsError()
+	}
+	return res, err
+}
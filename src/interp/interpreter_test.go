@@ -0,0 +1,135 @@
+package interp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestInterpreterIsolation(t *testing.T) {
+	a, err := NewInterpreter(WithGlobals(map[string]any{"tenant": "a"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewInterpreter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.EvalString(`(def x 1) (def counter (ref 0)) (ns tenant.a) (def y 2) (in-ns 'user)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.EvalString(`(def counter (ref 100))`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.EvalString(`(swap! counter (fn (n) (+ n 1)))`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		it     *Interpreter
+		source string
+		want   string
+	}{
+		{a, `x`, "1"},
+		{a, `tenant.a/y`, "2"},
+		{a, `@counter`, "1"},
+		{b, `@counter`, "100"},
+		{a, `tenant`, `"a"`},
+	}
+	for _, tt := range tests {
+		if v, err := tt.it.EvalString(tt.source); err != nil || PrStr(v, true) != tt.want {
+			t.Errorf("%s => %s, %v, expected %s", tt.source, PrStr(v, true), err, tt.want)
+		}
+	}
+	for _, source := range []string{`x`, `tenant.a/y`, `tenant`} {
+		if v, err := b.EvalString(source); err == nil {
+			t.Errorf("%s => %s in another interpreter", source, PrStr(v, true))
+		}
+	}
+	if v, _ := b.EvalString(`(all-ns)`); strings.Contains(PrStr(v, true), "tenant.a") {
+		t.Errorf("(all-ns) => %s, expected no namespace of another interpreter", PrStr(v, true))
+	}
+
+	// redefining a core fn only changes it for the interpreter that did
+	if _, err := a.EvalString(`(in-ns 'smack.core) (def inc (fn (n) n)) (in-ns 'user)`); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := b.EvalString(`(+ 1 2)`); err != nil || PrStr(v, true) != "3" {
+		t.Errorf("(+ 1 2) => %s, %v after another interpreter changed smack.core", PrStr(v, true), err)
+	}
+}
+
+func TestInterpreterSandbox(t *testing.T) {
+	var out bytes.Buffer
+	it, err := NewInterpreter(WithStdout(&out), WithBuiltins("+", "println", "slurp", "spit"), WithFS(fstest.MapFS{
+		"data.txt": {Data: []byte("data")},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(println (+ 1 2))`, "()"},
+		{`(slurp "data.txt")`, `(ok "data")`},
+		// special forms are always there
+		{`(let (x 1) (if x :yes :no))`, ":yes"},
+	}
+	for _, tt := range tests {
+		if v, err := it.EvalString(tt.source); err != nil || PrStr(v, true) != tt.want {
+			t.Errorf("%s => %s, %v, expected %s", tt.source, PrStr(v, true), err, tt.want)
+		}
+	}
+	if out.String() != "3\n" {
+		t.Errorf("println wrote %q", out.String())
+	}
+
+	for _, source := range []string{
+		// left out core fns
+		`(str 1)`,
+		`(go (fn () 1))`,
+		// Go packages
+		`(go.strings/ToUpper "a")`,
+		`(require 'go.os)`,
+	} {
+		if v, err := it.EvalString(source); err == nil {
+			t.Errorf("%s => %s in a sandbox", source, PrStr(v, true))
+		}
+	}
+	// files outside of the FS, and writes to an FS that is not writable
+	for _, source := range []string{`(slurp "/etc/hostname")`, `(slurp "../../etc/hostname")`, `(spit "new.txt" 1)`} {
+		if v, err := it.EvalString(source); err != nil || !v.IsErr() {
+			t.Errorf("%s => %s, %v, expected (err ...)", source, PrStr(v, true), err)
+		}
+	}
+
+	if _, err := NewInterpreter(WithBuiltins("no-such-fn")); err == nil {
+		t.Errorf("NewInterpreter with an unknown builtin succeeded")
+	}
+}
+
+func TestInterpreterGoPackages(t *testing.T) {
+	RegisterPackage("go.interptest", map[string]any{"Upper": strings.ToUpper})
+	tests := []struct {
+		opts []InterpreterOption
+		ok   bool
+	}{
+		{nil, true},
+		{[]InterpreterOption{WithBuiltins("str")}, false},
+		{[]InterpreterOption{WithFS(fstest.MapFS{})}, false},
+		{[]InterpreterOption{WithFS(fstest.MapFS{}), WithGoPackages("go.interptest")}, true},
+		{[]InterpreterOption{WithGoPackages("go.other")}, false},
+	}
+	for i, tt := range tests {
+		it, err := NewInterpreter(tt.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := it.EvalString(`(go.interptest/Upper "a")`)
+		if ok := err == nil && PrStr(v, true) == `"A"`; ok != tt.ok {
+			t.Errorf("case %d: (go.interptest/Upper \"a\") => %s, %v", i, PrStr(v, true), err)
+		}
+	}
+}
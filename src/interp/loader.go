@@ -0,0 +1,226 @@
+package interp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Modules are .smk files holding one namespace each. The namespace a.b-c lives in
+// a/b_c.smk, which is looked up relative to the file requiring it (from the root its
+// own namespace is laid out in, then its directory), then in every directory of
+// SMACK_PATH (separated like PATH), then relative to the working directory.
+//
+// A namespace is loaded once, requiring it again only makes it available, unless
+// :reload is given. Requiring a namespace that is still being loaded further up the
+// chain is an error naming the whole chain.
+
+const module_ext = ".smk"
+
+type load_frame struct {
+	// Namespace being loaded, empty for load-file
+	ns   string
+	file string
+}
+
+func default_search_path() []string {
+	path := make([]string, 0)
+	for _, dir := range filepath.SplitList(os.Getenv("SMACK_PATH")) {
+		if len(dir) > 0 {
+			path = append(path, dir)
+		}
+	}
+	return path
+}
+
+// a.b-c => a/b_c.smk
+func namespace_file(name string) string {
+	rel := strings.ReplaceAll(strings.ReplaceAll(name, "-", "_"), ".", string(filepath.Separator))
+	return rel + module_ext
+}
+
+func (r *Namespaces) loaded_from_file(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.files[name]
+	return ok
+}
+
+// Directory of the innermost file being loaded, the working directory outside of one
+func (r *Namespaces) current_dir() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.loading) == 0 {
+		return "."
+	}
+	return filepath.Dir(r.loading[len(r.loading)-1].file)
+}
+
+// Directory the current namespace is laid out from when it was loaded from its own
+// file, e.g. /src for /src/app/main.smk defining app.main
+func (r *Namespaces) current_root() (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.loading) == 0 {
+		return "", false
+	}
+	file := r.loading[len(r.loading)-1].file
+	rel := string(filepath.Separator) + namespace_file(r.current.name)
+	if !strings.HasSuffix(file, rel) {
+		return "", false
+	}
+	return strings.TrimSuffix(file, rel), true
+}
+
+func (r *Namespaces) find_module(name string) (string, error) {
+	rel := namespace_file(name)
+	dirs := make([]string, 0, len(r.search_path)+3)
+	if root, ok := r.current_root(); ok {
+		dirs = append(dirs, root)
+	}
+	dirs = append(dirs, r.current_dir())
+	dirs = append(dirs, r.search_path...)
+	dirs = append(dirs, ".")
+	for _, dir := range dirs {
+		path := filepath.Join(dir, rel)
+		if r.sys.is_file(path) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("require => Could not find namespace %s, looked for %s in %s", name, rel, strings.Join(dirs, string(filepath.ListSeparator)))
+}
+
+// Errors when name is still being loaded further up the chain. Its namespace
+// exists as soon as the ns form of its file is evaluated, so this has to be checked
+// before looking it up.
+func (r *Namespaces) check_cycle(name string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, frame := range r.loading {
+		if frame.ns == name {
+			return fmt.Errorf("require => Circular require: %s", r.load_chain(name))
+		}
+	}
+	return nil
+}
+
+// Loads the file of namespace name, the file must define it with ns. Go packages
+// registered as name are bound instead, see RegisterPackage
+func (r *Namespaces) load_namespace(name string) (*Namespace, error) {
+	if ns, err := r.go_package(name); ns != nil || err != nil {
+		return ns, err
+	}
+	path, err := r.find_module(name)
+	if err != nil {
+		return nil, err
+	}
+	existed := r.Find(name) != nil
+	if _, err := r.load_file(path, name); err != nil {
+		// a namespace left half loaded would be found by the next require
+		if !existed {
+			r.mu.Lock()
+			delete(r.all, name)
+			r.mu.Unlock()
+		}
+		return nil, err
+	}
+
+	ns := r.Find(name)
+	if ns == nil {
+		return nil, fmt.Errorf("require => %s did not define namespace %s", path, name)
+	}
+	r.mu.Lock()
+	r.files[name] = path
+	r.mu.Unlock()
+	return ns, nil
+}
+
+// a -> b -> c -> a, r.mu must be held
+func (r *Namespaces) load_chain(name string) string {
+	chain := make([]string, 0, len(r.loading)+1)
+	for _, frame := range r.loading {
+		if len(frame.ns) > 0 {
+			chain = append(chain, frame.ns)
+		} else {
+			chain = append(chain, frame.file)
+		}
+	}
+	return strings.Join(append(chain, name), " -> ")
+}
+
+// Evaluates every form of the file at path, starting in the current namespace.
+// The current namespace is restored afterwards, so an ns form in the file only
+// applies to the rest of the file.
+func (r *Namespaces) load_file(path string, ns_name string) (Value, error) {
+	path = r.sys.abs(path)
+	buf, err := r.sys.read_file(path)
+	if err != nil {
+		return NoValue(), err
+	}
+	forms, err := ReadAll(string(buf))
+	if err != nil {
+		return NoValue(), fmt.Errorf("%s: %s", path, err)
+	}
+	return r.run_file(path, ns_name, func(env *Env) (Value, error) {
+		return eval_forms(forms, env, nil)
+	})
+}
+
+// Runs the forms of the file at path, which must be absolute, with the file
+// pushed on the loading stack and the current namespace restored afterwards
+func (r *Namespaces) run_file(path string, ns_name string, run func(env *Env) (Value, error)) (Value, error) {
+	r.mu.Lock()
+	for _, frame := range r.loading {
+		if frame.file == path {
+			chain := r.load_chain(path)
+			r.mu.Unlock()
+			return NoValue(), fmt.Errorf("load => Circular load: %s", chain)
+		}
+	}
+	r.loading = append(r.loading, load_frame{ns_name, path})
+	prev := r.current
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		r.loading = r.loading[:len(r.loading)-1]
+		r.current = prev
+		r.mu.Unlock()
+	}()
+
+	res, err := run(prev.env)
+	if err != nil {
+		return NoValue(), fmt.Errorf("%s: %s", path, err)
+	}
+	return res, nil
+}
+
+// Loads a script file into the namespace registry of env, relative paths are
+// resolved from the working directory. Returns the value of the last form.
+func LoadFile(path string, env *Env) (Value, error) {
+	if env.ns == nil {
+		return NoValue(), fmt.Errorf("load => No namespace registry in this environment")
+	}
+	return env.ns.registry.load_file(path, "")
+}
+
+// (load-file path), relative paths are resolved from the directory of the file
+// being loaded
+func eval_load_file(r *Namespaces, vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	path, err := vs[0].TryString()
+	if err != nil {
+		return NewError(err)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.current_dir(), path)
+	}
+	if res, err := r.load_file(path, ""); err == nil {
+		return res
+	} else {
+		return NewError(err)
+	}
+}
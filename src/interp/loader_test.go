@@ -0,0 +1,63 @@
+package interp
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func new_test_interpreter(t *testing.T, files fstest.MapFS) *Interpreter {
+	t.Helper()
+	it, err := NewInterpreter(WithFS(files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return it
+}
+
+func TestRequire(t *testing.T) {
+	files := fstest.MapFS{
+		"lib/util.smk": {Data: []byte(`(ns lib.util) (def answer 42)`)},
+	}
+	it := new_test_interpreter(t, files)
+	v, err := it.EvalString(`(require '[lib.util :as u]) u/answer`)
+	if err != nil || v.AsNumber() != 42 {
+		t.Fatalf("require => %s, %v", PrStr(v, true), err)
+	}
+
+	// a loaded namespace is only read again with :reload
+	files["lib/util.smk"] = &fstest.MapFile{Data: []byte(`(ns lib.util) (def answer 43)`)}
+	if v, _ := it.EvalString(`(require 'lib.util) lib.util/answer`); v.AsNumber() != 42 {
+		t.Errorf("require read a loaded namespace again => %s", PrStr(v, true))
+	}
+	if v, _ := it.EvalString(`(require 'lib.util :reload) lib.util/answer`); v.AsNumber() != 43 {
+		t.Errorf("require :reload => %s", PrStr(v, true))
+	}
+}
+
+func TestRequireCycle(t *testing.T) {
+	it := new_test_interpreter(t, fstest.MapFS{
+		"a.smk": {Data: []byte(`(ns a (:require b))`)},
+		"b.smk": {Data: []byte(`(ns b (:require a))`)},
+	})
+	_, err := it.EvalString(`(require 'a)`)
+	if err == nil || !strings.Contains(err.Error(), "Circular require: a -> b -> a") {
+		t.Errorf("require of a cycle => %v", err)
+	}
+}
+
+func TestRequireFailed(t *testing.T) {
+	files := fstest.MapFS{
+		"bad.smk": {Data: []byte(`(ns bad) (def a 1) (undefined-fn)`)},
+	}
+	it := new_test_interpreter(t, files)
+	for i := 0; i < 2; i++ {
+		if _, err := it.EvalString(`(require 'bad)`); err == nil {
+			t.Fatalf("require %d of a failing file succeeded", i+1)
+		}
+	}
+	files["bad.smk"] = &fstest.MapFile{Data: []byte(`(ns bad) (def a 1)`)}
+	if v, err := it.EvalString(`(require 'bad) bad/a`); err != nil || v.AsNumber() != 1 {
+		t.Errorf("require after fixing the file => %s, %v", PrStr(v, true), err)
+	}
+}
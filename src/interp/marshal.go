@@ -0,0 +1,361 @@
+package interp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ToValue and FromValue copy Go data into Values and back, to hand request data to
+// scripts and read their results:
+//
+//	structs          <=> hashmaps keyed by :Field, or by :name for a smack:"name" tag
+//	maps             <=> hashmaps, keys and values converted
+//	slices, arrays   <=> arrays, []byte <=> String
+//	pointers          => what they point to, nil for nil, FromValue allocates them
+//	time.Time        <=> String in RFC 3339 format
+//	numbers, strings, bools, funcs and Values as in convert.go
+//
+// Tags are written like those of encoding/json: smack:"name", smack:"name,omitempty"
+// and smack:"-" to leave a field out, while smack:"-," names it -. The fields of
+// embedded structs without a tag are promoted. Struct fields are read from keyword
+// or string keys. Unlike the conversions of RegisterFunc nothing is kept as a Go
+// object, so a value that is not data is an error, and so is a value that
+// contains itself.
+
+var time_type = reflect.TypeOf(time.Time{})
+
+// Converts the Go data x to a Value
+func ToValue(x any) (Value, error) {
+	v, err := to_value(reflect.ValueOf(x), make(map[visit]bool))
+	if err != nil {
+		return NoValue(), fmt.Errorf("ToValue => %s", err)
+	}
+	return v, nil
+}
+
+// Stores v converted to the type out points to in *out
+func FromValue(v Value, out any) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("FromValue => Expected a non-nil pointer, got: %T", out)
+	}
+	if err := from_value(v, rv.Elem()); err != nil {
+		return fmt.Errorf("FromValue => %s", err)
+	}
+	return nil
+}
+
+// A struct field as a hashmap entry
+type struct_field struct {
+	name      string
+	index     []int
+	key       Value
+	omitempty bool
+}
+
+// struct type => []struct_field
+var struct_field_cache sync.Map
+
+// The exported fields of the struct type t, named by their smack tag
+func struct_fields(t reflect.Type) []struct_field {
+	if cached, ok := struct_field_cache.Load(t); ok {
+		return cached.([]struct_field)
+	}
+	fields := make([]struct_field, 0, t.NumField())
+	seen := make(map[string]bool)
+	promoted := make([]struct_field, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, has_tag := field.Tag.Lookup("smack")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" && !strings.Contains(tag, ",") {
+			continue
+		}
+		if field.Anonymous && !has_tag && field.Type.Kind() == reflect.Struct {
+			for _, inner := range struct_fields(field.Type) {
+				inner.index = append([]int{i}, inner.index...)
+				promoted = append(promoted, inner)
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		seen[name] = true
+		fields = append(fields, struct_field{
+			name:      name,
+			index:     []int{i},
+			key:       NewAtom(":" + name),
+			omitempty: has_option(opts, "omitempty"),
+		})
+	}
+	// fields of the struct itself win over promoted ones
+	for _, inner := range promoted {
+		if !seen[inner.name] {
+			seen[inner.name] = true
+			fields = append(fields, inner)
+		}
+	}
+	struct_field_cache.Store(t, fields)
+	return fields
+}
+
+// Reports whether the comma separated tag options opts include opt
+func has_option(opts, opt string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// The value of f in the hashmap or record m, under its keyword or its name
+func field_lookup(m Value, f struct_field) (Value, bool) {
+	if v, ok := map_lookup(m, f.key); ok {
+		return v, true
+	}
+	return map_lookup(m, NewString(f.name))
+}
+
+// A pointer, map or slice being converted, it is cyclic when seen again inside
+type visit struct {
+	ptr uintptr
+	t   reflect.Type
+	len int
+}
+
+func to_value(rv reflect.Value, seen map[visit]bool) (Value, error) {
+	if !rv.IsValid() {
+		return NewNilList(), nil
+	}
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		if rv.IsNil() {
+			break
+		}
+		key := visit{rv.Pointer(), rv.Type(), 0}
+		if rv.Kind() == reflect.Slice {
+			key.len = rv.Len()
+		}
+		if seen[key] {
+			return NoValue(), fmt.Errorf("Encountered a cyclic value of type %s", rv.Type())
+		}
+		seen[key] = true
+		defer delete(seen, key)
+	}
+	switch rv.Type() {
+	case value_type:
+		return rv.Interface().(Value), nil
+	case time_type:
+		return NewString(rv.Interface().(time.Time).Format(time.RFC3339Nano)), nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return from_go(rv)
+
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice {
+			if rv.IsNil() {
+				return NewNilList(), nil
+			}
+			if rv.Type().Elem().Kind() == reflect.Uint8 {
+				return NewString(string(rv.Bytes())), nil
+			}
+		}
+		vec := EmptyVector()
+		for i := 0; i < rv.Len(); i++ {
+			e, err := to_value(rv.Index(i), seen)
+			if err != nil {
+				return NoValue(), fmt.Errorf("Element %d: %s", i, err)
+			}
+			vec = vec.Conj(e)
+		}
+		return NewVector(vec), nil
+
+	case reflect.Map:
+		if rv.IsNil() {
+			return NewNilList(), nil
+		}
+		m := EmptyHashMap()
+		iter := rv.MapRange()
+		for iter.Next() {
+			k, err := to_value(iter.Key(), seen)
+			if err != nil {
+				return NoValue(), fmt.Errorf("Key %v: %s", iter.Key(), err)
+			}
+			v, err := to_value(iter.Value(), seen)
+			if err != nil {
+				return NoValue(), fmt.Errorf("Value of %s: %s", PrStr(k, true), err)
+			}
+			m = m.Assoc(k, v)
+		}
+		return NewHashMap(m), nil
+
+	case reflect.Struct:
+		m := EmptyHashMap()
+		for _, f := range struct_fields(rv.Type()) {
+			fv := rv.FieldByIndex(f.index)
+			if f.omitempty && fv.IsZero() {
+				continue
+			}
+			v, err := to_value(fv, seen)
+			if err != nil {
+				return NoValue(), fmt.Errorf("Field %s: %s", f.name, err)
+			}
+			m = m.Assoc(f.key, v)
+		}
+		return NewHashMap(m), nil
+
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return NewNilList(), nil
+		}
+		if rv.Type().Implements(error_type) {
+			return NewError(rv.Interface().(error)), nil
+		}
+		return to_value(rv.Elem(), seen)
+
+	case reflect.Func:
+		return from_go(rv)
+
+	default:
+		return NoValue(), fmt.Errorf("Go values of type %s are not data", rv.Type())
+	}
+}
+
+// Sets rv, which must be settable, to v converted to its type
+func from_value(v Value, rv reflect.Value) error {
+	t := rv.Type()
+	if v.IsObject() {
+		if o, ok := v.AsObject().assignable_to(t); ok {
+			rv.Set(o)
+			return nil
+		}
+	}
+	switch t {
+	case value_type:
+		rv.Set(reflect.ValueOf(v))
+		return nil
+	case time_type:
+		if !v.IsString() {
+			return fmt.Errorf("Expected a String in RFC 3339 format for %s, got: %s", t, v.TypeString())
+		}
+		tm, err := time.Parse(time.RFC3339Nano, v.AsString())
+		if err != nil {
+			return fmt.Errorf("Expected a String in RFC 3339 format for %s, got: %s", t, PrStr(v, true))
+		}
+		rv.Set(reflect.ValueOf(tm))
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		if is_nil(v) {
+			rv.Set(reflect.Zero(t))
+			return nil
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.New(t.Elem()))
+		}
+		return from_value(v, rv.Elem())
+
+	case reflect.Struct:
+		if !is_map_like(v) {
+			return convert_error(v, t)
+		}
+		for _, f := range struct_fields(t) {
+			val, ok := field_lookup(v, f)
+			if !ok {
+				continue
+			}
+			if err := from_value(val, rv.FieldByIndex(f.index)); err != nil {
+				return fmt.Errorf("Field %s: %s", f.name, err)
+			}
+		}
+		return nil
+
+	case reflect.Slice:
+		if is_nil(v) {
+			rv.Set(reflect.Zero(t))
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 && v.IsString() {
+			rv.Set(reflect.ValueOf([]byte(v.AsString())).Convert(t))
+			return nil
+		}
+		elems, ok := go_elements(v)
+		if !ok {
+			return convert_error(v, t)
+		}
+		res := reflect.MakeSlice(t, len(elems), len(elems))
+		for i, e := range elems {
+			if err := from_value(e, res.Index(i)); err != nil {
+				return fmt.Errorf("Element %d: %s", i, err)
+			}
+		}
+		rv.Set(res)
+		return nil
+
+	case reflect.Array:
+		elems, ok := go_elements(v)
+		if !ok {
+			return convert_error(v, t)
+		}
+		if len(elems) != t.Len() {
+			return fmt.Errorf("Expected %d elements for %s, got: %d", t.Len(), t, len(elems))
+		}
+		for i, e := range elems {
+			if err := from_value(e, rv.Index(i)); err != nil {
+				return fmt.Errorf("Element %d: %s", i, err)
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		if is_nil(v) {
+			rv.Set(reflect.Zero(t))
+			return nil
+		}
+		if !is_map_like(v) {
+			return convert_error(v, t)
+		}
+		res := reflect.MakeMap(t)
+		var err error
+		each_entry(v, func(key Value, val Value) bool {
+			kv, vv := reflect.New(t.Key()).Elem(), reflect.New(t.Elem()).Elem()
+			if err = from_value(key, kv); err != nil {
+				err = fmt.Errorf("Key %s: %s", PrStr(key, true), err)
+				return false
+			}
+			if err = from_value(val, vv); err != nil {
+				err = fmt.Errorf("Value of %s: %s", PrStr(key, true), err)
+				return false
+			}
+			res.SetMapIndex(kv, vv)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		rv.Set(res)
+		return nil
+
+	default:
+		res, err := to_go(v, t)
+		if err != nil {
+			return err
+		}
+		rv.Set(res)
+		return nil
+	}
+}
@@ -0,0 +1,177 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type marshal_base struct {
+	ID      int `smack:"id"`
+	Created time.Time
+}
+
+type marshal_item struct {
+	SKU   string  `smack:"sku"`
+	Price float64 `smack:"price,omitempty"`
+}
+
+type marshal_order struct {
+	marshal_base
+	Customer *string            `smack:"customer"`
+	Items    []marshal_item     `smack:"items"`
+	Counts   map[string]int     `smack:"counts"`
+	Raw      []byte             `smack:"raw"`
+	Pair     [2]int             `smack:"pair"`
+	Tags     map[string][]int8  `smack:"tags,omitempty"`
+	Dash     string             `smack:"-,"`
+	Skipped  string             `smack:"-"`
+	Extra    *marshal_item      `smack:"extra"`
+	Nested   map[string]*string `smack:"nested,omitempty"`
+	private  int
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	name := "ada"
+	in := marshal_order{
+		marshal_base: marshal_base{ID: 7, Created: time.Date(2024, 5, 1, 12, 30, 0, 500, time.UTC)},
+		Customer:     &name,
+		Items:        []marshal_item{{"a-1", 2.5}, {"b-2", 0}},
+		Counts:       map[string]int{"a": 1, "b": 2},
+		Raw:          []byte("bytes"),
+		Pair:         [2]int{3, 4},
+		Dash:         "dash",
+		Skipped:      "skipped",
+		private:      1,
+	}
+	v, err := ToValue(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := v.AsHashMap()
+	for _, key := range []string{":id", ":Created", ":customer", ":items", ":counts", ":raw", ":pair", ":-", ":extra"} {
+		if _, ok := m.Get(NewAtom(key)); !ok {
+			t.Errorf("ToValue => no %s in %s", key, PrStr(v, true))
+		}
+	}
+	for _, key := range []string{":Skipped", ":skipped", ":tags", ":nested", ":private", ":marshal_base"} {
+		if _, ok := m.Get(NewAtom(key)); ok {
+			t.Errorf("ToValue => unexpected %s in %s", key, PrStr(v, true))
+		}
+	}
+	if items, _ := m.Get(NewAtom(":items")); !strings.Contains(PrStr(items, true), `:sku "b-2"`) || strings.Contains(PrStr(items, true), ":price 0") {
+		t.Errorf("ToValue => items %s, expected omitempty to leave out the zero price", PrStr(items, true))
+	}
+
+	var out marshal_order
+	if err := FromValue(v, &out); err != nil {
+		t.Fatal(err)
+	}
+	in.Skipped = ""
+	in.private = 0
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("FromValue => %+v, expected %+v", out, in)
+	}
+}
+
+func TestFromValueKeys(t *testing.T) {
+	// fields are read from keywords or strings
+	v, err := ReadAll(`{"sku" "x" :price 3}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Eval(v[0], NewCoreEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var item marshal_item
+	if err := FromValue(m, &item); err != nil {
+		t.Fatal(err)
+	}
+	if item != (marshal_item{"x", 3}) {
+		t.Errorf("FromValue => %+v", item)
+	}
+}
+
+func TestFromValueErrors(t *testing.T) {
+	items := EmptyVector().Conj(NewHashMap(EmptyHashMap().Assoc(NewAtom(":sku"), NewNumber(1))))
+	v := NewHashMap(EmptyHashMap().Assoc(NewAtom(":items"), NewVector(items)))
+	var out marshal_order
+	err := FromValue(v, &out)
+	if err == nil || !strings.HasPrefix(err.Error(), "FromValue => Field items: Element 0: Field sku: ") {
+		t.Errorf("FromValue => %v", err)
+	}
+
+	if err := FromValue(v, out); err == nil {
+		t.Errorf("FromValue into a non-pointer succeeded")
+	}
+	var n int8
+	if err := FromValue(NewNumber(300), &n); err == nil {
+		t.Errorf("FromValue of 300 into an int8 succeeded")
+	}
+	if err := FromValue(NewNumber(1.5), &n); err == nil {
+		t.Errorf("FromValue of 1.5 into an int8 succeeded")
+	}
+}
+
+type marshal_node struct {
+	Value int
+	Next  *marshal_node
+}
+
+func TestToValueCycles(t *testing.T) {
+	node := &marshal_node{Value: 1}
+	node.Next = node
+	if _, err := ToValue(node); err == nil || !strings.Contains(err.Error(), "cyclic") {
+		t.Errorf("ToValue of a cyclic list => %v", err)
+	}
+
+	self := map[string]any{}
+	self["self"] = self
+	if _, err := ToValue(self); err == nil || !strings.Contains(err.Error(), "cyclic") {
+		t.Errorf("ToValue of a map holding itself => %v", err)
+	}
+
+	// the same pointer twice is not a cycle
+	shared := &marshal_node{Value: 2}
+	if _, err := ToValue([]*marshal_node{shared, shared}); err != nil {
+		t.Errorf("ToValue of a shared pointer => %s", err)
+	}
+}
+
+type marshal_result struct {
+	Err    error
+	Name   fmt.Stringer
+	Reader io.Reader
+	Note   string `smack:"note,omitempty,other"`
+}
+
+func TestMarshalInterfaces(t *testing.T) {
+	tests := []marshal_result{
+		{},
+		{Err: errors.New("failed"), Note: "note"},
+	}
+	for _, in := range tests {
+		v, err := ToValue(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := v.AsHashMap().Get(NewAtom(":note")); ok != (in.Note != "") {
+			t.Errorf("ToValue => %s, expected omitempty to leave out only an empty note", PrStr(v, true))
+		}
+		var out marshal_result
+		if err := FromValue(v, &out); err != nil {
+			t.Errorf("FromValue(%s) => %s", PrStr(v, true), err)
+			continue
+		}
+		if (out.Err == nil) != (in.Err == nil) || (in.Err != nil && out.Err.Error() != in.Err.Error()) ||
+			out.Name != nil || out.Reader != nil || out.Note != in.Note {
+			t.Errorf("FromValue => %+v, expected %+v", out, in)
+		}
+	}
+}
@@ -0,0 +1,438 @@
+package interp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// (match expr
+//   pattern body
+//   pattern :when guard body
+//   ...)
+//
+// Evaluates body of the first clause whose pattern matches the value of expr (and
+// whose guard is truthy), with the pattern's variables bound. Patterns are:
+//
+//	_                    anything
+//	x                    anything, bound to x
+//	1 "s" true :k nil    equal literals, 'sym matches the symbol sym
+//	[a b & rest]         lists and arrays, rest is bound to the remaining elements
+//	(list a b & rest)    lists only
+//	{:k p ...}           hashmaps and records with every key, values matching p
+//	(some p) none        Options
+//	(ok p) (err p)       Results
+//	(Name p ...)         records of type Name, fields in declaration order
+//	(Name)               any value whose type is Name (Number, String, Point...)
+//
+// Before matching, the clauses are checked for ones that can never be reached
+// (following a catch-all or a pattern covering theirs) and, for Options, Results and
+// Booleans, for variants no clause covers. Both are reported as errors.
+
+type pattern_kind int
+
+const (
+	pat_wild pattern_kind = iota
+	pat_bind
+	pat_literal
+	pat_seq
+	pat_list
+	pat_map
+	pat_type
+	pat_some
+	pat_none
+	pat_ok
+	pat_err
+)
+
+type pattern struct {
+	kind pattern_kind
+	// literal value, map keys are in keys
+	val Value
+	// bound name, type name
+	name  string
+	elems []*pattern
+	// pattern after &, nil when there is none
+	rest *pattern
+	keys []Value
+	// source form, for error messages
+	form Value
+}
+
+type match_clause struct {
+	pat   *pattern
+	guard Value
+	body  Value
+}
+
+func parse_pattern(form Value) (*pattern, error) {
+	p := &pattern{form: form}
+	switch form.Type() {
+	case VAL_SYMBOL:
+		name := form.AsSymbol().Name()
+		switch name {
+		case "_":
+			p.kind = pat_wild
+		case "none":
+			p.kind = pat_none
+		case "&":
+			return nil, fmt.Errorf("match => & must be followed by a single rest pattern")
+		default:
+			p.kind = pat_bind
+			p.name = name
+		}
+		return p, nil
+	case VAL_NUMBER, VAL_STRING, VAL_BOOLEAN, VAL_ATOM:
+		p.kind = pat_literal
+		p.val = form
+		return p, nil
+	case VAL_ARRAY:
+		p.kind = pat_seq
+		return p, parse_seq_patterns(p, form.AsList())
+	case VAL_HASHMAP:
+		p.kind = pat_map
+		kvs := form.AsList()
+		if len(kvs)%2 != 0 {
+			return nil, fmt.Errorf("match => Map pattern needs keys and patterns in pairs: %s", PrStr(form, true))
+		}
+		for i := 1; i < len(kvs); i = i + 2 {
+			sub, err := parse_pattern(kvs[i])
+			if err != nil {
+				return nil, err
+			}
+			p.keys = append(p.keys, kvs[i-1])
+			p.elems = append(p.elems, sub)
+		}
+		return p, nil
+	case VAL_LIST:
+		list := form.AsList()
+		if len(list) == 0 {
+			p.kind = pat_literal
+			p.val = NewNilList()
+			return p, nil
+		}
+		if !list[0].IsSymbol() {
+			return nil, fmt.Errorf("match => List patterns start with list, some, ok, err or a type name, got: %s", PrStr(form, true))
+		}
+		head := list[0].AsSymbol().Name()
+		switch head {
+		case "quot":
+			if len(list) != 2 {
+				return nil, fmt.Errorf("match => Expected a single quoted form: %s", PrStr(form, true))
+			}
+			p.kind = pat_literal
+			p.val = list[1]
+			return p, nil
+		case "list":
+			p.kind = pat_list
+			return p, parse_seq_patterns(p, list[1:])
+		case "some", "ok", "err":
+			if len(list) != 2 {
+				return nil, fmt.Errorf("match => (%s p) takes exactly one pattern: %s", head, PrStr(form, true))
+			}
+			p.kind = map[string]pattern_kind{"some": pat_some, "ok": pat_ok, "err": pat_err}[head]
+			sub, err := parse_pattern(list[1])
+			if err != nil {
+				return nil, err
+			}
+			p.elems = []*pattern{sub}
+			return p, nil
+		default:
+			p.kind = pat_type
+			p.name = head
+			for _, f := range list[1:] {
+				sub, err := parse_pattern(f)
+				if err != nil {
+					return nil, err
+				}
+				p.elems = append(p.elems, sub)
+			}
+			return p, nil
+		}
+	default:
+		return nil, fmt.Errorf("match => Invalid pattern: %s", PrStr(form, true))
+	}
+}
+
+func parse_seq_patterns(p *pattern, forms []Value) error {
+	for i := 0; i < len(forms); i++ {
+		if forms[i].IsSymbol() && forms[i].AsSymbol().Name() == "&" {
+			if i != len(forms)-2 {
+				return fmt.Errorf("match => & must be followed by a single rest pattern: %s", PrStr(p.form, true))
+			}
+			rest, err := parse_pattern(forms[i+1])
+			if err != nil {
+				return err
+			}
+			p.rest = rest
+			return nil
+		}
+		sub, err := parse_pattern(forms[i])
+		if err != nil {
+			return err
+		}
+		p.elems = append(p.elems, sub)
+	}
+	return nil
+}
+
+// Matches v against p, adding the variables it binds to binds
+func (p *pattern) match(v Value, binds map[string]Value) bool {
+	switch p.kind {
+	case pat_wild:
+		return true
+	case pat_bind:
+		binds[p.name] = v
+		return true
+	case pat_literal:
+		if is_nil(p.val) {
+			return is_nil(v)
+		}
+		return p.val.Equals(v)
+	case pat_seq, pat_list:
+		if !v.IsListLike() || (p.kind == pat_list && !v.IsList()) {
+			return false
+		}
+		return p.match_seq(v.AsList(), binds)
+	case pat_map:
+		if !is_map_like(v) {
+			return false
+		}
+		for i, key := range p.keys {
+			var val Value
+			var ok bool
+			if v.IsRecord() {
+				val, ok = v.AsRecord().Get(key)
+			} else {
+				val, ok = v.AsHashMap().Get(key)
+			}
+			if !ok || !p.elems[i].match(val, binds) {
+				return false
+			}
+		}
+		return true
+	case pat_type:
+		if dispatch_type_name(v) != p.name && v.TypeString() != p.name {
+			return false
+		}
+		if len(p.elems) == 0 {
+			return true
+		}
+		if !v.IsRecord() || len(v.AsRecord().vals) != len(p.elems) {
+			return false
+		}
+		for i, sub := range p.elems {
+			if !sub.match(v.AsRecord().vals[i], binds) {
+				return false
+			}
+		}
+		return true
+	case pat_some:
+		return v.IsSomeOption() && p.elems[0].match(v.AsOption().val, binds)
+	case pat_none:
+		return v.IsNoneOption()
+	case pat_ok:
+		return v.IsOk() && p.elems[0].match(v.AsResult().val, binds)
+	case pat_err:
+		return v.IsErr() && p.elems[0].match(v.AsResult().val, binds)
+	default:
+		return false
+	}
+}
+
+func (p *pattern) match_seq(list []Value, binds map[string]Value) bool {
+	if len(list) < len(p.elems) || (p.rest == nil && len(list) != len(p.elems)) {
+		return false
+	}
+	for i, sub := range p.elems {
+		if !sub.match(list[i], binds) {
+			return false
+		}
+	}
+	if p.rest != nil {
+		rest := make([]Value, len(list)-len(p.elems))
+		copy(rest, list[len(p.elems):])
+		return p.rest.match(NewList(rest), binds)
+	}
+	return true
+}
+
+// Matches every value
+func (p *pattern) irrefutable() bool {
+	return p.kind == pat_wild || p.kind == pat_bind
+}
+
+// Every value matching b also matches a
+func (a *pattern) covers(b *pattern) bool {
+	if a.irrefutable() {
+		return true
+	}
+	switch a.kind {
+	case pat_literal:
+		return b.kind == pat_literal && a.val.Equals(b.val) && is_nil(a.val) == is_nil(b.val)
+	case pat_none:
+		return b.kind == pat_none
+	case pat_some, pat_ok, pat_err:
+		return b.kind == a.kind && a.elems[0].covers(b.elems[0])
+	case pat_type:
+		if b.kind != pat_type || b.name != a.name {
+			return false
+		}
+		if len(a.elems) == 0 {
+			return true
+		}
+		if len(a.elems) != len(b.elems) {
+			return false
+		}
+		for i := range a.elems {
+			if !a.elems[i].covers(b.elems[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// Reports clauses that can never match and, for Options, Results and Booleans, the
+// variants no clause matches
+func check_match_clauses(clauses []match_clause) error {
+	for i, c := range clauses {
+		for _, prev := range clauses[:i] {
+			if prev.guard.IsNone() && prev.pat.covers(c.pat) {
+				return fmt.Errorf("match => Unreachable clause %s, already matched by %s", PrStr(c.pat.form, true), PrStr(prev.pat.form, true))
+			}
+		}
+	}
+
+	// variant => covered
+	covered := make(map[string]bool)
+	domain := ""
+	for _, c := range clauses {
+		if c.guard.IsSome() {
+			continue
+		}
+		p := c.pat
+		if p.irrefutable() {
+			return nil
+		}
+		switch {
+		case p.kind == pat_none:
+			domain = "Option"
+			covered["none"] = true
+		case p.kind == pat_some && p.elems[0].irrefutable():
+			domain = "Option"
+			covered["(some _)"] = true
+		case p.kind == pat_ok && p.elems[0].irrefutable():
+			domain = "Result"
+			covered["(ok _)"] = true
+		case p.kind == pat_err && p.elems[0].irrefutable():
+			domain = "Result"
+			covered["(err _)"] = true
+		case p.kind == pat_literal && p.val.IsBool():
+			domain = "Boolean"
+			covered[PrStr(p.val, true)] = true
+		}
+	}
+
+	variants := map[string][]string{
+		"Option":  {"(some _)", "none"},
+		"Result":  {"(ok _)", "(err _)"},
+		"Boolean": {"true", "false"},
+	}[domain]
+	// only a match over a single closed domain can be checked
+	for _, c := range clauses {
+		if !pattern_in_domain(c.pat, domain) {
+			return nil
+		}
+	}
+	missing := make([]string, 0)
+	for _, v := range variants {
+		if !covered[v] {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("match => Non-exhaustive patterns, missing: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func pattern_in_domain(p *pattern, domain string) bool {
+	switch domain {
+	case "Option":
+		return p.kind == pat_some || p.kind == pat_none
+	case "Result":
+		return p.kind == pat_ok || p.kind == pat_err
+	case "Boolean":
+		return p.kind == pat_literal && p.val.IsBool()
+	default:
+		return false
+	}
+}
+
+func parse_match_clauses(forms []Value) ([]match_clause, error) {
+	clauses := make([]match_clause, 0, len(forms)/2)
+	for i := 0; i < len(forms); {
+		pat, err := parse_pattern(forms[i])
+		if err != nil {
+			return nil, err
+		}
+		c := match_clause{pat: pat}
+		i++
+		if i+1 < len(forms) && forms[i].IsAtom() && forms[i].AsAtom().Name() == ":when" {
+			c.guard = forms[i+1]
+			i += 2
+		}
+		if i >= len(forms) {
+			return nil, fmt.Errorf("match => Missing body for pattern %s", PrStr(pat.form, true))
+		}
+		c.body = forms[i]
+		i++
+		clauses = append(clauses, c)
+	}
+	return clauses, nil
+}
+
+// Returns the body of the matching clause and the env to evaluate it in
+func eval_match(list []Value, env *Env) (Value, *Env, error) {
+	if len(list) < 2 {
+		return NoValue(), nil, fmt.Errorf("match => Expected an expression and clauses")
+	}
+	clauses, err := parse_match_clauses(list[2:])
+	if err != nil {
+		return NoValue(), nil, err
+	}
+	if err := check_match_clauses(clauses); err != nil {
+		return NoValue(), nil, err
+	}
+
+	v, err := Eval(list[1], env)
+	if err != nil {
+		return NoValue(), nil, err
+	}
+	for _, c := range clauses {
+		binds := make(map[string]Value)
+		if !c.pat.match(v, binds) {
+			continue
+		}
+		clause_env := NewEnv(env, nil, nil)
+		for name, val := range binds {
+			clause_env.Set(name, val)
+		}
+		if c.guard.IsSome() {
+			ok, err := Eval(c.guard, clause_env)
+			if err != nil {
+				return NoValue(), nil, err
+			}
+			if ok.IsError() {
+				return NoValue(), nil, ok.AsError()
+			}
+			if ok.IsFalsey() {
+				continue
+			}
+		}
+		return c.body, clause_env, nil
+	}
+	return NoValue(), nil, fmt.Errorf("match => No clause matched value: %s", PrStr(v, true))
+}
@@ -0,0 +1,43 @@
+package interp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		source string
+		// printed result, or a substring of the error when err is set
+		want string
+		err  bool
+	}{
+		{`(match 1 1 :one _ :other)`, ":one", false},
+		{`(match 2 1 :one x (+ x 1))`, "3", false},
+		{`(match [1 2 3] [a b & rest] rest)`, "(3)", false},
+		{`(match (list 1 2) [a] :one [a b] (+ a b))`, "3", false},
+		{`(match {:k 1 :j 2} {:k v} v)`, "1", false},
+		{`(match (some 5) (some x) x none 0)`, "5", false},
+		{`(match (err "e") (ok x) x (err e) e)`, `"e"`, false},
+		{`(match 5 x :when (> x 3) :big _ :small)`, ":big", false},
+		{`(match "s" (Number) :number (String) :string)`, ":string", false},
+		{`(match 1 2 :two)`, "No clause matched", true},
+		{`(match 1 _ :any 1 :one)`, "Unreachable clause", true},
+		{`(match (some 1) (some x) x)`, "none", true},
+	}
+	for _, tt := range tests {
+		it, err := NewInterpreter()
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := it.EvalString(tt.source)
+		switch {
+		case tt.err && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s => %s, %v, expected an error containing %q", tt.source, PrStr(v, true), err, tt.want)
+		case !tt.err && err != nil:
+			t.Errorf("%s => %s", tt.source, err)
+		case !tt.err && PrStr(v, true) != tt.want:
+			t.Errorf("%s => %s, expected %s", tt.source, PrStr(v, true), tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package interp
+
+import "fmt"
+
+// Metadata is an optional hashmap carried alongside a value. It is never part of
+// the value itself, so equality, hashing and printing all ignore it. Only
+// collections, records, symbols, functions and refs can carry metadata.
+
+func (v Value) SupportsMeta() bool {
+	switch v.Type() {
+	case VAL_LIST, VAL_ARRAY, VAL_HASHMAP, VAL_SET, VAL_RECORD, VAL_SYMBOL, VAL_FN, VAL_REF:
+		return true
+	default:
+		return false
+	}
+}
+
+// NOTE :: Returns nil when the value has no metadata
+func (v Value) Meta() *PersistentHashMap {
+	return v.meta
+}
+
+// Returns a copy of v with its metadata replaced, the underlying value is shared
+func (v Value) WithMeta(meta *PersistentHashMap) (Value, error) {
+	if !v.SupportsMeta() {
+		return NoValue(), fmt.Errorf("Values of type %s can not have metadata", v.TypeString())
+	}
+	v.meta = meta
+	return v, nil
+}
+
+func meta_value(v Value) Value {
+	if v.meta == nil {
+		return NewNilList()
+	}
+	return NewHashMap(v.meta)
+}
+
+// Expands the value following ^ in the reader into a metadata map:
+// ^{:k v} as is, ^:kw => {:kw true} and ^Type or ^"Type" => {:tag Type}
+func reader_meta(form Value) (*PersistentHashMap, error) {
+	switch form.Type() {
+	case VAL_HASHMAP:
+		return form.AsHashMap(), nil
+	case VAL_ATOM:
+		return EmptyHashMap().Assoc(form, NewBool(true)), nil
+	case VAL_SYMBOL:
+		fallthrough
+	case VAL_STRING:
+		return EmptyHashMap().Assoc(NewAtom(":tag"), form), nil
+	default:
+		return nil, fmt.Errorf("read_form => Metadata must be a HashMap, Keyword, Symbol or String, got: %s", form.TypeString())
+	}
+}
+
+func merge_meta(base *PersistentHashMap, extra *PersistentHashMap) *PersistentHashMap {
+	if base == nil {
+		return extra
+	}
+	res := base
+	extra.Each(func(key Value, val Value) bool {
+		res = res.Assoc(key, val)
+		return true
+	})
+	return res
+}
+
+func eval_meta(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	return meta_value(vs[0])
+}
+
+func eval_with_meta(vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
+	}
+	var meta *PersistentHashMap
+	if !is_nil(vs[1]) {
+		m, err := vs[1].TryHashMap()
+		if err != nil {
+			return NewError(err)
+		}
+		meta = m
+	}
+	if res, err := vs[0].WithMeta(meta); err == nil {
+		return res
+	} else {
+		return NewError(err)
+	}
+}
+
+// (vary-meta obj f & args) => (with-meta obj (f (meta obj) args...))
+func eval_vary_meta(frame *dyn_frame, vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected at least 2, got: %d", len(vs)))
+	}
+	fn, err := vs[1].TryFn()
+	if err != nil {
+		return NewError(err)
+	}
+	args := append([]Value{meta_value(vs[0])}, vs[2:]...)
+	meta, err := apply_fn(fn, frame, args)
+	if err != nil {
+		return NewError(err)
+	}
+	if meta.IsError() {
+		return meta
+	}
+	return eval_with_meta(vs[0], meta)
+}
@@ -0,0 +1,54 @@
+package interp
+
+import "testing"
+
+func TestMeta(t *testing.T) {
+	env := NewCoreEnv()
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(meta (with-meta [1 2] {:a 1}))`, `{:a 1}`},
+		{`(meta (with-meta {:x 1} {:a 1}))`, `{:a 1}`},
+		{`(meta (with-meta #{1} {:a 1}))`, `{:a 1}`},
+		{`(meta (with-meta (quot (1 2)) {:a 1}))`, `{:a 1}`},
+		{`(meta (with-meta (quot s) {:a 1}))`, `{:a 1}`},
+		{`(meta (with-meta (fn (x) x) {:a 1}))`, `{:a 1}`},
+		{`(meta (with-meta (with-meta [1] {:a 1}) {:b 2}))`, `{:b 2}`},
+		{`(meta [1 2])`, `()`},
+		{`(meta 1)`, `()`},
+		{`(meta (vary-meta (with-meta [1] {:a 1}) assoc :b 2))`, `{:a 1 :b 2}`},
+		{`(meta (vary-meta [1] (fn (m) {:z 1})))`, `{:z 1}`},
+		{`(vary-meta [1] (fn (m) {:z 1}))`, `[1]`},
+		{`(pr-str (with-meta [1] {:a 1}))`, `"[1]"`},
+		// the ^ shorthand
+		{`(meta (quot ^{:doc "x"} sym))`, `{:doc "x"}`},
+		{`(meta (quot ^:private sym))`, `{:private true}`},
+		{`(meta (quot ^String sym))`, `{:tag String}`},
+		{`(meta ^:a ^:b {})`, `{:a true :b true}`},
+		// metadata is ignored by equality and hashing
+		{`(= (with-meta [1 2] {:a 1}) [1 2])`, `true`},
+		{`(= ^:a [1] ^:b [1])`, `true`},
+		{`(= (with-meta (quot s) {:a 1}) (quot s))`, `true`},
+		{`(mget {[1] 1} (with-meta [1] {:a 1}))`, `1`},
+	}
+	for _, tt := range tests {
+		form, err := Read(tt.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := Eval(form, env); err != nil || PrStr(v, true) != tt.want {
+			t.Errorf("%s => %s, %v, expected %s", tt.source, PrStr(v, true), err, tt.want)
+		}
+	}
+
+	for _, source := range []string{`(with-meta 1 {:a 1})`, `(with-meta [1] 2)`, `(vary-meta [1] 2)`} {
+		form, err := Read(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := Eval(form, env); err == nil && !v.IsError() {
+			t.Errorf("%s => %s, expected an error", source, PrStr(v, true))
+		}
+	}
+}
@@ -0,0 +1,463 @@
+package interp
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Multimethods dispatch on the result of a user dispatch fn called with the same
+// arguments:
+//
+//	(defmulti area "doc"? (fn (s) (:kind s)) :default :else?)
+//	(defmethod area :circle (s) (* 3.14 (:r s) (:r s)))
+//	(defmethod area :default (s) 0)
+//
+// A method matches when the dispatch value isa? its dispatch value, so methods can
+// be shared through derive. When several match, the one whose dispatch value isa?
+// the others' wins, prefer-method breaks the remaining ties. The :default method is
+// called when none match.
+//
+// Methods and preferences are persistent maps swapped atomically, like refs, so
+// methods can be added while other goroutines are calling the multimethod.
+
+type MultiFn struct {
+	name        string
+	dispatch    *SmackFn
+	default_val Value
+	hierarchy   *Hierarchy
+	// dispatch value => fn
+	methods atomic.Pointer[PersistentHashMap]
+	// dispatch value => set of dispatch values it is preferred over
+	prefers atomic.Pointer[PersistentHashMap]
+}
+
+func NewMultiFn(name string, dispatch *SmackFn, default_val Value, h *Hierarchy) *MultiFn {
+	m := &MultiFn{name: name, dispatch: dispatch, default_val: default_val, hierarchy: h}
+	m.methods.Store(EmptyHashMap())
+	m.prefers.Store(EmptyHashMap())
+	return m
+}
+
+func (m *MultiFn) AddMethod(dispatch_val Value, f Value) {
+	for {
+		methods := m.methods.Load()
+		if m.methods.CompareAndSwap(methods, methods.Assoc(dispatch_val, f)) {
+			return
+		}
+	}
+}
+
+func (m *MultiFn) RemoveMethod(dispatch_val Value) {
+	for {
+		methods := m.methods.Load()
+		if m.methods.CompareAndSwap(methods, methods.Dissoc(dispatch_val)) {
+			return
+		}
+	}
+}
+
+// Prefers the method for x over the one for y when both match
+func (m *MultiFn) PreferMethod(x Value, y Value) error {
+	if m.prefers_over(y, x) {
+		return fmt.Errorf("prefer-method => %s is already preferred to %s", PrStr(y, true), PrStr(x, true))
+	}
+	for {
+		prefers := m.prefers.Load()
+		over := EmptySet()
+		if set, ok := prefers.Get(x); ok {
+			over = set.AsSet()
+		}
+		if m.prefers.CompareAndSwap(prefers, prefers.Assoc(x, NewSet(over.Conj(y)))) {
+			return nil
+		}
+	}
+}
+
+// x is preferred over y directly, or through the parents of x or y
+func (m *MultiFn) prefers_over(x Value, y Value) bool {
+	prefers := m.prefers.Load()
+	if set, ok := prefers.Get(x); ok && set.AsSet().Contains(y) {
+		return true
+	}
+	for _, parent := range m.hierarchy.Parents(y) {
+		if m.prefers_over(x, parent) {
+			return true
+		}
+	}
+	for _, parent := range m.hierarchy.Parents(x) {
+		if m.prefers_over(parent, y) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MultiFn) dominates(x Value, y Value) bool {
+	return m.prefers_over(x, y) || m.hierarchy.IsA(x, y)
+}
+
+// The method for dispatch_val, falling back to the :default method.
+// NOTE :: Returns NoValue() when there is none
+func (m *MultiFn) find_method(dispatch_val Value) (Value, error) {
+	methods := m.methods.Load()
+	if f, ok := methods.Get(dispatch_val); ok {
+		return f, nil
+	}
+
+	matches := make([]Value, 0)
+	methods.Each(func(key Value, f Value) bool {
+		if m.hierarchy.IsA(dispatch_val, key) {
+			matches = append(matches, key)
+		}
+		return true
+	})
+	// the most specific matches are the ones no other match dominates
+	best := make([]Value, 0, 1)
+	for _, key := range matches {
+		dominated := false
+		for _, other := range matches {
+			if !other.Equals(key) && m.dominates(other, key) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			best = append(best, key)
+		}
+	}
+	if len(best) > 1 {
+		return NoValue(), fmt.Errorf("Multiple methods in multimethod '%s' match dispatch value: %s -> %s and %s, and neither is preferred", m.name, PrStr(dispatch_val, true), PrStr(best[0], true), PrStr(best[1], true))
+	}
+	if len(best) == 1 {
+		f, _ := methods.Get(best[0])
+		return f, nil
+	}
+
+	if f, ok := methods.Get(m.default_val); ok {
+		return f, nil
+	}
+	return NoValue(), nil
+}
+
+func (m *MultiFn) invoke(frame *dyn_frame, args []Value) (Value, error) {
+	dispatch_val, err := apply_fn(m.dispatch, frame, args)
+	if err != nil {
+		return NoValue(), err
+	}
+	if dispatch_val.IsError() {
+		return NoValue(), dispatch_val.AsError()
+	}
+	f, err := m.find_method(dispatch_val)
+	if err != nil {
+		return NoValue(), err
+	}
+	if f.IsNone() {
+		return NoValue(), fmt.Errorf("No method in multimethod '%s' for dispatch value: %s", m.name, PrStr(dispatch_val, true))
+	}
+	return apply_fn(f.AsFn(), frame, args)
+}
+
+func (m *MultiFn) fn_value(doc string) Value {
+	fn := func(frame *dyn_frame, vs ...Value) Value {
+		if res, err := m.invoke(frame, vs); err == nil {
+			return res
+		} else {
+			return NewError(err)
+		}
+	}
+	f := new_core_frame_fn(fn, "()", doc)
+	f.AsFn().name = m.name
+	f.AsFn().multi = m
+	f.meta = f.meta.Dissoc(NewAtom(":arglists"))
+	return f
+}
+
+// (defmulti name "doc"? dispatch-fn :default val?)
+// A name already bound to a multimethod in env is left as is, so reloading a file
+// keeps its methods.
+func eval_defmulti(list []Value, env *Env) (Value, error) {
+	if len(list) < 3 {
+		return NoValue(), fmt.Errorf("defmulti => Expected a name and a dispatch fn")
+	}
+	sym, err := list[1].TrySymbol()
+	if err != nil {
+		return NoValue(), err
+	}
+	name := sym.Name()
+	if existing, ok := env.data[name]; ok && existing.IsFn() && existing.AsFn().multi != nil {
+		return existing, nil
+	}
+
+	rest := list[2:]
+	doc := ""
+	if len(rest) > 1 && rest[0].IsString() {
+		doc = rest[0].AsString()
+		rest = rest[1:]
+	}
+	dispatch, err := Eval(rest[0], env)
+	if err != nil {
+		return NoValue(), err
+	}
+	dispatch_fn, err := dispatch.TryFn()
+	if err != nil {
+		return NoValue(), fmt.Errorf("defmulti => Expected a dispatch fn: %s", err)
+	}
+
+	default_val := NewAtom(":default")
+	opts := rest[1:]
+	for i := 1; i < len(opts); i = i + 2 {
+		if !opts[i-1].IsAtom() || opts[i-1].AsAtom().Name() != ":default" {
+			return NoValue(), fmt.Errorf("defmulti => Unknown option: %s", PrStr(opts[i-1], true))
+		}
+		if default_val, err = Eval(opts[i], env); err != nil {
+			return NoValue(), err
+		}
+	}
+
+	if env.ns == nil {
+		return NoValue(), fmt.Errorf("defmulti => No namespace registry in this environment")
+	}
+	multi := NewMultiFn(name, dispatch_fn, default_val, env.ns.registry.hierarchy)
+	f := multi.fn_value(doc)
+	env.Set(name, f)
+	env.SetMeta(name, f.meta)
+	return f, nil
+}
+
+func eval_multi_symbol(form Value, env *Env) (*MultiFn, error) {
+	v, err := Eval(form, env)
+	if err != nil {
+		return nil, err
+	}
+	if !v.IsFn() || v.AsFn().multi == nil {
+		return nil, fmt.Errorf("%s is not a multimethod", PrStr(form, true))
+	}
+	return v.AsFn().multi, nil
+}
+
+// (defmethod name dispatch-val (params) body)
+func eval_defmethod(list []Value, env *Env) (Value, error) {
+	if len(list) < 5 {
+		return NoValue(), fmt.Errorf("defmethod => Expected a multimethod, a dispatch value, parameters and a body")
+	}
+	multi, err := eval_multi_symbol(list[1], env)
+	if err != nil {
+		return NoValue(), fmt.Errorf("defmethod => %s", err)
+	}
+	dispatch_val, err := Eval(list[2], env)
+	if err != nil {
+		return NoValue(), err
+	}
+	f, err := new_user_fn(list[3:], env, nil)
+	if err != nil {
+		return NoValue(), fmt.Errorf("defmethod => %s", err)
+	}
+	f.AsFn().name = multi.name
+	multi.AddMethod(dispatch_val, f)
+	return f, nil
+}
+
+func multi_arg(v Value) (*MultiFn, error) {
+	if !v.IsFn() || v.AsFn().multi == nil {
+		return nil, fmt.Errorf("value: %s::%s is not a multimethod", PrStr(v, true), v.TypeString())
+	}
+	return v.AsFn().multi, nil
+}
+
+// (prefer-method multi x y)
+func eval_prefer_method(vs ...Value) Value {
+	if len(vs) < 3 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 3, got: %d", len(vs)))
+	}
+	multi, err := multi_arg(vs[0])
+	if err != nil {
+		return NewError(err)
+	}
+	if err := multi.PreferMethod(vs[1], vs[2]); err != nil {
+		return NewError(err)
+	}
+	return vs[0]
+}
+
+// (remove-method multi dispatch-val)
+func eval_remove_method(vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
+	}
+	multi, err := multi_arg(vs[0])
+	if err != nil {
+		return NewError(err)
+	}
+	multi.RemoveMethod(vs[1])
+	return vs[0]
+}
+
+// (methods multi) => {dispatch-val fn}
+func eval_methods(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	multi, err := multi_arg(vs[0])
+	if err != nil {
+		return NewError(err)
+	}
+	return NewHashMap(multi.methods.Load())
+}
+
+// (get-method multi dispatch-val), the method that would be called, or nil
+func eval_get_method(vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
+	}
+	multi, err := multi_arg(vs[0])
+	if err != nil {
+		return NewError(err)
+	}
+	f, err := multi.find_method(vs[1])
+	if err != nil {
+		return NewError(err)
+	}
+	if f.IsNone() {
+		return NewNilList()
+	}
+	return f
+}
+
+// Hierarchy of tags built with derive. A tag isa? itself, every tag it was derived
+// from and their ancestors. Arrays of tags isa? arrays of the same length whose
+// elements they isa?.
+type Hierarchy struct {
+	// tag => set of parent tags
+	parents atomic.Pointer[PersistentHashMap]
+}
+
+func NewHierarchy() *Hierarchy {
+	h := &Hierarchy{}
+	h.parents.Store(EmptyHashMap())
+	return h
+}
+
+func (h *Hierarchy) Parents(tag Value) []Value {
+	if set, ok := h.parents.Load().Get(tag); ok {
+		return set.AsSet().Slice()
+	}
+	return nil
+}
+
+// Every ancestor of tag, nearest first
+func (h *Hierarchy) Ancestors(tag Value) []Value {
+	res := make([]Value, 0)
+	seen := EmptySet()
+	queue := h.Parents(tag)
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if seen.Contains(next) {
+			continue
+		}
+		seen = seen.Conj(next)
+		res = append(res, next)
+		queue = append(queue, h.Parents(next)...)
+	}
+	return res
+}
+
+func (h *Hierarchy) IsA(child Value, parent Value) bool {
+	if child.Equals(parent) {
+		return true
+	}
+	if child.IsArray() && parent.IsArray() {
+		left, right := child.AsList(), parent.AsList()
+		if len(left) != len(right) {
+			return false
+		}
+		for i := range left {
+			if !h.IsA(left[i], right[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, ancestor := range h.Ancestors(child) {
+		if ancestor.Equals(parent) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Hierarchy) Derive(child Value, parent Value) error {
+	if child.Equals(parent) {
+		return fmt.Errorf("derive => Can not derive %s from itself", PrStr(child, true))
+	}
+	if h.IsA(parent, child) {
+		return fmt.Errorf("derive => Cyclic derivation: %s already isa? %s", PrStr(parent, true), PrStr(child, true))
+	}
+	for {
+		parents := h.parents.Load()
+		set := EmptySet()
+		if existing, ok := parents.Get(child); ok {
+			set = existing.AsSet()
+		}
+		if h.parents.CompareAndSwap(parents, parents.Assoc(child, NewSet(set.Conj(parent)))) {
+			return nil
+		}
+	}
+}
+
+func (h *Hierarchy) Underive(child Value, parent Value) {
+	for {
+		parents := h.parents.Load()
+		existing, ok := parents.Get(child)
+		if !ok {
+			return
+		}
+		set := existing.AsSet().Disj(parent)
+		next := parents.Assoc(child, NewSet(set))
+		if set.Len() == 0 {
+			next = parents.Dissoc(child)
+		}
+		if h.parents.CompareAndSwap(parents, next) {
+			return
+		}
+	}
+}
+
+func eval_derive(h *Hierarchy, vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
+	}
+	if err := h.Derive(vs[0], vs[1]); err != nil {
+		return NewError(err)
+	}
+	return NewNilList()
+}
+
+func eval_underive(h *Hierarchy, vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
+	}
+	h.Underive(vs[0], vs[1])
+	return NewNilList()
+}
+
+func eval_isa(h *Hierarchy, vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
+	}
+	return NewBool(h.IsA(vs[0], vs[1]))
+}
+
+func eval_parents(h *Hierarchy, vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	return NewSet(NewPersistentSet(h.Parents(vs[0])))
+}
+
+func eval_ancestors(h *Hierarchy, vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	return NewSet(NewPersistentSet(h.Ancestors(vs[0])))
+}
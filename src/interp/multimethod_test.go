@@ -0,0 +1,56 @@
+package interp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMultimethods(t *testing.T) {
+	it, err := NewInterpreter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := it.EvalString(`
+		(defmulti area (fn (s) (:kind s)))
+		(defmethod area :circle (s) (* 3 (:r s) (:r s)))
+		(defmethod area :square (s) (* (:side s) (:side s)))`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := it.EvalString(`(area {:kind :tri})`); err == nil || !strings.Contains(err.Error(), "No method in multimethod 'area' for dispatch value: :tri") {
+		t.Errorf("area of a :tri without a default => %v", err)
+	}
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(area {:kind :circle :r 2})`, `12`},
+		{`(area {:kind :square :side 3})`, `9`},
+		{`(defmethod area :default (s) 0) (area {:kind :tri})`, `0`},
+		{`(defmulti other (fn (x) x) :default :else) (defmethod other :else (x) "else") (other 1)`, `"else"`},
+		// hierarchies
+		{`(derive :rect :shape) (derive :square :rect) (isa? :square :shape)`, `true`},
+		{`(isa? :shape :square)`, `false`},
+		{`(isa? [:square :x] [:shape :x])`, `true`},
+		{`(parents :square)`, `#{:rect}`},
+		{`(ancestors :square)`, `#{:rect :shape}`},
+		{`(defmulti kind (fn (x) x)) (defmethod kind :shape (x) "shape") (kind :square)`, `"shape"`},
+		// the most specific dispatch value wins
+		{`(defmethod kind :rect (x) "rect") (kind :square)`, `"rect"`},
+		{`(underive :square :rect) (isa? :square :shape)`, `false`},
+		// prefer-method breaks ties
+		{`(derive :both :a) (derive :both :b) (defmulti amb (fn (x) x)) (defmethod amb :a (x) "a") (defmethod amb :b (x) "b") (prefer-method amb :b :a) (amb :both)`, `"b"`},
+		{`(methods amb)`, `{:a #<fn amb> :b #<fn amb>}`},
+		{`(remove-method amb :b) (amb :both)`, `"a"`},
+	}
+	for _, tt := range tests {
+		if v, err := it.EvalString(tt.source); err != nil || PrStr(v, true) != tt.want {
+			t.Errorf("%s => %s, %v, expected %s", tt.source, PrStr(v, true), err, tt.want)
+		}
+	}
+
+	source := `(derive :both2 :a) (derive :both2 :b) (defmulti amb2 (fn (x) x)) (defmethod amb2 :a (x) "a") (defmethod amb2 :b (x) "b") (amb2 :both2)`
+	if _, err := it.EvalString(source); err == nil || !strings.Contains(err.Error(), "neither is preferred") {
+		t.Errorf("ambiguous dispatch => %v", err)
+	}
+}
@@ -0,0 +1,423 @@
+package interp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Every namespace has its own root Env whose outer is the core env, so names def'd
+// in one namespace never clobber another's. Other namespaces are reached with
+// qualified symbols (ns/name), through aliases (:as) or by referring names into
+// the namespace (:refer). The registry keeps track of the namespace top level
+// forms are evaluated in, which ns and in-ns switch.
+
+const (
+	core_ns_name = "smack.core"
+	user_ns_name = "user"
+)
+
+type Namespace struct {
+	name     string
+	env      *Env
+	registry *Namespaces
+	// alias => namespace
+	aliases map[string]*Namespace
+	// referred name => namespace it is def'd in
+	refers map[string]*Namespace
+	// namespaces whose every name is referred
+	refer_all []*Namespace
+	// record types defined in the namespace by name
+	records map[string]*RecordType
+}
+
+type Namespaces struct {
+	mu      sync.RWMutex
+	all     map[string]*Namespace
+	core    *Env
+	current *Namespace
+
+	// Module loading, see loader.go
+	search_path []string
+	// namespace => file it was loaded from
+	files map[string]string
+	// files being loaded, innermost last
+	loading []load_frame
+
+	// derive/isa? relationships, shared by every namespace
+	hierarchy *Hierarchy
+
+	// Streams and files of the interpreter
+	sys *system
+}
+
+func new_namespaces(core *Env, sys *system) *Namespaces {
+	r := &Namespaces{
+		all:         make(map[string]*Namespace),
+		core:        core,
+		sys:         sys,
+		search_path: default_search_path(),
+		files:       make(map[string]string),
+		hierarchy:   NewHierarchy(),
+	}
+	core.ns = r.new_namespace(core_ns_name, core)
+	r.current = r.Intern(user_ns_name)
+	return r
+}
+
+func (r *Namespaces) new_namespace(name string, env *Env) *Namespace {
+	ns := &Namespace{
+		name:     name,
+		env:      env,
+		registry: r,
+		aliases:  make(map[string]*Namespace),
+		refers:   make(map[string]*Namespace),
+		records:  make(map[string]*RecordType),
+	}
+	r.all[name] = ns
+	return ns
+}
+
+// Returns the namespace called name, creating it if it does not exist yet
+func (r *Namespaces) Intern(name string) *Namespace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ns, ok := r.all[name]; ok {
+		return ns
+	}
+	env := NewEnv(r.core, nil, nil)
+	ns := r.new_namespace(name, env)
+	env.ns = ns
+	return ns
+}
+
+// NOTE :: Returns nil when no namespace is called name
+func (r *Namespaces) Find(name string) *Namespace {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.all[name]
+}
+
+func (r *Namespaces) Current() *Namespace {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+func (r *Namespaces) SetCurrent(ns *Namespace) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current = ns
+}
+
+func (r *Namespaces) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.all))
+	for name := range r.all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (ns *Namespace) Name() string {
+	return ns.name
+}
+
+func (ns *Namespace) Env() *Env {
+	return ns.env
+}
+
+func (ns *Namespace) Alias(alias string, target *Namespace) {
+	ns.registry.mu.Lock()
+	defer ns.registry.mu.Unlock()
+	ns.aliases[alias] = target
+}
+
+// Refers names of src into ns, every name when names is nil
+func (ns *Namespace) Refer(src *Namespace, names []string) {
+	ns.registry.mu.Lock()
+	defer ns.registry.mu.Unlock()
+	if names == nil {
+		ns.refer_all = append(ns.refer_all, src)
+		return
+	}
+	for _, name := range names {
+		ns.refers[name] = src
+	}
+}
+
+// NOTE :: Returns nil when name is not referred into ns
+func (ns *Namespace) refer_of(name string) *Namespace {
+	ns.registry.mu.RLock()
+	defer ns.registry.mu.RUnlock()
+	if src, ok := ns.refers[name]; ok {
+		return src
+	}
+	for _, src := range ns.refer_all {
+		if _, ok := src.env.data[name]; ok {
+			return src
+		}
+	}
+	return nil
+}
+
+func (ns *Namespace) define_record(ty *RecordType) {
+	ns.registry.mu.Lock()
+	defer ns.registry.mu.Unlock()
+	ns.records[ty.name] = ty
+}
+
+// NOTE :: Returns nil when no record type called name is defined in or referred
+// into ns
+func (ns *Namespace) find_record(name string) *RecordType {
+	ns.registry.mu.RLock()
+	defer ns.registry.mu.RUnlock()
+	if ty, ok := ns.records[name]; ok {
+		return ty
+	}
+	if src, ok := ns.refers[name]; ok {
+		return src.records[name]
+	}
+	for _, src := range ns.refer_all {
+		if ty, ok := src.records[name]; ok {
+			return ty
+		}
+	}
+	return nil
+}
+
+// Splits ns/name, the namespace part being an alias of the env's namespace or a
+// full namespace name. / on its own is a plain symbol.
+func (e *Env) resolve_qualified(key_sym string) (*Namespace, string, bool) {
+	i := strings.Index(key_sym, "/")
+	if i <= 0 || i == len(key_sym)-1 || e.ns == nil {
+		return nil, "", false
+	}
+	ns_name, name := key_sym[:i], key_sym[i+1:]
+
+	e.ns.registry.mu.RLock()
+	target, ok := e.ns.aliases[ns_name]
+	if !ok {
+		target, ok = e.ns.registry.all[ns_name]
+	}
+	e.ns.registry.mu.RUnlock()
+	if !ok && strings.HasPrefix(ns_name, go_package_prefix) {
+		if ns, err := e.ns.registry.go_package(ns_name); err == nil && ns != nil {
+			return ns, name, true
+		}
+	}
+	return target, name, ok
+}
+
+// The env top level forms evaluated in env should continue in. Only the root env of
+// a namespace follows ns/in-ns switches, local envs stay where they are.
+func top_level_env(env *Env) *Env {
+	if env.ns == nil || env != env.ns.env {
+		return env
+	}
+	return env.ns.registry.Current().env
+}
+
+// Name of the namespace the REPL evaluates in, for its prompt
+func current_ns_name(env *Env) string {
+	if env.ns == nil {
+		return user_ns_name
+	}
+	return env.ns.registry.Current().name
+}
+
+// A require spec is a namespace symbol, or a list/array of the namespace symbol
+// followed by options:
+//
+//	:as alias       => alias/name resolves in the namespace
+//	:refer [names]  => names resolve unqualified
+//	:refer :all     => every name of the namespace resolves unqualified
+//
+// Namespaces that are not loaded yet are loaded from their file, see load_namespace.
+// reload loads them again even when they are.
+func ns_require(ns *Namespace, spec Value, reload bool) error {
+	if spec.IsSymbol() {
+		_, err := require_namespace(ns, spec.AsSymbol().Name(), reload)
+		return err
+	}
+	if !spec.IsListLike() || len(spec.AsList()) == 0 {
+		return fmt.Errorf("require => Expected a namespace symbol or [ns & options], got: %s", PrStr(spec, true))
+	}
+
+	parts := spec.AsList()
+	sym, err := parts[0].TrySymbol()
+	if err != nil {
+		return err
+	}
+	target, err := require_namespace(ns, sym.Name(), reload)
+	if err != nil {
+		return err
+	}
+
+	opts := parts[1:]
+	if len(opts)%2 != 0 {
+		return fmt.Errorf("require => Expected option/value pairs after %s", sym.Name())
+	}
+	for i := 1; i < len(opts); i = i + 2 {
+		opt, val := opts[i-1], opts[i]
+		if !opt.IsAtom() {
+			return fmt.Errorf("require => Expected a keyword option, got: %s", PrStr(opt, true))
+		}
+		switch opt.AsAtom().Name() {
+		case ":as":
+			alias, err := val.TrySymbol()
+			if err != nil {
+				return err
+			}
+			ns.Alias(alias.Name(), target)
+		case ":refer":
+			if val.IsAtom() && val.AsAtom().Name() == ":all" {
+				ns.Refer(target, nil)
+				continue
+			}
+			if !val.IsListLike() {
+				return fmt.Errorf("require => :refer expects a list of symbols or :all, got: %s", PrStr(val, true))
+			}
+			names := make([]string, 0, len(val.AsList()))
+			for _, v := range val.AsList() {
+				name, err := v.TrySymbol()
+				if err != nil {
+					return err
+				}
+				if _, ok := target.env.data[name.Name()]; !ok {
+					return fmt.Errorf("require => %s does not exist in %s", name.Name(), target.name)
+				}
+				names = append(names, name.Name())
+			}
+			ns.Refer(target, names)
+		default:
+			return fmt.Errorf("require => Unknown option: %s", opt.AsAtom().Name())
+		}
+	}
+	return nil
+}
+
+func require_namespace(ns *Namespace, name string, reload bool) (*Namespace, error) {
+	r := ns.registry
+	if err := r.check_cycle(name); err != nil {
+		return nil, err
+	}
+	if target := r.Find(name); target != nil && !(reload && r.loaded_from_file(name)) {
+		return target, nil
+	}
+	return r.load_namespace(name)
+}
+
+// Splits the :reload flag from the specs of a require
+func require_specs(vs []Value) ([]Value, bool) {
+	specs := make([]Value, 0, len(vs))
+	reload := false
+	for _, v := range vs {
+		if v.IsAtom() && v.AsAtom().Name() == ":reload" {
+			reload = true
+		} else {
+			specs = append(specs, v)
+		}
+	}
+	return specs, reload
+}
+
+// (ns name "doc"? (:require spec... :reload?)...)
+// Switches to the namespace called name, creating it if needed
+func eval_ns(list []Value, env *Env) (Value, error) {
+	if len(list) < 2 {
+		return NoValue(), fmt.Errorf("ns => Expected a namespace name")
+	}
+	sym, err := list[1].TrySymbol()
+	if err != nil {
+		return NoValue(), err
+	}
+	if env.ns == nil {
+		return NoValue(), fmt.Errorf("ns => No namespace registry in this environment")
+	}
+
+	ns := env.ns.registry.Intern(sym.Name())
+	env.ns.registry.SetCurrent(ns)
+	clauses := list[2:]
+	if len(clauses) > 0 && clauses[0].IsString() {
+		clauses = clauses[1:]
+	}
+	for _, clause := range clauses {
+		if !clause.IsList() || len(clause.AsList()) == 0 || !clause.AsList()[0].IsAtom() {
+			return NoValue(), fmt.Errorf("ns => Expected a (:keyword ...) clause, got: %s", PrStr(clause, true))
+		}
+		parts := clause.AsList()
+		switch parts[0].AsAtom().Name() {
+		case ":require":
+			specs, reload := require_specs(parts[1:])
+			for _, spec := range specs {
+				if err := ns_require(ns, spec, reload); err != nil {
+					return NoValue(), err
+				}
+			}
+		default:
+			return NoValue(), fmt.Errorf("ns => Unknown clause: %s", parts[0].AsAtom().Name())
+		}
+	}
+
+	return NewSymbol(Symbol(ns.name)), nil
+}
+
+// (in-ns 'name)
+func eval_in_ns(r *Namespaces, vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	sym, err := vs[0].TrySymbol()
+	if err != nil {
+		return NewError(err)
+	}
+	ns := r.Intern(sym.Name())
+	r.SetCurrent(ns)
+	return NewSymbol(Symbol(ns.name))
+}
+
+// (require 'spec ... :reload?) into the current namespace
+func eval_require(r *Namespaces, vs ...Value) Value {
+	ns := r.Current()
+	specs, reload := require_specs(vs)
+	for _, spec := range specs {
+		if err := ns_require(ns, spec, reload); err != nil {
+			return NewError(err)
+		}
+	}
+	return NewNilList()
+}
+
+// (alias 'alias 'ns)
+func eval_alias(r *Namespaces, vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
+	}
+	alias, err := vs[0].TrySymbol()
+	if err != nil {
+		return NewError(err)
+	}
+	name, err := vs[1].TrySymbol()
+	if err != nil {
+		return NewError(err)
+	}
+	target := r.Find(name.Name())
+	if target == nil {
+		return NewError(fmt.Errorf("alias => Namespace not found: %s", name.Name()))
+	}
+	r.Current().Alias(alias.Name(), target)
+	return NewNilList()
+}
+
+func eval_all_ns(r *Namespaces, vs ...Value) Value {
+	names := r.Names()
+	res := make([]Value, len(names))
+	for i, name := range names {
+		res[i] = NewSymbol(Symbol(name))
+	}
+	return NewList(res)
+}
@@ -0,0 +1,45 @@
+package interp
+
+import "testing"
+
+func TestNamespaces(t *testing.T) {
+	it, err := NewInterpreter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(ns-name)`, `user`},
+		{`(ns a) (def x 1)`, `1`},
+		{`(ns b) (def x 2)`, `2`},
+		// every namespace has its own x
+		{`x`, `2`},
+		{`a/x`, `1`},
+		{`(in-ns (quot a)) x`, `1`},
+		{`(ns-name)`, `a`},
+		{`(alias (quot bb) (quot b)) bb/x`, `2`},
+		{`(ns c (:require [a :refer [x]])) x`, `1`},
+		{`(ns d (:require [a :as aa] [b :refer :all])) aa/x`, `1`},
+		{`x`, `2`},
+		{`(all-ns)`, `(a b c d smack.core user)`},
+		{`(in-ns (quot e)) (ns-name)`, `e`},
+		// core fns resolve in every namespace, also qualified
+		{`(smack.core/+ 1 2)`, `3`},
+	}
+	for _, tt := range tests {
+		if v, err := it.EvalString(tt.source); err != nil || PrStr(v, true) != tt.want {
+			t.Errorf("%s => %s, %v, expected %s", tt.source, PrStr(v, true), err, tt.want)
+		}
+	}
+	if name := current_ns_name(it.Env()); name != "e" {
+		t.Errorf("REPL prompt namespace => %s, expected e", name)
+	}
+
+	for _, source := range []string{`x`, `nope/x`, `a/nope`} {
+		if _, err := it.EvalString(source); err == nil {
+			t.Errorf("%s in e resolved", source)
+		}
+	}
+}
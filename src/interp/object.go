@@ -0,0 +1,301 @@
+package interp
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Go objects are Go values handed to scripts as is, like database handles, loggers
+// or structs that should not be copied into a hashmap. Their methods and exported
+// fields are reached by reflection:
+//
+//	(.Method obj args...)       => calls obj.Method(args...), see RegisterFunc for conversions
+//	(.-Field obj)               => obj.Field, through any pointers
+//	(set! (.-Field obj) val)    => sets obj.Field to val, obj must be a pointer or a boxed struct
+//
+// (go-methods obj) and (go-fields obj) list what obj offers. Their type name is the
+// Go type, like *bytes.Buffer, so protocols can be extended to them.
+
+type GoObject struct {
+	val reflect.Value
+}
+
+// A Go object holding x. Structs and arrays are copied into a new variable so
+// fields can be set and methods with pointer receivers called. nil for nil
+func NewObject(x any) Value {
+	rv := reflect.ValueOf(x)
+	if !rv.IsValid() {
+		return NewNilList()
+	}
+	return new_object(rv)
+}
+
+func new_object(rv reflect.Value) Value {
+	if (rv.Kind() == reflect.Struct || rv.Kind() == reflect.Array) && !rv.CanAddr() {
+		boxed := reflect.New(rv.Type()).Elem()
+		boxed.Set(rv)
+		rv = boxed
+	}
+	return NewValue(VAL_OBJECT, &GoObject{rv})
+}
+
+// The Go value held
+func (o *GoObject) Interface() any {
+	return o.val.Interface()
+}
+
+func (o *GoObject) Type() reflect.Type {
+	return o.val.Type()
+}
+
+// The held value as a value of type t when it is one, or its address is
+func (o *GoObject) assignable_to(t reflect.Type) (reflect.Value, bool) {
+	switch {
+	case o.val.Type().AssignableTo(t):
+		return o.val, true
+	case o.val.CanAddr() && o.val.Addr().Type().AssignableTo(t):
+		return o.val.Addr(), true
+	case o.val.Kind() == reflect.Pointer && !o.val.IsNil() && o.val.Elem().Type().AssignableTo(t):
+		return o.val.Elem(), true
+	default:
+		return reflect.Value{}, false
+	}
+}
+
+// Comparable values hash by type, as equal ones may live in different variables
+func (o *GoObject) hash() uint32 {
+	switch o.val.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Chan, reflect.Func, reflect.Slice, reflect.UnsafePointer:
+		return mix_hash(hash_seed_ident ^ uint32(o.val.Pointer()) ^ uint32(uint64(o.val.Pointer())>>32))
+	default:
+		return hash_string(hash_seed_ident, o.val.Type().String())
+	}
+}
+
+// Comparable values compare with ==, any other only equals itself
+func (o *GoObject) equals(other *GoObject) bool {
+	if o == other {
+		return true
+	}
+	if o.val.Type() != other.val.Type() || !o.val.Type().Comparable() {
+		return false
+	}
+	defer func() { recover() }()
+	return o.val.Interface() == other.val.Interface()
+}
+
+// (.Method obj) or (.-Field obj) heads
+func is_interop_symbol(name string) bool {
+	return len(name) > 1 && name[0] == '.' && name != ".-"
+}
+
+// (.Method obj args...) or (.-Field obj)
+func eval_interop(list []Value, env *Env) (Value, error) {
+	name := list[0].AsSymbol().Name()
+	if len(list) < 2 {
+		return NoValue(), fmt.Errorf("%s => Expected a Go object", name)
+	}
+	args := make([]Value, len(list)-1)
+	for i, form := range list[1:] {
+		if v, err := Eval(form, env); err == nil {
+			args[i] = v
+		} else {
+			return NoValue(), err
+		}
+	}
+	obj, err := args[0].TryObject()
+	if err != nil {
+		return NoValue(), fmt.Errorf("%s => Expected a Go object, got: %s", name, args[0].TypeString())
+	}
+
+	if field_name, ok := strings.CutPrefix(name, ".-"); ok {
+		if len(args) != 1 {
+			return NoValue(), fmt.Errorf("%s => Invalid arity. Expected 1, got: %d", name, len(args))
+		}
+		field, err := obj.field(field_name)
+		if err != nil {
+			return NoValue(), fmt.Errorf("%s => %s", name, err)
+		}
+		if res, err := from_go(field); err == nil {
+			return res, nil
+		} else {
+			return NoValue(), fmt.Errorf("%s => %s", name, err)
+		}
+	}
+
+	method, err := obj.method(name[1:])
+	if err != nil {
+		return NoValue(), fmt.Errorf("%s => %s", name, err)
+	}
+	return new_go_fn(name, method).AsFn().fn(args[1:]...), nil
+}
+
+// (set! (.-Field obj) val)
+func eval_set_field(list []Value, env *Env) (Value, error) {
+	if len(list) != 3 || !list[1].IsList() || len(list[1].AsList()) != 2 || !list[1].AsList()[0].IsSymbol() {
+		return NoValue(), fmt.Errorf("set! => Expected (set! (.-Field obj) val)")
+	}
+	place := list[1].AsList()
+	field_name, ok := strings.CutPrefix(place[0].AsSymbol().Name(), ".-")
+	if !ok || field_name == "" {
+		return NoValue(), fmt.Errorf("set! => Expected a field access like (.-Field obj), got: %s", PrStr(list[1], true))
+	}
+
+	target, err := Eval(place[1], env)
+	if err != nil {
+		return NoValue(), err
+	}
+	obj, err := target.TryObject()
+	if err != nil {
+		return NoValue(), fmt.Errorf("set! => Expected a Go object, got: %s", target.TypeString())
+	}
+	val, err := Eval(list[2], env)
+	if err != nil {
+		return NoValue(), err
+	}
+
+	field, err := obj.field(field_name)
+	if err != nil {
+		return NoValue(), fmt.Errorf("set! => %s", err)
+	}
+	if !field.CanSet() {
+		return NoValue(), fmt.Errorf("set! => Field %s of %s can not be set, it is a copy", field_name, obj.Type())
+	}
+	rv, err := to_go(val, field.Type())
+	if err != nil {
+		return NoValue(), fmt.Errorf("set! => Field %s: %s", field_name, err)
+	}
+	field.Set(rv)
+	return val, nil
+}
+
+// The exported field name of the struct o holds, or points to
+func (o *GoObject) field(name string) (reflect.Value, error) {
+	rv := o.val
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return reflect.Value{}, fmt.Errorf("Unable to get field %s of a nil %s", name, o.Type())
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("%s is not a struct", o.Type())
+	}
+	field, ok := rv.Type().FieldByName(name)
+	if !ok || !field.IsExported() {
+		return reflect.Value{}, fmt.Errorf("%s has no exported field %s", o.Type(), name)
+	}
+	return rv.FieldByIndex(field.Index), nil
+}
+
+// The method name of the value o holds, or of its address
+func (o *GoObject) method(name string) (reflect.Value, error) {
+	if m := o.val.MethodByName(name); m.IsValid() {
+		return m, nil
+	}
+	if o.val.CanAddr() {
+		if m := o.val.Addr().MethodByName(name); m.IsValid() {
+			return m, nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("%s has no method %s", o.Type(), name)
+}
+
+// The type whose method set covers every method callable on o
+func (o *GoObject) method_type() reflect.Type {
+	if o.val.CanAddr() {
+		return o.val.Addr().Type()
+	}
+	return o.val.Type()
+}
+
+// The struct type o holds or points to, nil when it is not one
+func (o *GoObject) struct_type() reflect.Type {
+	t := o.Type()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
+
+func eval_isobject(vs ...Value) Value {
+	if len(vs) != 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got: %d", len(vs)))
+	}
+	return NewBool(vs[0].IsObject())
+}
+
+// (go-methods obj) => ("Len() int" "WriteString(string) (int, error)" ...)
+func eval_go_methods(vs ...Value) Value {
+	if len(vs) != 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got: %d", len(vs)))
+	}
+	obj, err := vs[0].TryObject()
+	if err != nil {
+		return NewError(err)
+	}
+	t := obj.method_type()
+	sigs := make([]string, 0, t.NumMethod())
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		sigs = append(sigs, m.Name+strings.TrimPrefix(go_method_signature(m.Type), "func"))
+	}
+	sort.Strings(sigs)
+	res := make([]Value, len(sigs))
+	for i, s := range sigs {
+		res[i] = NewString(s)
+	}
+	return NewList(res)
+}
+
+// The signature of the method type t without its receiver, like func(string) (int, error)
+func go_method_signature(t reflect.Type) string {
+	params := make([]string, 0, t.NumIn())
+	for i := 1; i < t.NumIn(); i++ {
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			params = append(params, "..."+t.In(i).Elem().String())
+		} else {
+			params = append(params, t.In(i).String())
+		}
+	}
+	results := make([]string, t.NumOut())
+	for i := range results {
+		results[i] = t.Out(i).String()
+	}
+	sig := "func(" + strings.Join(params, ", ") + ")"
+	switch len(results) {
+	case 0:
+		return sig
+	case 1:
+		return sig + " " + results[0]
+	default:
+		return sig + " (" + strings.Join(results, ", ") + ")"
+	}
+}
+
+// (go-fields obj) => ("Name string" "Port int" ...)
+func eval_go_fields(vs ...Value) Value {
+	if len(vs) != 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got: %d", len(vs)))
+	}
+	obj, err := vs[0].TryObject()
+	if err != nil {
+		return NewError(err)
+	}
+	t := obj.struct_type()
+	if t == nil {
+		return NewList([]Value{})
+	}
+	res := make([]Value, 0, t.NumField())
+	for _, field := range reflect.VisibleFields(t) {
+		if field.IsExported() && !field.Anonymous {
+			res = append(res, NewString(field.Name+" "+field.Type.String()))
+		}
+	}
+	return NewList(res)
+}
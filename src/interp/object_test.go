@@ -0,0 +1,69 @@
+package interp
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type object_plain struct{ X int }
+
+type object_counter struct{ N int }
+
+func (c object_counter) Get() int { return c.N }
+
+func (c *object_counter) Add(n int) int {
+	c.N += n
+	return c.N
+}
+
+func TestFromGoStructs(t *testing.T) {
+	if v, err := from_go(reflect.ValueOf(object_plain{1})); err != nil || !v.IsHashMap() {
+		t.Errorf("from_go of a plain struct => %s, %v", PrStr(v, true), err)
+	}
+	if v, err := from_go(reflect.ValueOf(object_counter{1})); err != nil || !v.IsObject() {
+		t.Errorf("from_go of a struct with methods => %s, %v", PrStr(v, true), err)
+	}
+	if v, err := from_go(reflect.ValueOf(&object_plain{1})); err != nil || !v.IsObject() {
+		t.Errorf("from_go of a pointer to a struct => %s, %v", PrStr(v, true), err)
+	}
+}
+
+func TestInterop(t *testing.T) {
+	counter := &object_counter{1}
+	it, err := NewInterpreter(WithGlobals(map[string]any{
+		"v":   object_counter{3},
+		"c":   counter,
+		"buf": &bytes.Buffer{},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(.Get v)`, "3"},
+		{`(.-N v)`, "3"},
+		{`(.Add c 2)`, "3"},
+		{`(set! (.-N c) 10)`, "10"},
+		{`(.Get c)`, "10"},
+		{`(do (.WriteString buf "hi") (.String buf))`, `"hi"`},
+		{`(object? buf)`, "true"},
+		{`(object? 1)`, "false"},
+		{`(nth (go-methods c) 0)`, `"Add(int) int"`},
+	}
+	for _, tt := range tests {
+		if v, err := it.EvalString(tt.source); err != nil || PrStr(v, true) != tt.want {
+			t.Errorf("%s => %s, %v, expected %s", tt.source, PrStr(v, true), err, tt.want)
+		}
+	}
+	if counter.N != 10 {
+		t.Errorf("set! on a pointer did not change the Go value, N = %d", counter.N)
+	}
+	for _, source := range []string{`(.Missing c)`, `(.-Missing c)`, `(.Get 1)`, `(set! (.-N v) "x")`} {
+		if _, err := it.EvalString(source); err == nil {
+			t.Errorf("%s succeeded", source)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package interp
 
 import (
 	"fmt"
+	"math"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -15,19 +17,44 @@ const (
 )
 
 type parser struct {
-	toks    []string
+	toks []string
+	// byte offset of each token in source
+	offs    []int
 	current uint32
+	source  string
+	// attach :line and :column metadata to forms that can carry it
+	positions bool
+	// offset of the start of every line, computed on first use
+	lines []int
+	// the record type #Name{...} is read as, see read_record. Nil when records are
+	// read as the forms building them
+	records func(name string) *RecordType
 }
 
 func new_parser(source string) parser {
-	toks := tokenize(source)
-	current := uint32(0)
+	toks, offs := tokenize(source)
 	return parser{
-		toks,
-		current,
+		toks:   toks,
+		offs:   offs,
+		source: source,
 	}
 }
 
+// 1 based line and column of the token at index tok
+func (p *parser) position(tok uint32) (int, int) {
+	if p.lines == nil {
+		p.lines = []int{0}
+		for i := 0; i < len(p.source); i++ {
+			if p.source[i] == '\n' {
+				p.lines = append(p.lines, i+1)
+			}
+		}
+	}
+	off := p.offs[tok]
+	line := sort.Search(len(p.lines), func(i int) bool { return p.lines[i] > off })
+	return line, 1 + off - p.lines[line-1]
+}
+
 func (p *parser) peek() string {
 	return p.toks[p.current]
 }
@@ -41,6 +68,22 @@ func (p *parser) skip(n int) {
 }
 
 func (p *parser) read_form() (Value, error) {
+	start := p.current
+	form, err := p.read_form_at()
+	if err != nil || !p.positions || !form.SupportsMeta() {
+		return form, err
+	}
+	if form.Meta() != nil {
+		if _, ok := form.Meta().Get(NewAtom(":line")); ok {
+			return form, nil
+		}
+	}
+	line, col := p.position(start)
+	meta := EmptyHashMap().Assoc(NewAtom(":line"), NewNumber(float64(line))).Assoc(NewAtom(":column"), NewNumber(float64(col)))
+	return form.WithMeta(merge_meta(form.Meta(), meta))
+}
+
+func (p *parser) read_form_at() (Value, error) {
 	tok := p.peek()
 
 	switch tok[0] {
@@ -53,23 +96,128 @@ func (p *parser) read_form() (Value, error) {
 	case '{':
 		p.skip(1)
 		return p.read_listas(VAL_HASHMAP)
+	case '^':
+		return p.read_with_meta()
+	case '#':
+		if tok == "#" && int(p.current)+1 < len(p.toks) && p.peek_next() == "{" {
+			p.skip(2)
+			return p.read_list('}', VAL_SET)
+		}
+		if len(tok) > 1 && tok[1] != '#' && int(p.current)+1 < len(p.toks) && p.peek_next() == "{" {
+			return p.read_record()
+		}
+		return p.read_atom()
+	case '\'':
+		return p.read_wrapped("quot")
+	case '`':
+		return p.read_wrapped("quasiquot")
+	case '@':
+		return p.read_wrapped("deref")
+	case '~':
+		if tok == "~@" {
+			return p.read_wrapped("splice-unquot")
+		}
+		return p.read_wrapped("unquot")
 	default:
 		return p.read_atom()
 	}
 
 }
 
+// #Name{:field val...}, a record as it is printed. It is read as the record itself
+// when the parser knows the type Name, otherwise as (map->Name {:field val...}),
+// which builds it when evaluated
+func (p *parser) read_record() (Value, error) {
+	name := p.peek()[1:]
+	p.skip(2)
+	m, err := p.read_list('}', VAL_HASHMAP)
+	if err != nil {
+		return NoValue(), err
+	}
+	if p.records != nil {
+		if ty := p.records(name); ty != nil {
+			kvs := m.AsList()
+			if len(kvs)%2 != 0 {
+				return NoValue(), fmt.Errorf("read_form => Record #%s needs an even number of forms, got: %d", name, len(kvs))
+			}
+			fields := EmptyHashMap()
+			for i := 1; i < len(kvs); i = i + 2 {
+				fields = fields.Assoc(kvs[i-1], kvs[i])
+			}
+			return record_from_map(ty, fields), nil
+		}
+	}
+	ctor := "map->" + name
+	if i := strings.LastIndex(name, "/"); i > 0 {
+		ctor = name[:i+1] + "map->" + name[i+1:]
+	}
+	return NewList([]Value{NewSymbol(Symbol(ctor)), m}), nil
+}
+
+// Reader shorthand, e.g. 'x => (quot x)
+func (p *parser) read_wrapped(sym string) (Value, error) {
+	if int(p.current)+1 >= len(p.toks) {
+		return NoValue(), fmt.Errorf("read_form => Expected a form after %s", p.peek())
+	}
+	p.skip(1)
+	if form, err := p.read_form(); err == nil {
+		return NewList([]Value{NewSymbol(Symbol(sym)), form}), nil
+	} else {
+		return NoValue(), err
+	}
+}
+
+// ^meta form, metadata is attached to the read form itself
+func (p *parser) read_with_meta() (Value, error) {
+	if int(p.current)+2 >= len(p.toks) {
+		return NoValue(), fmt.Errorf("read_form => Expected metadata and a form after ^")
+	}
+	p.skip(1)
+	meta_form, err := p.read_form()
+	if err != nil {
+		return NoValue(), err
+	}
+	meta, err := reader_meta(meta_form)
+	if err != nil {
+		return NoValue(), err
+	}
+
+	p.skip(1)
+	form, err := p.read_form()
+	if err != nil {
+		return NoValue(), err
+	}
+	return form.WithMeta(merge_meta(form.Meta(), meta))
+}
+
 func (p *parser) read_atom() (Value, error) {
 	tok := p.peek()
 	switch tok[0] {
 	case '"':
+		if len(tok) < 2 || tok[len(tok)-1] != '"' {
+			return NoValue(), fmt.Errorf("read_atom => Unterminated string %s", tok)
+		}
 		// trim off surrounding double quotes
-		s := string(tok[1 : len(tok)-1])
+		s := unescape_string(tok[1 : len(tok)-1])
 		return NewString(s), nil
 	case ':':
 		return NewAtom(tok), nil
+	case '#':
+		switch tok {
+		case "##Inf":
+			return NewNumber(math.Inf(1)), nil
+		case "##-Inf":
+			return NewNumber(math.Inf(-1)), nil
+		case "##NaN":
+			return NewNumber(math.NaN()), nil
+		}
+		return NewSymbol(Symbol(tok)), nil
 	default:
-		n, err := strconv.ParseFloat(tok, 32)
+		var n float64
+		err := fmt.Errorf("not a number")
+		if is_number_token(tok) {
+			n, err = strconv.ParseFloat(tok, 64)
+		}
 		if err != nil {
 			// TODO :: Probably want to put this in a global keyword map
 			switch tok {
@@ -87,6 +235,19 @@ func (p *parser) read_atom() (Value, error) {
 	}
 }
 
+// Only tokens starting with a digit (after an optional sign or '.') are
+// numbers, so symbols like inf or nan are not read as floats
+func is_number_token(tok string) bool {
+	i := 0
+	if tok[i] == '-' || tok[i] == '+' {
+		i++
+	}
+	if i < len(tok) && tok[i] == '.' {
+		i++
+	}
+	return i < len(tok) && tok[i] >= '0' && tok[i] <= '9'
+}
+
 // list_type MUST be VAL_LIST, VAL_ARRAY or VAL_HASHMAP. otherwise
 // NoValue(), error is returned
 func (p *parser) read_listas(list_type uint32) (Value, error) {
@@ -105,7 +266,7 @@ func (p *parser) read_listas(list_type uint32) (Value, error) {
 	return p.read_list(delim, list_type)
 }
 
-// list_type MUST be VAL_LIST, VAL_ARRAY or VAL_HASHMAP. otherwise
+// list_type MUST be VAL_LIST, VAL_ARRAY, VAL_HASHMAP or VAL_SET. otherwise
 // NoValue(), error is returned
 func (p *parser) read_list(delim byte, list_type uint32) (Value, error) {
 	list := make([]Value, 0)
@@ -135,18 +296,27 @@ func (p *parser) read_list(delim byte, list_type uint32) (Value, error) {
 		p.skip(1)
 	}
 
-	// NOTE :: Hashmaps are read as lists (arrays) and then converted to
-	// maps at eval time
+	// NOTE :: Hashmaps, arrays and sets are read as lists (arrays) and then
+	// converted at eval time
 	return NewValue(list_type, list), nil
 }
 
-func tokenize(source string) []string {
-	re := regexp.MustCompile(`[\s,]*(~@|[\[\]{}()'` + "`" + `~^@]|"(?:\\.|[^\\"])*"?|;.*|[^\s\[\]{}('"` + "`" + `,;)]*)`)
-	matchesRaw := re.FindAll([]byte(source), -1)
+var token_re = regexp.MustCompile(`[\s,]*(~@|[\[\]{}()'` + "`" + `~^@]|"(?:\\.|[^\\"])*"?|;.*|[^\s\[\]{}('"` + "`" + `,;)]*)`)
+
+// Returns the tokens of source and the byte offset each starts at
+func tokenize(source string) ([]string, []int) {
+	matchesRaw := token_re.FindAllStringSubmatchIndex(source, -1)
 
 	matches := make([]string, 0, len(matchesRaw))
-	for _, s := range matchesRaw {
-		matches = append(matches, strings.TrimSpace(string(s)))
+	offs := make([]int, 0, len(matchesRaw))
+	for _, m := range matchesRaw {
+		tok := strings.TrimSpace(source[m[2]:m[3]])
+		// Whitespace at the end of input and comments are not forms
+		if len(tok) == 0 || tok[0] == ';' {
+			continue
+		}
+		matches = append(matches, tok)
+		offs = append(offs, m[2])
 	}
-	return matches
+	return matches, offs
 }
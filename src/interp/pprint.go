@@ -0,0 +1,190 @@
+package interp
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Pretty printer used by pprint and the REPL. Collections are printed on one
+// line when they fit in the remaining width, otherwise each element goes on its own
+// line, aligned just inside the opening bracket. Map entries keep their key and value
+// on the same line, the value being laid out from the column after the key.
+//
+// Output is always readable, apart from truncated collections which end
+// in ... and collections nested past MaxDepth which print as #
+
+type PPrintOptions struct {
+	// Target column width
+	Width int
+	// Elements printed per collection before truncating with ... (<= 0 is unlimited)
+	MaxLength int
+	// Nesting depth before collections are elided with # (<= 0 is unlimited)
+	MaxDepth int
+}
+
+var DefaultPPrintOptions = PPrintOptions{
+	Width:     80,
+	MaxLength: 100,
+	MaxDepth:  12,
+}
+
+func PPrint(v Value, opts PPrintOptions) string {
+	p := pprinter{opts: opts}
+	p.pp(v, 0, 0)
+	return p.sb.String()
+}
+
+type pprinter struct {
+	opts PPrintOptions
+	sb   strings.Builder
+}
+
+// Elements of a list, array or hashmap (as alternating keys and values) up to MaxLength
+func (p *pprinter) elems(v Value) ([]Value, bool) {
+	var elems []Value
+	count := 0
+	truncated := false
+	limit := p.opts.MaxLength
+
+	if is_map_like(v) {
+		each_entry(v, func(key Value, val Value) bool {
+			if limit > 0 && count >= limit {
+				truncated = true
+				return false
+			}
+			elems = append(elems, key, val)
+			count++
+			return true
+		})
+		return elems, truncated
+	}
+
+	if v.IsSet() {
+		elems = v.AsSet().Slice()
+	} else {
+		elems = v.AsList()
+	}
+	if limit > 0 && len(elems) > limit {
+		return elems[:limit], true
+	}
+	return elems, false
+}
+
+func delims(v Value) (string, string) {
+	switch v.Type() {
+	case VAL_ARRAY:
+		return "[", "]"
+	case VAL_HASHMAP:
+		return "{", "}"
+	case VAL_RECORD:
+		return "#" + v.AsRecord().ty.name + "{", "}"
+	case VAL_SET:
+		return "#{", "}"
+	default:
+		return "(", ")"
+	}
+}
+
+func is_collection(v Value) bool {
+	return v.IsListLike() || is_map_like(v) || v.IsSet()
+}
+
+func is_map_like(v Value) bool {
+	return v.IsHashMap() || v.IsRecord()
+}
+
+func (p *pprinter) too_deep(depth int) bool {
+	return p.opts.MaxDepth > 0 && depth >= p.opts.MaxDepth
+}
+
+// Single line form, respecting the length and depth limits
+func (p *pprinter) flat(v Value, depth int) string {
+	if !is_collection(v) {
+		return PrStr(v, true)
+	}
+	if p.too_deep(depth) {
+		return "#"
+	}
+
+	open, close := delims(v)
+	elems, truncated := p.elems(v)
+	parts := make([]string, 0, len(elems)+1)
+	for _, e := range elems {
+		parts = append(parts, p.flat(e, depth+1))
+	}
+	if truncated {
+		parts = append(parts, "...")
+	}
+	return open + strings.Join(parts, " ") + close
+}
+
+func (p *pprinter) fits(s string, column int) bool {
+	return column+utf8.RuneCountInString(s) <= p.opts.Width
+}
+
+// Writes v assuming the cursor is at column
+func (p *pprinter) pp(v Value, column int, depth int) {
+	flat := p.flat(v, depth)
+	if !is_collection(v) || p.too_deep(depth) || p.fits(flat, column) {
+		p.sb.WriteString(flat)
+		return
+	}
+
+	open, close := delims(v)
+	elems, truncated := p.elems(v)
+	inner := column + len(open)
+	indent := "\n" + strings.Repeat(" ", inner)
+
+	p.sb.WriteString(open)
+	if is_map_like(v) {
+		for i := 1; i < len(elems); i = i + 2 {
+			if i > 1 {
+				p.sb.WriteString(indent)
+			}
+			key := p.flat(elems[i-1], depth+1)
+			p.sb.WriteString(key)
+			p.sb.WriteRune(' ')
+			p.pp(elems[i], inner+utf8.RuneCountInString(key)+1, depth+1)
+		}
+	} else {
+		for i, e := range elems {
+			if i > 0 {
+				p.sb.WriteString(indent)
+			}
+			p.pp(e, inner, depth+1)
+		}
+	}
+	if truncated {
+		if len(elems) > 0 {
+			p.sb.WriteString(indent)
+		}
+		p.sb.WriteString("...")
+	}
+	p.sb.WriteString(close)
+}
+
+// Reads :width, :max-length and :max-depth from an options map, starting from DefaultPPrintOptions
+func pprint_options(v Value) (PPrintOptions, error) {
+	opts := DefaultPPrintOptions
+	m, err := v.TryHashMap()
+	if err != nil {
+		return opts, err
+	}
+
+	fields := map[string]*int{
+		":width":      &opts.Width,
+		":max-length": &opts.MaxLength,
+		":max-depth":  &opts.MaxDepth,
+	}
+	for name, field := range fields {
+		if val, ok := m.Get(NewAtom(name)); ok {
+			n, err := val.TryNumber()
+			if err != nil {
+				return opts, fmt.Errorf("pprint => option %s: %s", name, err)
+			}
+			*field = int(n)
+		}
+	}
+	return opts, nil
+}
@@ -0,0 +1,55 @@
+package interp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPPrint(t *testing.T) {
+	env := NewCoreEnv()
+	tests := []struct {
+		source string
+		opts   PPrintOptions
+		want   string
+	}{
+		{`[1 2 3]`, DefaultPPrintOptions, "[1 2 3]"},
+		{`[1 2 3]`, PPrintOptions{Width: 5}, "[1\n 2\n 3]"},
+		{`[1 [2 3] "four"]`, PPrintOptions{Width: 8}, "[1\n [2 3]\n \"four\"]"},
+		{`{:key [1 2 3]}`, PPrintOptions{Width: 10}, "{:key [1\n       2\n       3]}"},
+		{`#{1}`, PPrintOptions{Width: 2}, "#{1}"},
+		{`[1 2 3]`, PPrintOptions{Width: 80, MaxLength: 2}, "[1 2 ...]"},
+		{`[1 2 3]`, PPrintOptions{Width: 3, MaxLength: 2}, "[1\n 2\n ...]"},
+		{`[1 [2 [3]]]`, PPrintOptions{Width: 80, MaxDepth: 2}, "[1 [2 #]]"},
+		{`"a long string that does not fit"`, PPrintOptions{Width: 4}, `"a long string that does not fit"`},
+	}
+	for _, tt := range tests {
+		form, err := Read(tt.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := Eval(form, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := PPrint(v, tt.opts); got != tt.want {
+			t.Errorf("PPrint(%s, %+v) =>\n%s\nexpected\n%s", tt.source, tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestPPrintCore(t *testing.T) {
+	var out bytes.Buffer
+	it, err := NewInterpreter(WithStdout(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := it.EvalString(`(pprint [1 2 3]) (pprint [1 2 3] {:width 5 :max-length 2})`); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "[1 2 3]\n[1\n 2\n ...]\n" {
+		t.Errorf("pprint wrote %q", out.String())
+	}
+	if v, err := it.EvalString(`(pprint 1 {:width "wide"})`); err == nil && !v.IsError() {
+		t.Errorf("pprint with a string width => %s", PrStr(v, true))
+	}
+}
@@ -0,0 +1,190 @@
+package interp
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Printer for every VAL_* type, in two modes:
+//   - readably (pr-str/prn): output can be read back with Read, strings are quoted and escaped
+//   - display (str/println): strings and errors are printed as is
+//
+// Values that have no readable form (functions, channels, refs, protocols) print as #<...>
+
+func PrStr(v Value, readably bool) string {
+	sb := strings.Builder{}
+	write_value(&sb, v, readably)
+	return sb.String()
+}
+
+func write_value(sb *strings.Builder, v Value, readably bool) {
+	switch v.Type() {
+	case VAL_NUMBER:
+		sb.WriteString(format_number(v.AsNumber()))
+	case VAL_STRING:
+		if readably {
+			sb.WriteString(escape_string(v.AsString()))
+		} else {
+			sb.WriteString(v.AsString())
+		}
+	case VAL_BOOLEAN:
+		sb.WriteString(strconv.FormatBool(v.AsBool()))
+	case VAL_LIST:
+		write_seq(sb, v.AsList(), "(", ")", readably)
+	case VAL_ARRAY:
+		write_seq(sb, v.AsList(), "[", "]", readably)
+	case VAL_HASHMAP, VAL_RECORD:
+		if v.IsRecord() {
+			sb.WriteRune('#')
+			sb.WriteString(v.AsRecord().ty.name)
+		}
+		sb.WriteRune('{')
+		i := 0
+		each_entry(v, func(key Value, val Value) bool {
+			if i > 0 {
+				sb.WriteRune(' ')
+			}
+			write_value(sb, key, readably)
+			sb.WriteRune(' ')
+			write_value(sb, val, readably)
+			i++
+			return true
+		})
+		sb.WriteRune('}')
+	case VAL_SET:
+		write_seq(sb, v.AsSet().Slice(), "#{", "}", readably)
+	case VAL_SYMBOL:
+		sb.WriteString(v.AsSymbol().Name())
+	case VAL_ATOM:
+		sb.WriteString(v.AsAtom().Name())
+	case VAL_FN:
+		sb.WriteString(v.AsFn().String())
+	case VAL_ERROR:
+		msg := "nil"
+		if v.val != nil {
+			msg = v.AsError().Error()
+		}
+		if readably {
+			sb.WriteString("#<error ")
+			sb.WriteString(escape_string(msg))
+			sb.WriteRune('>')
+		} else {
+			sb.WriteString(msg)
+		}
+	case VAL_CHANNEL:
+		sb.WriteString("#<chan>")
+	case VAL_REF:
+		sb.WriteString("#<ref ")
+		write_value(sb, v.AsRef().Deref(), readably)
+		sb.WriteRune('>')
+	case VAL_OPTION:
+		if o := v.AsOption(); o.is_some {
+			write_seq(sb, []Value{NewSymbol("some"), o.val}, "(", ")", readably)
+		} else {
+			sb.WriteString("none")
+		}
+	case VAL_RESULT:
+		if r := v.AsResult(); r.is_ok {
+			write_seq(sb, []Value{NewSymbol("ok"), r.val}, "(", ")", readably)
+		} else {
+			write_seq(sb, []Value{NewSymbol("err"), r.val}, "(", ")", readably)
+		}
+	case VAL_PROTOCOL:
+		sb.WriteString("#<protocol ")
+		sb.WriteString(v.AsProtocol().name)
+		sb.WriteRune('>')
+	case VAL_OBJECT:
+		sb.WriteString("#<go ")
+		sb.WriteString(v.TypeString())
+		sb.WriteRune('>')
+	case VAL_NONE:
+		sb.WriteString("#<none>")
+	default:
+		sb.WriteString("#<unknown>")
+	}
+}
+
+// Entries of a hashmap or record
+func each_entry(v Value, fn func(key Value, val Value) bool) {
+	if v.IsRecord() {
+		v.AsRecord().Each(fn)
+	} else {
+		v.AsHashMap().Each(fn)
+	}
+}
+
+func write_seq(sb *strings.Builder, list []Value, open string, close string, readably bool) {
+	sb.WriteString(open)
+	for i, v := range list {
+		if i > 0 {
+			sb.WriteRune(' ')
+		}
+		write_value(sb, v, readably)
+	}
+	sb.WriteString(close)
+}
+
+// Shortest representation that parses back to the same float64,
+// integers print without a decimal point.
+func format_number(n float64) string {
+	switch {
+	case math.IsInf(n, 1):
+		return "##Inf"
+	case math.IsInf(n, -1):
+		return "##-Inf"
+	case math.IsNaN(n):
+		return "##NaN"
+	}
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
+
+func escape_string(s string) string {
+	sb := strings.Builder{}
+	sb.WriteRune('"')
+	for _, c := range s {
+		switch c {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			sb.WriteRune(c)
+		}
+	}
+	sb.WriteRune('"')
+	return sb.String()
+}
+
+// Inverse of escape_string, s is the string token without its surrounding quotes
+func unescape_string(s string) string {
+	if !strings.ContainsRune(s, '\\') {
+		return s
+	}
+	sb := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 >= len(s) {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,73 @@
+package interp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrinter(t *testing.T) {
+	var out bytes.Buffer
+	it, err := NewInterpreter(WithStdout(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(pr-str 1.5 -3 1e20 0.1)`, `"1.5 -3 1e+20 0.1"`},
+		{`(pr-str ##Inf ##-Inf ##NaN)`, `"##Inf ##-Inf ##NaN"`},
+		{`(pr-str "a\"b\n" :k (quot s) nil true)`, `"\"a\\\"b\\n\" :k s () true"`},
+		{`(pr-str [1 [2]] (quot (1 (2))) {:a 1} #{1})`, `"[1 [2]] (1 (2)) {:a 1} #{1}"`},
+		{`(str 1.5 "a" :k (quot s) [1 "x"] nil)`, `"1.5a:ks[1 x]()"`},
+		{`(pr-str + (fn (x) x))`, `"#<fn +> #<fn>"`},
+		{`(do (def f (fn (x) x)) (pr-str f))`, `"#<fn f>"`},
+		{`(pr-str (some 1) none (ok 1) (err "e"))`, `"(some 1) none (ok 1) (err \"e\")"`},
+		{`(str (some "a"))`, `"(some a)"`},
+		{`(pr-str (ref 1))`, `"#<ref 1>"`},
+	}
+	for _, tt := range tests {
+		if v, err := it.EvalString(tt.source); err != nil || PrStr(v, true) != tt.want {
+			t.Errorf("%s => %s, %v, expected %s", tt.source, PrStr(v, true), err, tt.want)
+		}
+	}
+
+	if _, err := it.EvalString(`(prn "a" 1 :k) (println "a" 1 :k)`); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "\"a\" 1 :k\na 1 :k\n" {
+		t.Errorf("prn and println wrote %q", out.String())
+	}
+}
+
+// The value of source, with collection literals built and nothing else evaluated
+func read_data(t *testing.T, env *Env, source string) Value {
+	t.Helper()
+	form, err := Read(source)
+	if err != nil {
+		t.Fatalf("%s does not read: %s", source, err)
+	}
+	if form.IsSymbol() || form.IsList() {
+		return form
+	}
+	v, err := Eval(form, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// Data printed readably reads back as an equal value
+func TestPrinterRoundTrip(t *testing.T) {
+	env := NewCoreEnv()
+	for _, source := range []string{
+		`1.25`, `-7`, `1e+300`, `"tab\there \"quoted\" back\\slash"`, `:kw`, `sym`, `true`, `false`, `()`,
+		`[1 "two" :three [4]]`, `(1 (2 (3)))`, `{:a [1 2] "b" {:c #{1 2}}}`, `#{:x "y"}`, `##Inf`, `##-Inf`,
+	} {
+		v := read_data(t, env, source)
+		printed := PrStr(v, true)
+		if back := read_data(t, env, printed); !back.Equals(v) {
+			t.Errorf("%s printed as %s reads back as %s", source, printed, PrStr(back, true))
+		}
+	}
+}
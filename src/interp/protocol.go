@@ -0,0 +1,372 @@
+package interp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// A protocol is a named set of methods dispatched on the type of their first argument.
+//
+//	(defprotocol Shape "doc"? (area "doc"? (this)) (scale (this factor)))
+//	(extend-type Number Shape (area (this) ...) (scale (this factor) ...))
+//	(extend-protocol Shape Number (area (this) ...) Array (area (this) ...))
+//
+// Types are named as TypeString reports them (Number, String, Array, HashMap,
+// *bytes.Buffer...), plus Nil which is tried before List for nil, and Object which
+// every type falls back to. Records are looked up like vars are, Point or geo/Point,
+// and implementations for them are kept under the name qualified by their
+// namespace, so records of the same name in two namespaces do not share methods.
+// Any other name is an error. Implementations are kept in a map from type name to
+// methods that is replaced as a whole on every extend, so dispatch is two map
+// lookups without locking.
+
+const (
+	nil_type_name    = "Nil"
+	object_type_name = "Object"
+)
+
+type Protocol struct {
+	name    string
+	methods []protocol_method
+	// type name => method name => implementation
+	impls atomic.Pointer[map[string]map[string]*SmackFn]
+}
+
+type protocol_method struct {
+	name     string
+	arglists []Value
+	doc      string
+}
+
+func NewProtocol(name string, methods []protocol_method) *Protocol {
+	p := &Protocol{name: name, methods: methods}
+	impls := make(map[string]map[string]*SmackFn)
+	p.impls.Store(&impls)
+	return p
+}
+
+func (p *Protocol) Name() string {
+	return p.name
+}
+
+func (p *Protocol) has_method(name string) bool {
+	for _, m := range p.methods {
+		if m.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Implements methods of p for values of type type_name, replacing any previous
+// implementation of the same methods. Records are named ns/Name
+func (p *Protocol) Extend(type_name string, fns map[string]*SmackFn) error {
+	for name := range fns {
+		if !p.has_method(name) {
+			return fmt.Errorf("%s is not a method of protocol %s", name, p.name)
+		}
+	}
+	for {
+		current := p.impls.Load()
+		next := make(map[string]map[string]*SmackFn, len(*current)+1)
+		for ty, methods := range *current {
+			next[ty] = methods
+		}
+		methods := make(map[string]*SmackFn, len(p.methods))
+		for name, f := range (*current)[type_name] {
+			methods[name] = f
+		}
+		for name, f := range fns {
+			methods[name] = f
+		}
+		next[type_name] = methods
+		if p.impls.CompareAndSwap(current, &next) {
+			return nil
+		}
+	}
+}
+
+// Methods implemented for v, nil if p is not extended to its type
+func (p *Protocol) impls_for(v Value) map[string]*SmackFn {
+	impls := *p.impls.Load()
+	if is_nil(v) {
+		if methods, ok := impls[nil_type_name]; ok {
+			return methods
+		}
+	}
+	if methods, ok := impls[dispatch_type_name(v)]; ok {
+		return methods
+	}
+	return impls[object_type_name]
+}
+
+func (p *Protocol) Satisfies(v Value) bool {
+	return len(p.impls_for(v)) > 0
+}
+
+// NOTE :: Returns nil when method is not implemented for the type of v
+func (p *Protocol) find_impl(v Value, method string) *SmackFn {
+	return p.impls_for(v)[method]
+}
+
+// The fn method is bound to, calling the implementation for the type of its first argument
+func (p *Protocol) dispatch_fn(m protocol_method) Value {
+	fn := func(frame *dyn_frame, vs ...Value) Value {
+		if len(vs) < 1 {
+			return NewError(fmt.Errorf("Invalid arity. Expected at least 1, got 0"))
+		}
+		impl := p.find_impl(vs[0], m.name)
+		if impl == nil {
+			return NewError(fmt.Errorf("No implementation of method: %s of protocol: %s found for type: %s", m.name, p.name, dispatch_type_name(vs[0])))
+		}
+		if res, err := apply_fn(impl, frame, vs); err == nil {
+			return res
+		} else {
+			return NewError(err)
+		}
+	}
+	f := new_core_frame_fn(fn, "()", m.doc)
+	f.AsFn().name = m.name
+	f.meta = f.meta.Assoc(NewAtom(":arglists"), NewList(m.arglists))
+	f.meta = f.meta.Assoc(NewAtom(":protocol"), NewSymbol(Symbol(p.name)))
+	return f
+}
+
+func dispatch_type_name(v Value) string {
+	if is_nil(v) {
+		return nil_type_name
+	}
+	if v.IsRecord() {
+		return v.AsRecord().ty.qualified_name()
+	}
+	return v.TypeString()
+}
+
+// The name impls for the type named name in env are kept under, see
+// dispatch_type_name
+func impl_type_name(name string, env *Env) (string, error) {
+	if ty := find_record_type(env, name); ty != nil {
+		return ty.qualified_name(), nil
+	}
+	// Go types are named with their package, like *bytes.Buffer
+	if builtin_type_name(name) || strings.Contains(name, ".") {
+		return name, nil
+	}
+	return "", fmt.Errorf("Unknown type: %s", name)
+}
+
+func builtin_type_name(name string) bool {
+	if name == nil_type_name || name == object_type_name {
+		return true
+	}
+	for ty := uint32(VAL_NUMBER); ty < VAL_OBJECT; ty++ {
+		// records and Go objects dispatch on their own type names
+		if ty != VAL_RECORD && TypeString(ty) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// (method "doc"? (this args...)...)
+func parse_protocol_method(sig Value) (protocol_method, error) {
+	if !sig.IsList() || len(sig.AsList()) < 2 {
+		return protocol_method{}, fmt.Errorf("defprotocol => Expected a method signature (name \"doc\"? (this args...)), got: %s", PrStr(sig, true))
+	}
+	parts := sig.AsList()
+	sym, err := parts[0].TrySymbol()
+	if err != nil {
+		return protocol_method{}, err
+	}
+	m := protocol_method{name: sym.Name()}
+	arglists := parts[1:]
+	if arglists[0].IsString() {
+		m.doc = arglists[0].AsString()
+		arglists = arglists[1:]
+	}
+	if len(arglists) == 0 {
+		return protocol_method{}, fmt.Errorf("defprotocol => Method %s needs a parameter list", m.name)
+	}
+	for _, params := range arglists {
+		if !params.IsListLike() || len(params.AsList()) == 0 {
+			return protocol_method{}, fmt.Errorf("defprotocol => Method %s must take at least one parameter, got: %s", m.name, PrStr(params, true))
+		}
+	}
+	m.arglists = arglists
+	return m, nil
+}
+
+// (defprotocol Name "doc"? (method "doc"? (this args...)...)...)
+// Binds Name to the protocol and every method to its dispatch fn
+func eval_defprotocol(list []Value, env *Env) (Value, error) {
+	if len(list) < 2 {
+		return NoValue(), fmt.Errorf("defprotocol => Expected a protocol name")
+	}
+	sym, err := list[1].TrySymbol()
+	if err != nil {
+		return NoValue(), err
+	}
+	sigs := list[2:]
+	var doc Value
+	if len(sigs) > 0 && sigs[0].IsString() {
+		doc = sigs[0]
+		sigs = sigs[1:]
+	}
+
+	methods := make([]protocol_method, 0, len(sigs))
+	for _, sig := range sigs {
+		m, err := parse_protocol_method(sig)
+		if err != nil {
+			return NoValue(), err
+		}
+		methods = append(methods, m)
+	}
+
+	p := NewProtocol(sym.Name(), methods)
+	for _, m := range methods {
+		f := p.dispatch_fn(m)
+		env.Set(m.name, f)
+		env.SetMeta(m.name, f.meta)
+	}
+
+	val := NewValue(VAL_PROTOCOL, p)
+	env.Set(p.name, val)
+	if doc.IsString() {
+		env.SetMeta(p.name, EmptyHashMap().Assoc(NewAtom(":doc"), doc))
+	}
+	return val, nil
+}
+
+// (method (this args...) body) forms into their fns
+func protocol_impls(forms []Value, env *Env) (map[string]*SmackFn, error) {
+	fns := make(map[string]*SmackFn, len(forms))
+	for _, form := range forms {
+		if !form.IsList() || len(form.AsList()) < 3 || !form.AsList()[0].IsSymbol() {
+			return nil, fmt.Errorf("Expected a method implementation (name (this args...) body), got: %s", PrStr(form, true))
+		}
+		parts := form.AsList()
+		name := parts[0].AsSymbol().Name()
+		f, err := new_user_fn(parts[1:], env, nil)
+		if err != nil {
+			return nil, err
+		}
+		f.AsFn().name = name
+		fns[name] = f.AsFn()
+	}
+	return fns, nil
+}
+
+func eval_protocol_symbol(form Value, env *Env) (*Protocol, error) {
+	v, err := Eval(form, env)
+	if err != nil {
+		return nil, err
+	}
+	return v.TryProtocol()
+}
+
+// Splits forms into groups, each starting at a form head accepts
+func split_groups(forms []Value, head func(Value) bool) [][]Value {
+	groups := make([][]Value, 0)
+	for _, form := range forms {
+		if head(form) || len(groups) == 0 {
+			groups = append(groups, []Value{form})
+		} else {
+			groups[len(groups)-1] = append(groups[len(groups)-1], form)
+		}
+	}
+	return groups
+}
+
+// (extend-type TypeName Protocol (method (this args...) body)... Protocol2 ...)
+func eval_extend_type(list []Value, env *Env) (Value, error) {
+	if len(list) < 3 {
+		return NoValue(), fmt.Errorf("extend-type => Expected a type name and protocol implementations")
+	}
+	ty, err := list[1].TrySymbol()
+	if err != nil {
+		return NoValue(), err
+	}
+	type_name, err := impl_type_name(ty.Name(), env)
+	if err != nil {
+		return NoValue(), fmt.Errorf("extend-type => %s", err)
+	}
+	for _, group := range split_groups(list[2:], Value.IsSymbol) {
+		p, err := eval_protocol_symbol(group[0], env)
+		if err != nil {
+			return NoValue(), fmt.Errorf("extend-type => %s", err)
+		}
+		fns, err := protocol_impls(group[1:], env)
+		if err != nil {
+			return NoValue(), fmt.Errorf("extend-type => %s", err)
+		}
+		if err := p.Extend(type_name, fns); err != nil {
+			return NoValue(), fmt.Errorf("extend-type => %s", err)
+		}
+	}
+	return NewNilList(), nil
+}
+
+// (extend-protocol Protocol TypeName (method (this args...) body)... TypeName2 ...)
+func eval_extend_protocol(list []Value, env *Env) (Value, error) {
+	if len(list) < 3 {
+		return NoValue(), fmt.Errorf("extend-protocol => Expected a protocol and type implementations")
+	}
+	p, err := eval_protocol_symbol(list[1], env)
+	if err != nil {
+		return NoValue(), fmt.Errorf("extend-protocol => %s", err)
+	}
+	for _, group := range split_groups(list[2:], Value.IsSymbol) {
+		ty, err := group[0].TrySymbol()
+		if err != nil {
+			return NoValue(), fmt.Errorf("extend-protocol => Expected a type name, got: %s", PrStr(group[0], true))
+		}
+		type_name, err := impl_type_name(ty.Name(), env)
+		if err != nil {
+			return NoValue(), fmt.Errorf("extend-protocol => %s", err)
+		}
+		fns, err := protocol_impls(group[1:], env)
+		if err != nil {
+			return NoValue(), fmt.Errorf("extend-protocol => %s", err)
+		}
+		if err := p.Extend(type_name, fns); err != nil {
+			return NoValue(), fmt.Errorf("extend-protocol => %s", err)
+		}
+	}
+	return NewNilList(), nil
+}
+
+// (satisfies? Protocol x)
+func eval_satisfies(vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
+	}
+	p, err := vs[0].TryProtocol()
+	if err != nil {
+		return NewError(err)
+	}
+	return NewBool(p.Satisfies(vs[1]))
+}
+
+// (extenders Protocol), the sorted names of the types it is extended to, records
+// qualified by their namespace
+func eval_extenders(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	p, err := vs[0].TryProtocol()
+	if err != nil {
+		return NewError(err)
+	}
+	names := make([]string, 0)
+	for name := range *p.impls.Load() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	res := make([]Value, 0, len(names))
+	for _, name := range names {
+		res = append(res, NewSymbol(Symbol(name)))
+	}
+	return NewList(res)
+}
@@ -0,0 +1,52 @@
+package interp
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestProtocolRecordsOfNamespaces(t *testing.T) {
+	files := fstest.MapFS{
+		"geo/shapes.smk": {Data: []byte(`(ns geo.shapes)
+(defprotocol Shape (area (this)))
+(defrecord Sq [side])
+(extend-type Sq Shape (area (this) (* (:side this) (:side this))))`)},
+	}
+	it := new_test_interpreter(t, files)
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(require '[geo.shapes :as g])`, "()"},
+		// a record of the same name in another namespace has its own impls
+		{`(defrecord Sq [side])`, "Sq"},
+		{`(g/area (g/->Sq 3))`, "9"},
+		{`(satisfies? g/Shape (->Sq 3))`, "false"},
+		{`(extend-type Sq g/Shape (area (this) 0))`, "()"},
+		{`(g/area (->Sq 3))`, "0"},
+		{`(g/area (g/->Sq 3))`, "9"},
+		{`(extend-protocol g/Shape g/Sq (area (this) 1) Number (area (this) this))`, "()"},
+		{`(g/area (g/->Sq 3))`, "1"},
+		{`(g/area 5)`, "5"},
+		{`(extenders g/Shape)`, "(Number geo.shapes/Sq user/Sq)"},
+	}
+	for _, tt := range tests {
+		if v, err := it.EvalString(tt.source); err != nil || PrStr(v, true) != tt.want {
+			t.Errorf("%s => %s, %v, expected %s", tt.source, PrStr(v, true), err, tt.want)
+		}
+	}
+
+	for _, source := range []string{
+		`(extend-type Numbr g/Shape (area (this) 0))`,
+		`(extend-protocol g/Shape Sq (area (this) 0) Strng (area (this) 0))`,
+		`(extend-type other/Sq g/Shape (area (this) 0))`,
+	} {
+		if _, err := it.EvalString(source); err == nil || !strings.Contains(err.Error(), "Unknown type") {
+			t.Errorf("%s => %v, expected an unknown type error", source, err)
+		}
+	}
+	if v, _ := it.EvalString(`(extenders g/Shape)`); PrStr(v, true) != "(Number geo.shapes/Sq user/Sq)" {
+		t.Errorf("a failed extend changed the extenders to %s", PrStr(v, true))
+	}
+}
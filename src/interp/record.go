@@ -0,0 +1,287 @@
+package interp
+
+import "fmt"
+
+// Records are named types with a fixed set of fields, defined with defrecord (or its
+// alias defstruct):
+//
+//	(defrecord Point "doc"? [x y])
+//	(->Point 1 2)            => #Point{:x 1 :y 2}, which read-str reads back
+//	(map->Point {:x 1})      => #Point{:x 1 :y nil}
+//	(Point? p) (:x p) (assoc p :x 5)
+//
+// A record behaves like an immutable hashmap keyed by its field keywords. Keys that
+// are not fields can be assoc'd too, dissoc'ing a field turns the record back into
+// a plain hashmap. TypeString reports the record name, so protocols can be extended
+// to records with extend-type. Record types belong to the namespace they are
+// defined in and are named from other namespaces like vars are, ns/Name.
+
+type RecordType struct {
+	name string
+	// namespace the type is defined in, empty outside of any
+	ns     string
+	fields []Value
+	// field keyword => index in fields
+	index map[Atom]int
+}
+
+type Record struct {
+	ty   *RecordType
+	vals []Value
+	// keys assoc'd beyond the declared fields, nil when there are none
+	ext *PersistentHashMap
+}
+
+func NewRecordType(name string, fields []string) *RecordType {
+	ty := &RecordType{
+		name:   name,
+		fields: make([]Value, len(fields)),
+		index:  make(map[Atom]int, len(fields)),
+	}
+	for i, field := range fields {
+		kw := NewAtom(":" + field)
+		ty.fields[i] = kw
+		ty.index[kw.AsAtom()] = i
+	}
+	return ty
+}
+
+func (ty *RecordType) Name() string {
+	return ty.name
+}
+
+// The name qualified by the namespace of the type, which tells apart record types
+// of the same name
+func (ty *RecordType) qualified_name() string {
+	if ty.ns == "" {
+		return ty.name
+	}
+	return ty.ns + "/" + ty.name
+}
+
+func (ty *RecordType) field_index(key Value) (int, bool) {
+	if !key.IsAtom() {
+		return 0, false
+	}
+	i, ok := ty.index[key.AsAtom()]
+	return i, ok
+}
+
+func NewRecord(ty *RecordType, vals []Value) Value {
+	return NewValue(VAL_RECORD, &Record{ty: ty, vals: vals})
+}
+
+func (r *Record) Type() *RecordType {
+	return r.ty
+}
+
+func (r *Record) Len() int {
+	if r.ext == nil {
+		return len(r.vals)
+	}
+	return len(r.vals) + r.ext.Len()
+}
+
+func (r *Record) Get(key Value) (Value, bool) {
+	if i, ok := r.ty.field_index(key); ok {
+		return r.vals[i], true
+	}
+	if r.ext == nil {
+		return NoValue(), false
+	}
+	return r.ext.Get(key)
+}
+
+// Returns a new record with key mapped to val, r is unchanged
+func (r *Record) Assoc(key Value, val Value) *Record {
+	next := &Record{ty: r.ty, vals: r.vals, ext: r.ext}
+	if i, ok := r.ty.field_index(key); ok {
+		next.vals = append([]Value(nil), r.vals...)
+		next.vals[i] = val
+		return next
+	}
+	if next.ext == nil {
+		next.ext = EmptyHashMap()
+	}
+	next.ext = next.ext.Assoc(key, val)
+	return next
+}
+
+// Without one of its fields a value is no longer a record, so dissoc'ing a field
+// returns a hashmap
+func (r *Record) Dissoc(key Value) Value {
+	if _, ok := r.ty.field_index(key); ok {
+		return NewHashMap(r.HashMap().Dissoc(key))
+	}
+	if r.ext == nil {
+		return NewValue(VAL_RECORD, r)
+	}
+	return NewValue(VAL_RECORD, &Record{ty: r.ty, vals: r.vals, ext: r.ext.Dissoc(key)})
+}
+
+// Fields in declaration order, then the extra keys
+func (r *Record) Each(fn func(key Value, val Value) bool) {
+	for i, field := range r.ty.fields {
+		if !fn(field, r.vals[i]) {
+			return
+		}
+	}
+	if r.ext != nil {
+		r.ext.Each(fn)
+	}
+}
+
+func (r *Record) HashMap() *PersistentHashMap {
+	m := EmptyHashMap()
+	r.Each(func(key Value, val Value) bool {
+		m = m.Assoc(key, val)
+		return true
+	})
+	return m
+}
+
+func (r *Record) Equals(other *Record) bool {
+	if r.ty != other.ty || r.Len() != other.Len() {
+		return false
+	}
+	equal := true
+	r.Each(func(key Value, val Value) bool {
+		oval, ok := other.Get(key)
+		equal = ok && val.Equals(oval)
+		return equal
+	})
+	return equal
+}
+
+// (defrecord Name "doc"? [fields...]), defstruct is an alias
+// Binds ->Name, map->Name and Name?
+func eval_defrecord(list []Value, env *Env) (Value, error) {
+	form := list[0].AsSymbol().Name()
+	if len(list) < 3 {
+		return NoValue(), fmt.Errorf("%s => Expected a name and a field list", form)
+	}
+	sym, err := list[1].TrySymbol()
+	if err != nil {
+		return NoValue(), err
+	}
+	rest := list[2:]
+	doc := ""
+	if rest[0].IsString() && len(rest) > 1 {
+		doc = rest[0].AsString()
+		rest = rest[1:]
+	}
+	if !rest[0].IsListLike() {
+		return NoValue(), fmt.Errorf("%s => Expected a field list, got: %s", form, PrStr(rest[0], true))
+	}
+
+	fields := make([]string, 0, len(rest[0].AsList()))
+	seen := make(map[string]bool, len(rest[0].AsList()))
+	for _, f := range rest[0].AsList() {
+		field, err := f.TrySymbol()
+		if err != nil {
+			return NoValue(), err
+		}
+		if seen[field.Name()] {
+			return NoValue(), fmt.Errorf("%s => Duplicate field %s in %s", form, field.Name(), sym.Name())
+		}
+		seen[field.Name()] = true
+		fields = append(fields, field.Name())
+	}
+
+	ty := NewRecordType(sym.Name(), fields)
+	if env.ns != nil {
+		ty.ns = env.ns.name
+		env.ns.define_record(ty)
+	}
+	arglist := NewList([]Value{NewList(record_field_symbols(fields))})
+
+	ctor_name := "->" + ty.name
+	ctor := func(vs ...Value) Value {
+		if len(vs) != len(fields) {
+			return NewError(fmt.Errorf("%s => Expected %d arguments, got: %d", ctor_name, len(fields), len(vs)))
+		}
+		return NewRecord(ty, append([]Value(nil), vs...))
+	}
+	define_record_fn(env, ctor_name, ctor, arglist, fmt.Sprintf("Returns a new %s record of its fields. %s", ty.name, doc))
+
+	map_ctor_name := "map->" + ty.name
+	map_ctor := func(vs ...Value) Value {
+		if len(vs) < 1 {
+			return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+		}
+		m, err := vs[0].TryHashMap()
+		if err != nil {
+			return NewError(err)
+		}
+		return record_from_map(ty, m)
+	}
+	define_record_fn(env, map_ctor_name, map_ctor, NewList([]Value{NewList([]Value{NewSymbol("m")})}), fmt.Sprintf("Returns a new %s record of the keys of the hashmap m, missing fields are nil.", ty.name))
+
+	pred_name := ty.name + "?"
+	pred := func(vs ...Value) Value {
+		if len(vs) < 1 {
+			return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+		}
+		return NewBool(vs[0].IsRecord() && vs[0].AsRecord().ty == ty)
+	}
+	define_record_fn(env, pred_name, pred, NewList([]Value{NewList([]Value{NewSymbol("x")})}), fmt.Sprintf("Returns true if x is a %s record.", ty.name))
+
+	return NewSymbol(Symbol(ty.name)), nil
+}
+
+// NOTE :: Returns nil when name, possibly qualified as ns/Name, is not a record
+// type defined in or referred into the namespace of env
+func find_record_type(env *Env, name string) *RecordType {
+	if env.ns == nil {
+		return nil
+	}
+	if ns, short, ok := env.resolve_qualified(name); ok {
+		if ns == nil {
+			return nil
+		}
+		return ns.find_record(short)
+	}
+	return env.ns.find_record(name)
+}
+
+func record_field_symbols(fields []string) []Value {
+	syms := make([]Value, len(fields))
+	for i, field := range fields {
+		syms[i] = NewSymbol(Symbol(field))
+	}
+	return syms
+}
+
+func define_record_fn(env *Env, name string, fn SmackFnPtr, arglists Value, doc string) {
+	f := new_core_fn(fn, "()", doc)
+	f.AsFn().name = name
+	f.meta = f.meta.Assoc(NewAtom(":arglists"), arglists)
+	env.Set(name, f)
+	env.SetMeta(name, f.meta)
+}
+
+func record_from_map(ty *RecordType, m *PersistentHashMap) Value {
+	r := &Record{ty: ty, vals: make([]Value, len(ty.fields))}
+	for i := range r.vals {
+		r.vals[i] = NewNilList()
+	}
+	m.Each(func(key Value, val Value) bool {
+		if i, ok := ty.field_index(key); ok {
+			r.vals[i] = val
+		} else {
+			if r.ext == nil {
+				r.ext = EmptyHashMap()
+			}
+			r.ext = r.ext.Assoc(key, val)
+		}
+		return true
+	})
+	return NewValue(VAL_RECORD, r)
+}
+
+func eval_isrecord(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	return NewBool(vs[0].IsRecord())
+}
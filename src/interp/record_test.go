@@ -0,0 +1,67 @@
+package interp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecords(t *testing.T) {
+	it, err := NewInterpreter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := it.EvalString(`(defrecord Point "A point" [x y]) (def p (->Point 1 2))`); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`p`, "#Point{:x 1 :y 2}"},
+		{`(map->Point {:x 1})`, "#Point{:x 1 :y ()}"},
+		{`(Point? p)`, "true"},
+		{`(Point? {:x 1 :y 2})`, "false"},
+		{`(record? p)`, "true"},
+		{`(:x p)`, "1"},
+		{`(mget p :y)`, "2"},
+		{`(assoc p :x 5)`, "#Point{:x 5 :y 2}"},
+		{`(assoc p :z 3)`, "#Point{:x 1 :y 2 :z 3}"},
+		{`(Point? (dissoc p :x))`, "false"},
+		{`(dissoc p :x)`, "{:y 2}"},
+		{`(= p (->Point 1 2))`, "true"},
+		{`(= p {:x 1 :y 2})`, "false"},
+		{`(len p)`, "2"},
+		// printed records read back
+		{`(= p (unwrap (read-str (pr-str p))))`, "true"},
+		{`(def q (assoc p :z "z")) (= q (unwrap (read-str (pr-str q))))`, "true"},
+		{`(Point? (unwrap (read-str "#Point{:x 1 :y 2}")))`, "true"},
+		{`#Point{:x 1 :y (+ 1 1)}`, "#Point{:x 1 :y 2}"},
+		{`(read-str "#Nope{:x 1}")`, "(ok (map->Nope {:x 1}))"},
+	}
+	for _, tt := range tests {
+		if v, err := it.EvalString(tt.source); err != nil || PrStr(v, true) != tt.want {
+			t.Errorf("%s => %s, %v, expected %s", tt.source, PrStr(v, true), err, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		source string
+		err    string
+	}{
+		{`(defrecord Bad [x x])`, "Duplicate field x"},
+		{`(defrecord Bad x)`, "Expected a field list"},
+		{`(->Point 1)`, "Expected 2 arguments"},
+		{`(map->Point 1)`, "not a hashmap"},
+		{`(read-str "#Point{:x}")`, "even number"},
+	} {
+		// errors are returned, or (err msg) for read-str
+		v, err := it.EvalString(tt.source)
+		msg := PrStr(v, true)
+		if err != nil {
+			msg = err.Error()
+		}
+		if (err == nil && !v.IsErr()) || !strings.Contains(msg, tt.err) {
+			t.Errorf("%s => %s, expected an error containing %q", tt.source, msg, tt.err)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package interp
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// SmackRef is a mutable reference cell that is safe to share between goroutines.
+// The current value is swapped atomically, so readers never block and writers
+// retry (swap!) or fail (compare-and-set!) when another goroutine got there first.
+//
+// An optional validator fn must return truthy for a new value to be accepted, and
+// watch fns are called with (key ref old new) after every change.
+type SmackRef struct {
+	state     atomic.Pointer[Value]
+	validator atomic.Pointer[SmackFn]
+	watches   atomic.Pointer[PersistentHashMap]
+}
+
+func NewRef(val Value) Value {
+	r := &SmackRef{}
+	r.state.Store(&val)
+	r.watches.Store(EmptyHashMap())
+	return NewValue(VAL_REF, r)
+}
+
+func (self *SmackRef) Deref() Value {
+	return *self.state.Load()
+}
+
+func (self *SmackRef) validate(frame *dyn_frame, val Value) error {
+	validator := self.validator.Load()
+	if validator == nil {
+		return nil
+	}
+	res, err := apply_fn(validator, frame, []Value{val})
+	if err != nil {
+		return err
+	}
+	if res.IsError() {
+		return res.AsError()
+	}
+	if res.IsFalsey() {
+		return fmt.Errorf("Invalid reference state: %s", PrStr(val, true))
+	}
+	return nil
+}
+
+func (self *SmackRef) notify(frame *dyn_frame, ref Value, old Value, new Value) {
+	self.watches.Load().Each(func(key Value, val Value) bool {
+		apply_fn(val.AsFn(), frame, []Value{key, ref, old, new})
+		return true
+	})
+}
+
+// Sets the value regardless of the current one
+func (self *SmackRef) Reset(frame *dyn_frame, ref Value, val Value) (Value, error) {
+	if err := self.validate(frame, val); err != nil {
+		return NoValue(), err
+	}
+	old := self.state.Swap(&val)
+	self.notify(frame, ref, *old, val)
+	return val, nil
+}
+
+// Sets the value to (f current args...), retrying with the latest value until no
+// other goroutine has changed it in between. f may be called more than once so it
+// should be free of side effects.
+func (self *SmackRef) Swap(frame *dyn_frame, ref Value, f *SmackFn, args []Value) (Value, error) {
+	for {
+		current := self.state.Load()
+		val, err := apply_fn(f, frame, append([]Value{*current}, args...))
+		if err != nil {
+			return NoValue(), err
+		}
+		if val.IsError() {
+			return NoValue(), val.AsError()
+		}
+		if err := self.validate(frame, val); err != nil {
+			return NoValue(), err
+		}
+		if self.state.CompareAndSwap(current, &val) {
+			self.notify(frame, ref, *current, val)
+			return val, nil
+		}
+	}
+}
+
+// Sets the value to new only if the current value is equal to old
+func (self *SmackRef) CompareAndSet(frame *dyn_frame, ref Value, old Value, new Value) (bool, error) {
+	current := self.state.Load()
+	if !current.Equals(old) {
+		return false, nil
+	}
+	if err := self.validate(frame, new); err != nil {
+		return false, err
+	}
+	if !self.state.CompareAndSwap(current, &new) {
+		return false, nil
+	}
+	self.notify(frame, ref, *current, new)
+	return true, nil
+}
+
+func (self *SmackRef) AddWatch(key Value, f *SmackFn) {
+	for {
+		watches := self.watches.Load()
+		if self.watches.CompareAndSwap(watches, watches.Assoc(key, NewValue(VAL_FN, f))) {
+			return
+		}
+	}
+}
+
+func (self *SmackRef) RemoveWatch(key Value) {
+	for {
+		watches := self.watches.Load()
+		if self.watches.CompareAndSwap(watches, watches.Dissoc(key)) {
+			return
+		}
+	}
+}
+
+// (ref x) or (ref x validator)
+func eval_ref(frame *dyn_frame, vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1 or 2, got 0"))
+	}
+	ref := NewRef(vs[0])
+	if len(vs) > 1 {
+		if res := eval_set_validator(frame, ref, vs[1]); res.IsError() {
+			return res
+		}
+	}
+	return ref
+}
+
+func eval_deref(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	if r, err := vs[0].TryRef(); err == nil {
+		return r.Deref()
+	} else {
+		return NewError(err)
+	}
+}
+
+func eval_reset(frame *dyn_frame, vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
+	}
+	r, err := vs[0].TryRef()
+	if err != nil {
+		return NewError(err)
+	}
+	if val, err := r.Reset(frame, vs[0], vs[1]); err == nil {
+		return val
+	} else {
+		return NewError(err)
+	}
+}
+
+func eval_swap(frame *dyn_frame, vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected at least 2, got: %d", len(vs)))
+	}
+	r, err := vs[0].TryRef()
+	if err != nil {
+		return NewError(err)
+	}
+	f, err := vs[1].TryFn()
+	if err != nil {
+		return NewError(err)
+	}
+	if val, err := r.Swap(frame, vs[0], f, vs[2:]); err == nil {
+		return val
+	} else {
+		return NewError(err)
+	}
+}
+
+func eval_compare_and_set(frame *dyn_frame, vs ...Value) Value {
+	if len(vs) < 3 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 3, got: %d", len(vs)))
+	}
+	r, err := vs[0].TryRef()
+	if err != nil {
+		return NewError(err)
+	}
+	if ok, err := r.CompareAndSet(frame, vs[0], vs[1], vs[2]); err == nil {
+		return NewBool(ok)
+	} else {
+		return NewError(err)
+	}
+}
+
+// (set-validator! ref f), a nil f removes the validator.
+// The current value must pass the new validator.
+func eval_set_validator(frame *dyn_frame, vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
+	}
+	r, err := vs[0].TryRef()
+	if err != nil {
+		return NewError(err)
+	}
+	if is_nil(vs[1]) {
+		r.validator.Store(nil)
+		return NewNilList()
+	}
+	f, err := vs[1].TryFn()
+	if err != nil {
+		return NewError(err)
+	}
+
+	prev := r.validator.Swap(f)
+	if err := r.validate(frame, r.Deref()); err != nil {
+		r.validator.Store(prev)
+		return NewError(err)
+	}
+	return NewNilList()
+}
+
+// (add-watch ref key f), f is called with (key ref old new) after every change
+func eval_add_watch(vs ...Value) Value {
+	if len(vs) < 3 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 3, got: %d", len(vs)))
+	}
+	r, err := vs[0].TryRef()
+	if err != nil {
+		return NewError(err)
+	}
+	f, err := vs[2].TryFn()
+	if err != nil {
+		return NewError(err)
+	}
+	r.AddWatch(vs[1], f)
+	return vs[0]
+}
+
+func eval_remove_watch(vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
+	}
+	r, err := vs[0].TryRef()
+	if err != nil {
+		return NewError(err)
+	}
+	r.RemoveWatch(vs[1])
+	return vs[0]
+}
@@ -0,0 +1,85 @@
+package interp
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRef(t *testing.T) {
+	it, err := NewInterpreter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`(def r (ref 1))`, `#<ref 1>`},
+		{`@r`, `1`},
+		{`(deref r)`, `1`},
+		{`(reset! r 5)`, `5`},
+		{`(swap! r + 2)`, `7`},
+		{`(compare-and-set! r 7 8)`, `true`},
+		{`(compare-and-set! r 7 9)`, `false`},
+		{`@r`, `8`},
+		// validators reject new values and leave the ref as it was
+		{`(def v (ref 1))`, `#<ref 1>`},
+		{`(set-validator! v (fn (x) (> x 0)))`, `()`},
+		{`(reset! v 2)`, `2`},
+		{`@v`, `2`},
+		// watches are called with their key, the ref, the old and the new value
+		{`(def seen (ref ()))`, `#<ref ()>`},
+		{`(def w (ref 0))`, `#<ref 0>`},
+		{`(add-watch w :k (fn (k rr old new) (reset! seen [k old new])))`, `#<ref 0>`},
+		{`(reset! w 3)`, `3`},
+		{`@seen`, `[:k 0 3]`},
+		{`(remove-watch w :k)`, `#<ref 3>`},
+		{`(reset! w 4)`, `4`},
+		{`@seen`, `[:k 0 3]`},
+	}
+	for _, tt := range tests {
+		if v, err := it.EvalString(tt.source); err != nil || PrStr(v, true) != tt.want {
+			t.Errorf("%s => %s, %v, expected %s", tt.source, PrStr(v, true), err, tt.want)
+		}
+	}
+
+	for _, source := range []string{`(reset! v -1)`, `(swap! v + -5)`, `(set-validator! (ref -1) (fn (x) (> x 0)))`} {
+		if _, err := it.EvalString(source); err == nil || !strings.Contains(err.Error(), "Invalid reference state") {
+			t.Errorf("%s => %v, expected an invalid state", source, err)
+		}
+	}
+	if v, err := it.EvalString(`@v`); err != nil || PrStr(v, true) != "2" {
+		t.Errorf("@v after failed updates => %s, %v", PrStr(v, true), err)
+	}
+	if _, err := it.EvalString(`(deref 1)`); err == nil {
+		t.Errorf("(deref 1) succeeded")
+	}
+}
+
+// swap! retries until its update applies, so none is lost to another goroutine
+func TestRefSwapGoroutines(t *testing.T) {
+	env := NewCoreEnv()
+	r := NewRef(NewNumber(0))
+	swap, plus := env.Find("swap!"), env.Find("+")
+
+	const goroutines, swaps = 8, 200
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < swaps; j++ {
+				if res, err := Call(swap, []Value{r, plus, NewNumber(1)}); err != nil || res.IsError() {
+					t.Errorf("swap! => %s, %v", PrStr(res, true), err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := r.AsRef().Deref(); !n.Equals(NewNumber(goroutines * swaps)) {
+		t.Errorf("ref holds %s after %d swaps", PrStr(n, true), goroutines*swaps)
+	}
+}
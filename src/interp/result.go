@@ -0,0 +1,244 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Option and Result values make failure explicit instead of signalling it with nil,
+// -1 or a bare error:
+//
+//	(some x) / none     => a value that may be absent
+//	(ok x)   / (err e)  => the outcome of something that may fail
+//
+// (? expr) unwraps (some x) and (ok x) to x, and returns none, (err e) or an error
+// from the enclosing fn right away. Any other value is passed through as is.
+//
+// none is falsey, every other Option and Result is truthy.
+
+type Option struct {
+	val     Value
+	is_some bool
+}
+
+type Result struct {
+	val   Value
+	is_ok bool
+}
+
+func NewSome(val Value) Value {
+	return NewValue(VAL_OPTION, Option{val, true})
+}
+
+func NewNone() Value {
+	return NewValue(VAL_OPTION, Option{NewNilList(), false})
+}
+
+func NewOk(val Value) Value {
+	return NewValue(VAL_RESULT, Result{val, true})
+}
+
+func NewErr(val Value) Value {
+	return NewValue(VAL_RESULT, Result{val, false})
+}
+
+// (err "message") from a Go error
+func NewErrFrom(err error) Value {
+	return NewErr(NewString(err.Error()))
+}
+
+func (v Value) IsSomeOption() bool {
+	return v.Type() == VAL_OPTION && v.AsOption().is_some
+}
+
+func (v Value) IsNoneOption() bool {
+	return v.Type() == VAL_OPTION && !v.AsOption().is_some
+}
+
+func (v Value) IsOk() bool {
+	return v.Type() == VAL_RESULT && v.AsResult().is_ok
+}
+
+func (v Value) IsErr() bool {
+	return v.Type() == VAL_RESULT && !v.AsResult().is_ok
+}
+
+// Returned from Eval by (? expr) to unwind to the enclosing fn call, which returns
+// val in place of its body
+type propagation struct {
+	val Value
+}
+
+func (p *propagation) Error() string {
+	return fmt.Sprintf("? => %s escaped outside of a function", PrStr(p.val, true))
+}
+
+// The value a fn call returns when err is a propagation from (? expr) in its body
+func catch_propagation(res Value, err error) (Value, error) {
+	var p *propagation
+	if errors.As(err, &p) {
+		return p.val, nil
+	}
+	return res, err
+}
+
+// (? expr)
+func eval_propagate(list []Value, env *Env) (Value, error) {
+	if len(list) != 2 {
+		return NoValue(), fmt.Errorf("? => Expected exactly 1 form, got: %d", len(list)-1)
+	}
+	v, err := Eval(list[1], env)
+	if err != nil {
+		return NoValue(), err
+	}
+	switch {
+	case v.IsSomeOption():
+		return v.AsOption().val, nil
+	case v.IsOk():
+		return v.AsResult().val, nil
+	case v.IsNoneOption(), v.IsErr(), v.IsError():
+		return NoValue(), &propagation{v}
+	default:
+		return v, nil
+	}
+}
+
+func eval_some(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	return NewSome(vs[0])
+}
+
+func eval_ok(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	return NewOk(vs[0])
+}
+
+func eval_err(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	return NewErr(vs[0])
+}
+
+func eval_issome(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	return NewBool(vs[0].IsSomeOption())
+}
+
+func eval_isnone(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	return NewBool(vs[0].IsNoneOption())
+}
+
+func eval_isok(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	return NewBool(vs[0].IsOk())
+}
+
+func eval_isoption(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	return NewBool(vs[0].Type() == VAL_OPTION)
+}
+
+func eval_isresult(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	return NewBool(vs[0].Type() == VAL_RESULT)
+}
+
+// (unwrap x), the value of (some x) or (ok x), an error for none and (err e)
+func eval_unwrap(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	v := vs[0]
+	switch {
+	case v.IsSomeOption():
+		return v.AsOption().val
+	case v.IsOk():
+		return v.AsResult().val
+	case v.IsNoneOption(), v.IsErr():
+		return NewError(fmt.Errorf("unwrap => Called on %s", PrStr(v, true)))
+	default:
+		return NewError(fmt.Errorf("unwrap => Expected Option or Result, got: %s", v.TypeString()))
+	}
+}
+
+// (unwrap-or x default), default in place of none and (err e)
+func eval_unwrap_or(vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
+	}
+	v := vs[0]
+	switch {
+	case v.IsSomeOption():
+		return v.AsOption().val
+	case v.IsOk():
+		return v.AsResult().val
+	case v.IsNoneOption(), v.IsErr():
+		return vs[1]
+	default:
+		return NewError(fmt.Errorf("unwrap-or => Expected Option or Result, got: %s", v.TypeString()))
+	}
+}
+
+// (unwrap-err x), the e of (err e)
+func eval_unwrap_err(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	if !vs[0].IsErr() {
+		return NewError(fmt.Errorf("unwrap-err => Called on %s", PrStr(vs[0], true)))
+	}
+	return vs[0].AsResult().val
+}
+
+// (parse-num s) => (ok n) or (err msg)
+func eval_parse_num(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	s, err := vs[0].TryString()
+	if err != nil {
+		return NewErrFrom(err)
+	}
+	s = strings.TrimSpace(s)
+	if len(s) == 0 || !is_number_token(s) {
+		return NewErr(NewString(fmt.Sprintf("parse-num => Not a number: %s", escape_string(s))))
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return NewErr(NewString(fmt.Sprintf("parse-num => Not a number: %s", escape_string(s))))
+	}
+	return NewOk(NewNumber(n))
+}
+
+// (spit filename s) => (ok nil) or (err msg)
+func eval_spit(sys *system, vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
+	}
+	filename, err := vs[0].TryString()
+	if err != nil {
+		return NewErrFrom(err)
+	}
+	if err := sys.write_file(filename, []byte(vs[1].String())); err != nil {
+		return NewErrFrom(err)
+	}
+	return NewOk(NewNilList())
+}
@@ -0,0 +1,59 @@
+package interp
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOptionsAndResults(t *testing.T) {
+	it := new_test_interpreter(t, fstest.MapFS{
+		"data.txt": {Data: []byte("data")},
+	})
+	if _, err := it.EvalString(`
+		(def half (fn (n) (if (= n 0) (err "zero") (ok (* n 0.5)))))
+		(def quarter (fn (n) (half (? (half n)))))
+		(def inc-some (fn (o) (+ 1 (? o))))`); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		source string
+		want   string
+	}{
+		// ? unwraps, or returns from the enclosing fn right away
+		{`(quarter 8)`, `(ok 2)`},
+		{`(quarter 0)`, `(err "zero")`},
+		{`(inc-some (some 1))`, `2`},
+		{`(inc-some none)`, `none`},
+		{`(inc-some 5)`, `6`},
+		// core fns that may fail return Results
+		{`(parse-num "12")`, `(ok 12)`},
+		{`(parse-num "x")`, `(err "parse-num => Not a number: \"x\"")`},
+		{`(slurp "data.txt")`, `(ok "data")`},
+		{`[(result? (slurp "missing.txt")) (ok? (slurp "missing.txt"))]`, `[true false]`},
+		{`(unwrap (some 1))`, `1`},
+		{`(unwrap (ok 2))`, `2`},
+		{`(unwrap-or none 3)`, `3`},
+		{`(unwrap-or (err "e") 3)`, `3`},
+		{`(unwrap-or (some 1) 3)`, `1`},
+		{`(unwrap-err (err "e"))`, `"e"`},
+		{`[(some? (some 1)) (none? none) (ok? (ok 1)) (ok? (err 1)) (option? none) (result? (err 1)) (option? 1)]`, `[true true true false true true false]`},
+		// only none is falsey
+		{`[(if none 1 2) (if (some false) 1 2) (if (err 1) 1 2)]`, `[2 1 1]`},
+	}
+	for _, tt := range tests {
+		if v, err := it.EvalString(tt.source); err != nil || PrStr(v, true) != tt.want {
+			t.Errorf("%s => %s, %v, expected %s", tt.source, PrStr(v, true), err, tt.want)
+		}
+	}
+
+	for _, tt := range []struct{ source, want string }{
+		{`(unwrap none)`, "unwrap => Called on none"},
+		{`(unwrap (err "e"))`, `unwrap => Called on (err "e")`},
+		{`(unwrap-err (ok 1))`, "unwrap-err => Called on (ok 1)"},
+	} {
+		if _, err := it.EvalString(tt.source); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s => %v, expected %s", tt.source, err, tt.want)
+		}
+	}
+}
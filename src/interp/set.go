@@ -0,0 +1,125 @@
+package interp
+
+import "fmt"
+
+// PersistentSet is a PersistentHashMap whose keys are the elements, mapped to themselves
+type PersistentSet struct {
+	items *PersistentHashMap
+}
+
+func EmptySet() *PersistentSet {
+	return &PersistentSet{EmptyHashMap()}
+}
+
+func NewPersistentSet(vals []Value) *PersistentSet {
+	set := EmptySet()
+	for _, v := range vals {
+		set = set.Conj(v)
+	}
+	return set
+}
+
+func (self *PersistentSet) Len() int {
+	return self.items.Len()
+}
+
+func (self *PersistentSet) Contains(v Value) bool {
+	_, ok := self.items.Get(v)
+	return ok
+}
+
+// Returns the element equal to v stored in the set
+func (self *PersistentSet) Get(v Value) (Value, bool) {
+	return self.items.Get(v)
+}
+
+func (self *PersistentSet) Conj(v Value) *PersistentSet {
+	return &PersistentSet{self.items.Assoc(v, v)}
+}
+
+func (self *PersistentSet) Disj(v Value) *PersistentSet {
+	return &PersistentSet{self.items.Dissoc(v)}
+}
+
+func (self *PersistentSet) Each(fn func(v Value) bool) {
+	self.items.Each(func(key Value, _ Value) bool {
+		return fn(key)
+	})
+}
+
+func (self *PersistentSet) Slice() []Value {
+	res := make([]Value, 0, self.Len())
+	self.Each(func(v Value) bool {
+		res = append(res, v)
+		return true
+	})
+	return res
+}
+
+func eval_hash_set(vs ...Value) Value {
+	return NewSet(NewPersistentSet(vs))
+}
+
+func eval_set(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	if vs[0].IsSet() {
+		return vs[0]
+	}
+	if list, err := vs[0].TryList(); err == nil {
+		return NewSet(NewPersistentSet(list))
+	} else {
+		return NewError(err)
+	}
+}
+
+func eval_isset(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 1, got 0"))
+	}
+	return NewBool(vs[0].IsSet())
+}
+
+func eval_disj(vs ...Value) Value {
+	if len(vs) < 1 {
+		return NewError(fmt.Errorf("Invalid arity. Expected at least 1, got 0"))
+	}
+	if set, err := vs[0].TrySet(); err == nil {
+		for _, v := range vs[1:] {
+			set = set.Disj(v)
+		}
+		return NewSet(set)
+	} else {
+		return NewError(err)
+	}
+}
+
+// (contains? coll key), keys of hashmaps, elements of sets and indices of lists and arrays
+func eval_contains(vs ...Value) Value {
+	if len(vs) < 2 {
+		return NewError(fmt.Errorf("Invalid arity. Expected 2, got: %d", len(vs)))
+	}
+	coll := vs[0]
+	key := vs[1]
+	switch coll.Type() {
+	case VAL_HASHMAP:
+		_, ok := coll.AsHashMap().Get(key)
+		return NewBool(ok)
+	case VAL_RECORD:
+		_, ok := coll.AsRecord().Get(key)
+		return NewBool(ok)
+	case VAL_SET:
+		return NewBool(coll.AsSet().Contains(key))
+	case VAL_LIST:
+		fallthrough
+	case VAL_ARRAY:
+		if !key.IsNumber() {
+			return NewBool(false)
+		}
+		i := int(key.AsNumber())
+		return NewBool(i >= 0 && i < coll.Len())
+	default:
+		return NewError(fmt.Errorf("contains? => Expected HashMap, Set, List or Array, got: %s", coll.TypeString()))
+	}
+}
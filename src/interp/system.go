@@ -0,0 +1,114 @@
+package interp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// What scripts see of the world outside the interpreter: the streams println,
+// read-line and doc use, the files load-file, require, slurp and spit reach and
+// the Go packages they may refer to. Every core env has its own, see Interpreter.
+
+type system struct {
+	stdin  *bufio.Reader
+	stdout io.Writer
+	stderr io.Writer
+	// nil for the files of the OS
+	fsys fs.FS
+	// Go packages scripts may use, every registered one when nil
+	go_packages map[string]bool
+}
+
+// An fs.FS that spit can write to, see WithFS
+type WriteFileFS interface {
+	fs.FS
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// The process' streams and files, shared by every env that is not configured otherwise
+var os_system = sync.OnceValue(func() *system {
+	return &system{
+		stdin:  bufio.NewReader(os.Stdin),
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+})
+
+// The system of the interpreter env belongs to
+func (e *Env) system() *system {
+	if e.ns != nil {
+		return e.ns.registry.sys
+	}
+	return os_system()
+}
+
+// Absolute paths of an fs.FS start at its root, which is also the working
+// directory. .. never leaves it.
+func (s *system) abs(path string) string {
+	if s.fsys == nil {
+		if abs, err := filepath.Abs(path); err == nil {
+			return abs
+		}
+		return path
+	}
+	return filepath.Join(string(filepath.Separator), path)
+}
+
+// The name of path in the fs.FS
+func (s *system) fs_name(path string) string {
+	name := strings.TrimPrefix(filepath.ToSlash(s.abs(path)), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+func (s *system) read_file(path string) ([]byte, error) {
+	if s.fsys == nil {
+		return os.ReadFile(path)
+	}
+	return fs.ReadFile(s.fsys, s.fs_name(path))
+}
+
+func (s *system) write_file(path string, data []byte) error {
+	if s.fsys == nil {
+		return os.WriteFile(path, data, 0644)
+	}
+	if w, ok := s.fsys.(WriteFileFS); ok {
+		return w.WriteFile(s.fs_name(path), data, 0644)
+	}
+	return &fs.PathError{Op: "write", Path: path, Err: fmt.Errorf("read only file system")}
+}
+
+func (s *system) is_file(path string) bool {
+	var info fs.FileInfo
+	var err error
+	if s.fsys == nil {
+		info, err = os.Stat(path)
+	} else {
+		info, err = fs.Stat(s.fsys, s.fs_name(path))
+	}
+	return err == nil && !info.IsDir()
+}
+
+func (s *system) allows_go_package(name string) bool {
+	return s.go_packages == nil || s.go_packages[name]
+}
+
+// (read-line) => (some line) without its line ending, none at the end of stdin
+func eval_read_line(s *system, vs ...Value) Value {
+	line, err := s.stdin.ReadString('\n')
+	if err == io.EOF && len(line) == 0 {
+		return NewNone()
+	}
+	if err != nil && err != io.EOF {
+		return NewError(err)
+	}
+	return NewSome(NewString(strings.TrimRight(line, "\r\n")))
+}
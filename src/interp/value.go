@@ -18,6 +18,13 @@ const (
 	VAL_CHANNEL
 	VAL_ERROR
 	VAL_FN
+	VAL_REF
+	VAL_SET
+	VAL_PROTOCOL
+	VAL_RECORD
+	VAL_OPTION
+	VAL_RESULT
+	VAL_OBJECT
 )
 
 const (
@@ -31,12 +38,37 @@ type SmackFn struct {
 	env    *Env
 	fn     SmackFnPtr
 	ty     int
+	name   string
+	// Macros are called with their arguments unevaluated and the result is evaluated in their place
+	is_macro bool
+	// Set when the fn is a multimethod, see defmulti
+	multi *MultiFn
+	// Set when the body was compiled to Go, see NewCompiledFn
+	native NativeBody
+	// Set for core fns that call other fns, which run with the dynamic bindings of
+	// the caller, see new_core_frame_fn
+	with_frame func(frame *dyn_frame, vs ...Value) Value
 }
 
 func (self *SmackFn) Apply(vs ...Value) Value {
 	return self.fn(vs...)
 }
 
+// Calls the core fn self with the dynamic bindings of the caller
+func (self *SmackFn) call_core(frame *dyn_frame, vs []Value) Value {
+	if self.with_frame != nil {
+		return self.with_frame(frame, vs...)
+	}
+	return self.fn(vs...)
+}
+
+// The env the body of the user fn self is evaluated in when called with vs
+func (self *SmackFn) call_env(frame *dyn_frame, vs []Value) *Env {
+	env := NewEnv(self.env, self.params.AsList(), vs)
+	env.frame = frame
+	return env
+}
+
 func (self *SmackFn) IsNil() bool {
 	return self.fn == nil
 }
@@ -49,7 +81,14 @@ type SmackFnPtr func(...Value) Value
 type EnvData map[string]SmackFn
 
 func (f SmackFn) String() string {
-	return fmt.Sprintf("%#v", f)
+	if len(f.name) == 0 {
+		return "#<fn>"
+	}
+	return fmt.Sprintf("#<fn %s>", f.name)
+}
+
+func (self *SmackFn) Name() string {
+	return self.name
 }
 
 const ATOM_PREFIX = rune(0x269B)
@@ -81,19 +120,20 @@ func (s Symbol) String() string {
 }
 
 type Value struct {
-	ty  uint32
-	val interface{}
+	ty   uint32
+	val  interface{}
+	meta *PersistentHashMap
 }
 
 func NoValue() Value {
 	ty := uint32(VAL_NONE)
 	var val interface{} = nil
-	return Value{ty, val}
+	return Value{ty, val, nil}
 }
 
 func NewValue[T any](ty uint32, val T) Value {
 	return Value{
-		ty, val,
+		ty, val, nil,
 	}
 }
 
@@ -113,13 +153,21 @@ func NewList(vals []Value) Value {
 	return NewValue(VAL_LIST, vals)
 }
 func NewArray(val []Value) Value {
+	return NewVector(NewPersistentVector(val))
+}
+
+func NewVector(val *PersistentVector) Value {
 	return NewValue(VAL_ARRAY, val)
 }
 
-func NewHashMap(val SmackMap) Value {
+func NewHashMap(val *PersistentHashMap) Value {
 	return NewValue(VAL_HASHMAP, val)
 }
 
+func NewSet(val *PersistentSet) Value {
+	return NewValue(VAL_SET, val)
+}
+
 func NewSymbol(val Symbol) Value {
 	return NewValue(VAL_SYMBOL, val)
 }
@@ -128,16 +176,9 @@ func NewError(err error) Value {
 	return NewValue(VAL_ERROR, err)
 }
 
+// Atoms are strings, so equal ones are equal without interning them
 func NewAtom(name string) Value {
-	atom_name := fmt.Sprintf("%c%s", ATOM_PREFIX, name)
-
-	if cached, ok := smack_atoms[atom_name]; ok {
-		return NewValue(VAL_ATOM, cached)
-	} else {
-		atom := Atom(atom_name)
-		smack_atoms[atom_name] = atom
-		return NewValue(VAL_ATOM, atom)
-	}
+	return NewValue(VAL_ATOM, Atom(fmt.Sprintf("%c%s", ATOM_PREFIX, name)))
 }
 
 func NewChan() Value {
@@ -145,7 +186,8 @@ func NewChan() Value {
 	return NewValue(VAL_CHANNEL, c)
 }
 
-func new_core_fn(fn SmackFnPtr) Value {
+// arglists is read as a list of parameter lists, e.g. "((x) (x y))"
+func new_core_fn(fn SmackFnPtr, arglists string, doc string) Value {
 	sfn := &SmackFn{
 		body:   NewNilList(),
 		params: NewNilList(),
@@ -153,13 +195,28 @@ func new_core_fn(fn SmackFnPtr) Value {
 		fn:     fn,
 		ty:     SMACK_FN_CORE,
 	}
-	return NewValue(VAL_FN, sfn)
+	v := NewValue(VAL_FN, sfn)
+	v.meta = EmptyHashMap().Assoc(NewAtom(":doc"), NewString(doc))
+	if args, err := Read(arglists); err == nil {
+		v.meta = v.meta.Assoc(NewAtom(":arglists"), args)
+	}
+	return v
+}
+
+// A core fn that is handed the dynamic bindings in effect where it is called.
+// Called from Go without them, see SmackFn.Apply, it runs without any.
+func new_core_frame_fn(fn func(frame *dyn_frame, vs ...Value) Value, arglists string, doc string) Value {
+	v := new_core_fn(func(vs ...Value) Value {
+		return fn(no_bindings, vs...)
+	}, arglists, doc)
+	v.AsFn().with_frame = fn
+	return v
 }
 
 func NewFn(body Value, params Value, env *Env, fn SmackFnPtr) Value {
 	ty := SMACK_FN_USER
 	fun := &SmackFn{
-		body, params, env, fn, ty,
+		body, params, env, fn, ty, "", false, nil, nil, nil,
 	}
 	return NewValue(VAL_FN, fun)
 }
@@ -168,6 +225,11 @@ func NewNilList() Value {
 	return NewList(nil_list)
 }
 
+// nil is read as (and represented by) the empty list
+func is_nil(v Value) bool {
+	return v.IsList() && len(v.AsList()) == 0
+}
+
 func (v Value) AsNumber() float64 {
 	return v.val.(float64)
 }
@@ -180,12 +242,40 @@ func (v Value) AsBool() bool {
 	return v.val.(bool)
 }
 
+// NOTE :: Arrays are backed by a PersistentVector once evaluated, in which case
+// the elements are copied out into a new slice
 func (v Value) AsList() []Value {
+	if vec, ok := v.val.(*PersistentVector); ok {
+		return vec.Slice()
+	}
 	return v.val.([]Value)
 }
 
-func (v Value) AsHashMap() SmackMap {
-	return v.val.(SmackMap)
+// NOTE :: Quoted array and hashmap literals are never evaluated and are still
+// stored as a []Value, these are converted as is.
+func (v Value) AsVector() *PersistentVector {
+	if list, ok := v.val.([]Value); ok {
+		return NewPersistentVector(list)
+	}
+	return v.val.(*PersistentVector)
+}
+
+func (v Value) AsHashMap() *PersistentHashMap {
+	if list, ok := v.val.([]Value); ok {
+		m := EmptyHashMap()
+		for i := 1; i < len(list); i = i + 2 {
+			m = m.Assoc(list[i-1], list[i])
+		}
+		return m
+	}
+	return v.val.(*PersistentHashMap)
+}
+
+func (v Value) AsSet() *PersistentSet {
+	if list, ok := v.val.([]Value); ok {
+		return NewPersistentSet(list)
+	}
+	return v.val.(*PersistentSet)
 }
 
 func (v Value) AsSymbol() Symbol {
@@ -200,6 +290,30 @@ func (v Value) AsFn() *SmackFn {
 	return v.val.(*SmackFn)
 }
 
+func (v Value) AsRef() *SmackRef {
+	return v.val.(*SmackRef)
+}
+
+func (v Value) AsProtocol() *Protocol {
+	return v.val.(*Protocol)
+}
+
+func (v Value) AsRecord() *Record {
+	return v.val.(*Record)
+}
+
+func (v Value) AsObject() *GoObject {
+	return v.val.(*GoObject)
+}
+
+func (v Value) AsOption() Option {
+	return v.val.(Option)
+}
+
+func (v Value) AsResult() Result {
+	return v.val.(Result)
+}
+
 func (v Value) AsError() error {
 	return v.val.(error)
 }
@@ -244,10 +358,30 @@ func (v Value) IsHashMap() bool {
 	return v.Type() == VAL_HASHMAP
 }
 
+func (v Value) IsSet() bool {
+	return v.Type() == VAL_SET
+}
+
 func (v Value) IsFn() bool {
 	return v.Type() == VAL_FN
 }
 
+func (v Value) IsRef() bool {
+	return v.Type() == VAL_REF
+}
+
+func (v Value) IsProtocol() bool {
+	return v.Type() == VAL_PROTOCOL
+}
+
+func (v Value) IsObject() bool {
+	return v.Type() == VAL_OBJECT
+}
+
+func (v Value) IsRecord() bool {
+	return v.Type() == VAL_RECORD
+}
+
 func (v Value) IsChan() bool {
 	return v.Type() == VAL_CHANNEL
 }
@@ -279,13 +413,14 @@ func (v Value) IsTruthy() bool {
 	case VAL_BOOLEAN:
 		return v.AsBool()
 	case VAL_ARRAY:
-		fallthrough
+		return v.Len() > 0
 	case VAL_LIST:
 		l := v.AsList()
 		return l != nil && len(l) > 0
 	case VAL_HASHMAP:
-		m := v.AsHashMap()
-		return m != nil && len(m) > 0
+		fallthrough
+	case VAL_SET:
+		return v.Len() > 0
 	case VAL_FN:
 		f := v.AsFn()
 		return !f.IsNil()
@@ -294,6 +429,10 @@ func (v Value) IsTruthy() bool {
 	case VAL_CHANNEL:
 		c := v.AsChan()
 		return c != nil
+	case VAL_REF, VAL_PROTOCOL, VAL_RECORD, VAL_RESULT, VAL_OBJECT:
+		return true
+	case VAL_OPTION:
+		return v.AsOption().is_some
 	case VAL_NONE:
 		return false
 	default:
@@ -338,7 +477,15 @@ func (v Value) TryList() ([]Value, error) {
 	}
 }
 
-func (v Value) TryHashMap() (SmackMap, error) {
+func (v Value) TryVector() (*PersistentVector, error) {
+	if v.Type() == VAL_ARRAY {
+		return v.AsVector(), nil
+	} else {
+		return nil, fmt.Errorf("value: %s::%s is not an array", v.val, v.TypeString())
+	}
+}
+
+func (v Value) TryHashMap() (*PersistentHashMap, error) {
 	t := v.Type()
 	if t == VAL_HASHMAP {
 		return v.AsHashMap(), nil
@@ -347,6 +494,14 @@ func (v Value) TryHashMap() (SmackMap, error) {
 	}
 }
 
+func (v Value) TrySet() (*PersistentSet, error) {
+	if v.Type() == VAL_SET {
+		return v.AsSet(), nil
+	} else {
+		return nil, fmt.Errorf("value: %s::%s is not a set", v.val, v.TypeString())
+	}
+}
+
 func (v Value) TrySymbol() (Symbol, error) {
 	if v.Type() == VAL_SYMBOL {
 		return v.AsSymbol(), nil
@@ -363,6 +518,38 @@ func (v Value) TryFn() (*SmackFn, error) {
 	}
 }
 
+func (v Value) TryRef() (*SmackRef, error) {
+	if v.Type() == VAL_REF {
+		return v.AsRef(), nil
+	} else {
+		return nil, fmt.Errorf("value: %s::%s is not a ref", v.val, v.TypeString())
+	}
+}
+
+func (v Value) TryProtocol() (*Protocol, error) {
+	if v.Type() == VAL_PROTOCOL {
+		return v.AsProtocol(), nil
+	} else {
+		return nil, fmt.Errorf("value: %s::%s is not a protocol", v.val, v.TypeString())
+	}
+}
+
+func (v Value) TryRecord() (*Record, error) {
+	if v.Type() == VAL_RECORD {
+		return v.AsRecord(), nil
+	} else {
+		return nil, fmt.Errorf("value: %s::%s is not a record", v.val, v.TypeString())
+	}
+}
+
+func (v Value) TryObject() (*GoObject, error) {
+	if v.Type() == VAL_OBJECT {
+		return v.AsObject(), nil
+	} else {
+		return nil, fmt.Errorf("value: %s::%s is not a Go object", v.val, v.TypeString())
+	}
+}
+
 func (v Value) TryChan() (chan Value, error) {
 	if v.Type() == VAL_CHANNEL {
 		return v.AsChan(), nil
@@ -371,57 +558,46 @@ func (v Value) TryChan() (chan Value, error) {
 	}
 }
 
+// Number of elements in a list, array, set or hashmap. -1 for any other type.
+func (v Value) Len() int {
+	switch l := v.val.(type) {
+	case []Value:
+		if v.IsHashMap() {
+			// unevaluated hashmap literal, stored as key value pairs
+			return len(l) / 2
+		}
+		return len(l)
+	case *PersistentVector:
+		return l.Len()
+	case *PersistentHashMap:
+		return l.Len()
+	case *PersistentSet:
+		return l.Len()
+	case *Record:
+		return l.Len()
+	default:
+		return -1
+	}
+}
+
 func (v Value) Type() uint32 {
 	return v.ty
 }
 
+// Records report the name of their record type, Go objects their Go type
 func (v Value) TypeString() string {
+	if v.ty == VAL_RECORD {
+		return v.AsRecord().ty.name
+	}
+	if v.ty == VAL_OBJECT {
+		return v.AsObject().Type().String()
+	}
 	return TypeString(v.ty)
 }
 
+// Display representation, see PrStr for the readable one
 func (v Value) String() string {
-	switch v.Type() {
-	case VAL_NUMBER:
-		return fmt.Sprintf("%f", v.AsNumber())
-	case VAL_STRING:
-		return v.AsString()
-	case VAL_BOOLEAN:
-		return fmt.Sprintf("%t", v.AsBool())
-	case VAL_ARRAY:
-		fallthrough
-	case VAL_LIST:
-		list := v.AsList()
-		sb := strings.Builder{}
-		sb.WriteRune('(')
-
-		for i, v := range list {
-			sb.WriteString(v.String())
-
-			// only append a space if we are not the last element
-			if i != len(list)-1 {
-				sb.WriteRune(' ')
-			}
-		}
-		sb.WriteRune(')')
-		return sb.String()
-
-	case VAL_HASHMAP:
-		return fmt.Sprintf("%#v", v.AsHashMap())
-	case VAL_SYMBOL:
-		return fmt.Sprintf(":%s", v.AsSymbol())
-	case VAL_FN:
-		return v.AsFn().String()
-	case VAL_ATOM:
-		return v.AsAtom().Name()
-	case VAL_ERROR:
-		return fmt.Sprintf("%s", v.AsError())
-	case VAL_NONE:
-		return "NONE"
-	case VAL_CHANNEL:
-		return fmt.Sprintf("%#v", v.AsChan())
-	default:
-		return "Unknown/Incorrect Internal Type"
-	}
+	return PrStr(v, false)
 }
 
 func TypeString(ty uint32) string {
@@ -440,6 +616,12 @@ func TypeString(ty uint32) string {
 		return "HashMap"
 	case VAL_SYMBOL:
 		return "Symbol"
+	case VAL_ATOM:
+		return "Keyword"
+	case VAL_REF:
+		return "Ref"
+	case VAL_SET:
+		return "Set"
 	case VAL_FN:
 		return "Function"
 	case VAL_ERROR:
@@ -448,6 +630,16 @@ func TypeString(ty uint32) string {
 		return "None"
 	case VAL_CHANNEL:
 		return "Channel"
+	case VAL_PROTOCOL:
+		return "Protocol"
+	case VAL_RECORD:
+		return "Record"
+	case VAL_OPTION:
+		return "Option"
+	case VAL_RESULT:
+		return "Result"
+	case VAL_OBJECT:
+		return "GoObject"
 	default:
 		return "Unknown/Incorrect Internal Type"
 	}
@@ -0,0 +1,222 @@
+package interp
+
+// PersistentVector is an immutable 32-way trie with a tail buffer (as described
+// by Bagwell and used by Clojure). Updates copy only the path from the root to the
+// changed leaf, so older versions stay valid and share structure with newer ones.
+// This makes vectors safe to hand to other goroutines.
+
+const (
+	vec_bits  = 5
+	vec_width = 1 << vec_bits
+	vec_mask  = vec_width - 1
+)
+
+type vec_node struct {
+	children [vec_width]*vec_node
+	values   []Value
+}
+
+type PersistentVector struct {
+	count int
+	shift uint
+	root  *vec_node
+	tail  []Value
+}
+
+// DONT MUTATE ME!!!!!
+var empty_vec_node = &vec_node{}
+var empty_vector = &PersistentVector{0, vec_bits, empty_vec_node, []Value{}}
+
+func EmptyVector() *PersistentVector {
+	return empty_vector
+}
+
+func NewPersistentVector(vals []Value) *PersistentVector {
+	vec := empty_vector
+	for _, v := range vals {
+		vec = vec.Conj(v)
+	}
+	return vec
+}
+
+func (self *PersistentVector) Len() int {
+	return self.count
+}
+
+func (self *PersistentVector) tailoff() int {
+	if self.count < vec_width {
+		return 0
+	}
+	return ((self.count - 1) >> vec_bits) << vec_bits
+}
+
+// Returns the leaf array holding index i. i MUST be in range
+func (self *PersistentVector) array_for(i int) []Value {
+	if i >= self.tailoff() {
+		return self.tail
+	}
+	node := self.root
+	for level := self.shift; level > 0; level -= vec_bits {
+		node = node.children[(i>>level)&vec_mask]
+	}
+	return node.values
+}
+
+func (self *PersistentVector) Nth(i int) (Value, bool) {
+	if i < 0 || i >= self.count {
+		return NoValue(), false
+	}
+	return self.array_for(i)[i&vec_mask], true
+}
+
+func (self *PersistentVector) Conj(val Value) *PersistentVector {
+	// Room left in the tail, copy it and append
+	if self.count-self.tailoff() < vec_width {
+		tail := make([]Value, len(self.tail), len(self.tail)+1)
+		copy(tail, self.tail)
+		tail = append(tail, val)
+		return &PersistentVector{self.count + 1, self.shift, self.root, tail}
+	}
+
+	// Tail is full, push it into the tree
+	tail_node := &vec_node{values: self.tail}
+	shift := self.shift
+	var root *vec_node
+
+	if (self.count >> vec_bits) > (1 << self.shift) {
+		// Root overflow, tree grows by one level
+		root = &vec_node{}
+		root.children[0] = self.root
+		root.children[1] = new_vec_path(self.shift, tail_node)
+		shift += vec_bits
+	} else {
+		root = self.push_tail(self.shift, self.root, tail_node)
+	}
+
+	return &PersistentVector{self.count + 1, shift, root, []Value{val}}
+}
+
+func (self *PersistentVector) push_tail(level uint, parent *vec_node, tail_node *vec_node) *vec_node {
+	sub_idx := ((self.count - 1) >> level) & vec_mask
+	ret := &vec_node{children: parent.children}
+
+	var insert *vec_node
+	if level == vec_bits {
+		insert = tail_node
+	} else if child := parent.children[sub_idx]; child != nil {
+		insert = self.push_tail(level-vec_bits, child, tail_node)
+	} else {
+		insert = new_vec_path(level-vec_bits, tail_node)
+	}
+	ret.children[sub_idx] = insert
+	return ret
+}
+
+func new_vec_path(level uint, node *vec_node) *vec_node {
+	if level == 0 {
+		return node
+	}
+	ret := &vec_node{}
+	ret.children[0] = new_vec_path(level-vec_bits, node)
+	return ret
+}
+
+// Returns a new vector with index i set to val. i == Len() appends.
+func (self *PersistentVector) Assoc(i int, val Value) (*PersistentVector, bool) {
+	if i < 0 || i > self.count {
+		return self, false
+	}
+	if i == self.count {
+		return self.Conj(val), true
+	}
+
+	if i >= self.tailoff() {
+		tail := make([]Value, len(self.tail))
+		copy(tail, self.tail)
+		tail[i&vec_mask] = val
+		return &PersistentVector{self.count, self.shift, self.root, tail}, true
+	}
+	root := vec_do_assoc(self.shift, self.root, i, val)
+	return &PersistentVector{self.count, self.shift, root, self.tail}, true
+}
+
+func vec_do_assoc(level uint, node *vec_node, i int, val Value) *vec_node {
+	if level == 0 {
+		values := make([]Value, len(node.values))
+		copy(values, node.values)
+		values[i&vec_mask] = val
+		return &vec_node{values: values}
+	}
+	ret := &vec_node{children: node.children}
+	sub_idx := (i >> level) & vec_mask
+	ret.children[sub_idx] = vec_do_assoc(level-vec_bits, node.children[sub_idx], i, val)
+	return ret
+}
+
+// Returns a new vector without the last element.
+func (self *PersistentVector) Pop() (*PersistentVector, bool) {
+	switch self.count {
+	case 0:
+		return self, false
+	case 1:
+		return empty_vector, true
+	}
+
+	if self.count-self.tailoff() > 1 {
+		tail := make([]Value, len(self.tail)-1)
+		copy(tail, self.tail)
+		return &PersistentVector{self.count - 1, self.shift, self.root, tail}, true
+	}
+
+	tail := self.array_for(self.count - 2)
+	root := self.pop_tail(self.shift, self.root)
+	shift := self.shift
+	if root == nil {
+		root = empty_vec_node
+	}
+	if shift > vec_bits && root.children[1] == nil {
+		root = root.children[0]
+		shift -= vec_bits
+	}
+	return &PersistentVector{self.count - 1, shift, root, tail}, true
+}
+
+func (self *PersistentVector) pop_tail(level uint, node *vec_node) *vec_node {
+	sub_idx := ((self.count - 2) >> level) & vec_mask
+	if level > vec_bits {
+		child := self.pop_tail(level-vec_bits, node.children[sub_idx])
+		if child == nil && sub_idx == 0 {
+			return nil
+		}
+		ret := &vec_node{children: node.children}
+		ret.children[sub_idx] = child
+		return ret
+	} else if sub_idx == 0 {
+		return nil
+	}
+	ret := &vec_node{children: node.children}
+	ret.children[sub_idx] = nil
+	return ret
+}
+
+// Calls fn for each element in order, stopping early if fn returns false
+func (self *PersistentVector) Each(fn func(i int, v Value) bool) {
+	for i := 0; i < self.count; i += vec_width {
+		leaf := self.array_for(i)
+		for j, v := range leaf {
+			if !fn(i+j, v) {
+				return
+			}
+		}
+	}
+}
+
+// Copies the vector out into a new slice
+func (self *PersistentVector) Slice() []Value {
+	res := make([]Value, 0, self.count)
+	self.Each(func(_ int, v Value) bool {
+		res = append(res, v)
+		return true
+	})
+	return res
+}
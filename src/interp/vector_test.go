@@ -0,0 +1,99 @@
+package interp
+
+import "testing"
+
+// Sizes around the tail, the first level of the trie and the root growing a level
+var vector_sizes = []int{0, 1, 31, 32, 33, 64, 65, 1056, 1057, 1088, 1089, 2000}
+
+func numbers(n int) *PersistentVector {
+	vec := EmptyVector()
+	for i := 0; i < n; i++ {
+		vec = vec.Conj(NewNumber(float64(i)))
+	}
+	return vec
+}
+
+func check_numbers(t *testing.T, vec *PersistentVector, n int) {
+	t.Helper()
+	if vec.Len() != n {
+		t.Fatalf("Len() = %d, expected %d", vec.Len(), n)
+	}
+	for i := 0; i < n; i++ {
+		if v, ok := vec.Nth(i); !ok || v.AsNumber() != float64(i) {
+			t.Fatalf("Nth(%d) = %s, %t with %d elements", i, PrStr(v, true), ok, n)
+		}
+	}
+	if _, ok := vec.Nth(n); ok {
+		t.Fatalf("Nth(%d) found an element past the end", n)
+	}
+	if _, ok := vec.Nth(-1); ok {
+		t.Fatalf("Nth(-1) found an element")
+	}
+}
+
+func TestVectorConj(t *testing.T) {
+	for _, n := range vector_sizes {
+		check_numbers(t, numbers(n), n)
+	}
+}
+
+func TestVectorPop(t *testing.T) {
+	for _, n := range vector_sizes {
+		vec := numbers(n)
+		for i := n; i > 0; i-- {
+			popped, ok := vec.Pop()
+			if !ok {
+				t.Fatalf("Pop() failed with %d elements", i)
+			}
+			check_numbers(t, popped, i-1)
+			// popping leaves the original as it was
+			if vec.Len() != i {
+				t.Fatalf("Pop() changed the length of the original to %d", vec.Len())
+			}
+			vec = popped
+		}
+		if _, ok := vec.Pop(); ok {
+			t.Fatalf("Pop() succeeded on an empty vector")
+		}
+	}
+}
+
+// Pops across the tail boundary and grows again, so the trie is rebuilt from popped nodes
+func TestVectorPopConj(t *testing.T) {
+	vec := numbers(1089)
+	for i := 0; i < 100; i++ {
+		vec, _ = vec.Pop()
+	}
+	for i := 989; i < 1200; i++ {
+		vec = vec.Conj(NewNumber(float64(i)))
+	}
+	check_numbers(t, vec, 1200)
+}
+
+func TestVectorAssoc(t *testing.T) {
+	for _, n := range vector_sizes {
+		vec := numbers(n)
+		updated := vec
+		for i := 0; i < n; i++ {
+			var ok bool
+			if updated, ok = updated.Assoc(i, NewNumber(float64(-i))); !ok {
+				t.Fatalf("Assoc(%d) failed with %d elements", i, n)
+			}
+		}
+		check_numbers(t, vec, n)
+		for i := 0; i < n; i++ {
+			if v, _ := updated.Nth(i); v.AsNumber() != float64(-i) {
+				t.Fatalf("Nth(%d) = %s after Assoc", i, PrStr(v, true))
+			}
+		}
+		// assoc at the end appends
+		if appended, ok := vec.Assoc(n, NewNumber(float64(n))); !ok {
+			t.Fatalf("Assoc(%d) did not append", n)
+		} else {
+			check_numbers(t, appended, n+1)
+		}
+		if _, ok := vec.Assoc(n+1, NewNilList()); ok {
+			t.Fatalf("Assoc(%d) succeeded past the end", n+1)
+		}
+	}
+}
@@ -1,23 +1,34 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
+	_ "github.com/MatthewMcDade13/smack/src/gostd"
 	"github.com/MatthewMcDade13/smack/src/interp"
+	"github.com/MatthewMcDade13/smack/src/transpile"
 )
 
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(check(os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "build" {
+		os.Exit(build(os.Args[2:]))
+	}
+
 	if len(os.Args) > 1 {
 		input_file := os.Args[1]
 
-		if bytes, err := os.ReadFile(input_file); err == nil {
-			script := string(bytes)
-			env := interp.NewCoreEnv()
-			interp.Rep(script, env)
+		env := interp.NewCoreEnv()
+		if _, err := interp.LoadFile(input_file, env); err == nil {
 			os.Exit(0)
-
 		} else {
 			log.Fatal(err)
 		}
@@ -28,3 +39,48 @@ func main() {
 	}
 
 }
+
+// smack check files..., exits with 1 when any problem is found
+func check(files []string) int {
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: smack check file...")
+		return 2
+	}
+	status := 0
+	for _, file := range files {
+		diags, err := interp.CheckFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+			status = 1
+			continue
+		}
+		for _, d := range diags {
+			fmt.Println(d)
+		}
+		if len(diags) > 0 {
+			status = 1
+		}
+	}
+	return status
+}
+
+// smack build [-o output] [-src dir] file
+func build(args []string) int {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	output := flags.String("o", "", "binary to write, the script name without its extension by default")
+	src_dir := flags.String("src", "", "directory to keep the generated Go program in")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: smack build [-o output] [-src dir] file")
+		return 2
+	}
+	script := flags.Arg(0)
+	if *output == "" {
+		*output = strings.TrimSuffix(filepath.Base(script), filepath.Ext(script))
+	}
+	if err := transpile.Build(script, *output, *src_dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
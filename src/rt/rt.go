@@ -0,0 +1,151 @@
+// Package rt is the runtime of Smack programs transpiled to Go by smack build.
+//
+// Values, environments and the core fns are those of the interpreter, so compiled
+// code and the forms it hands to Eval (macros, match, protocols...) see the same
+// namespaces and behave the same as a script run with interp.LoadFile, including
+// the Go standard library bindings of gostd.
+package rt
+
+import (
+	_ "github.com/MatthewMcDade13/smack/src/gostd"
+	"github.com/MatthewMcDade13/smack/src/interp"
+)
+
+type Value = interp.Value
+type Env = interp.Env
+type Form = interp.CompiledForm
+
+func NewCoreEnv() *Env {
+	return interp.NewCoreEnv()
+}
+
+// A new scope inside outer, as let creates
+func NewEnv(outer *Env) *Env {
+	return interp.NewEnv(outer, nil, nil)
+}
+
+// Runs the compiled forms of the script at path, see interp.RunCompiled
+func Run(path string, env *Env, forms []Form) (Value, error) {
+	return interp.RunCompiled(path, env, forms)
+}
+
+func Eval(form Value, env *Env) (Value, error) {
+	return interp.Eval(form, env)
+}
+
+func Lookup(env *Env, name string) (Value, error) {
+	return env.Get(name)
+}
+
+// Calls f with the dynamic bindings in effect in env
+func Call(env *Env, f Value, args ...Value) (Value, error) {
+	return interp.CallIn(env, f, args)
+}
+
+// Calls f from the tail position of a compiled fn body, see interp.TailCall
+func TailCall(env *Env, f Value, args ...Value) (Value, error) {
+	return interp.TailCall(env, f, args)
+}
+
+func IsMacro(v Value) bool {
+	return interp.IsMacro(v)
+}
+
+func Define(env *Env, sym Value, doc Value, value Value) Value {
+	return interp.Define(env, sym, doc, value)
+}
+
+// The fn of the form (fn "doc"? params body) with its body compiled to body
+func Fn(env *Env, form Value, body interp.NativeBody) (Value, error) {
+	return interp.NewCompiledFn(form.AsList()[1:], env, form.Meta(), body)
+}
+
+func Truthy(v Value) bool {
+	return v.IsTruthy()
+}
+
+// Forms as the reader produces them
+
+func Num(n float64) Value {
+	return interp.NewNumber(n)
+}
+
+func Str(s string) Value {
+	return interp.NewString(s)
+}
+
+func Bool(b bool) Value {
+	return interp.NewBool(b)
+}
+
+// name includes the leading :
+func Keyword(name string) Value {
+	return interp.NewAtom(name)
+}
+
+func Sym(name string) Value {
+	return interp.NewSymbol(interp.Symbol(name))
+}
+
+func Nil() Value {
+	return interp.NewNilList()
+}
+
+func None() Value {
+	return interp.NoValue()
+}
+
+func List(forms ...Value) Value {
+	return interp.NewList(forms)
+}
+
+func ArrayForm(forms ...Value) Value {
+	return interp.NewValue(interp.VAL_ARRAY, forms)
+}
+
+func MapForm(forms ...Value) Value {
+	return interp.NewValue(interp.VAL_HASHMAP, forms)
+}
+
+func SetForm(forms ...Value) Value {
+	return interp.NewValue(interp.VAL_SET, forms)
+}
+
+// form with the metadata map of alternating keys and values kvs
+func WithMeta(form Value, kvs ...Value) Value {
+	meta := interp.EmptyHashMap()
+	for i := 1; i < len(kvs); i = i + 2 {
+		meta = meta.Assoc(kvs[i-1], kvs[i])
+	}
+	res, _ := form.WithMeta(meta)
+	return res
+}
+
+// Evaluated literals, carrying the metadata of the literal form like Eval does
+
+func Vector(form Value, vals ...Value) Value {
+	vec := interp.EmptyVector()
+	for _, v := range vals {
+		vec = vec.Conj(v)
+	}
+	res, _ := interp.NewVector(vec).WithMeta(form.Meta())
+	return res
+}
+
+func HashMap(form Value, kvs ...Value) Value {
+	m := interp.EmptyHashMap()
+	for i := 1; i < len(kvs); i = i + 2 {
+		m = m.Assoc(kvs[i-1], kvs[i])
+	}
+	res, _ := interp.NewHashMap(m).WithMeta(form.Meta())
+	return res
+}
+
+func Set(form Value, vals ...Value) Value {
+	set := interp.EmptySet()
+	for _, v := range vals {
+		set = set.Conj(v)
+	}
+	res, _ := interp.NewSet(set).WithMeta(form.Meta())
+	return res
+}
@@ -0,0 +1,102 @@
+package transpile
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const smack_module = "github.com/MatthewMcDade13/smack"
+
+// Transpiles the script at path and builds it into the binary output. The Go
+// program is written to src_dir, or a temporary directory removed afterwards when
+// src_dir is empty. It is built against the smack sources found in SMACK_ROOT, or
+// the module enclosing the working directory.
+func Build(path string, output string, src_dir string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	output, err = filepath.Abs(output)
+	if err != nil {
+		return err
+	}
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	code, err := Transpile(string(source), path)
+	if err != nil {
+		return err
+	}
+	root, err := smack_root()
+	if err != nil {
+		return err
+	}
+
+	if src_dir == "" {
+		if src_dir, err = os.MkdirTemp("", "smack-build-"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(src_dir)
+	} else if err := os.MkdirAll(src_dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(src_dir, "main.go"), code, 0644); err != nil {
+		return err
+	}
+	mod := fmt.Sprintf("module smack_build\n\ngo 1.21\n\nrequire %s v0.0.0\n\nreplace %s => %s\n", smack_module, smack_module, root)
+	if err := os.WriteFile(filepath.Join(src_dir, "go.mod"), []byte(mod), 0644); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("go", "build", "-o", output, ".")
+	cmd.Dir = src_dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("build => go build failed: %s", err)
+	}
+	return nil
+}
+
+// Directory of the smack module sources
+func smack_root() (string, error) {
+	if root := os.Getenv("SMACK_ROOT"); root != "" {
+		return filepath.Abs(root)
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if module_name(filepath.Join(dir, "go.mod")) == smack_module {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("build => Smack sources not found, set SMACK_ROOT to the directory of %s", smack_module)
+		}
+		dir = parent
+	}
+}
+
+// The module declared by the go.mod at path, empty if there is none
+func module_name(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "module ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "module "))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package transpile
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/MatthewMcDade13/smack/src/interp"
+)
+
+// Every script in scripts/ must print the same built as interpreted
+func TestBuildMatchesInterpreter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a Go program for every script")
+	}
+	scripts, err := filepath.Glob("../../scripts/*.smk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) == 0 {
+		t.Fatal("no scripts found")
+	}
+	for _, script := range scripts {
+		t.Run(filepath.Base(script), func(t *testing.T) {
+			var want bytes.Buffer
+			it, err := interp.NewInterpreter(interp.WithStdout(&want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := it.LoadFile(script); err != nil {
+				t.Fatalf("LoadFile => %s", err)
+			}
+
+			binary := filepath.Join(t.TempDir(), "script")
+			if err := Build(script, binary, ""); err != nil {
+				t.Fatalf("Build => %s", err)
+			}
+			got, err := exec.Command(binary).Output()
+			if err != nil {
+				t.Fatalf("running the built script => %s", err)
+			}
+			if string(got) != want.String() {
+				t.Errorf("built script printed:\n%s\ninterpreted:\n%s", got, want.String())
+			}
+		})
+	}
+}
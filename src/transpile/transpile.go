@@ -0,0 +1,453 @@
+// Package transpile compiles Smack scripts to Go programs built on the rt package.
+//
+// Literals, symbols, calls, def, let, do, if, fn and quot are compiled to Go. Every
+// other form (macros and their calls, match, ns, protocols, records...) is kept as
+// data and evaluated by the interpreter when it is reached, so a compiled program
+// behaves like the script run with the interpreter.
+package transpile
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/MatthewMcDade13/smack/src/interp"
+)
+
+const rt_import = "github.com/MatthewMcDade13/smack/src/rt"
+
+type generator struct {
+	// package level vars holding forms as data
+	data []string
+	// one func per top level form
+	funcs []string
+	// current func body
+	body *strings.Builder
+	tmp  int
+	// data uses math.Inf or math.NaN
+	uses_math bool
+}
+
+// Transpiles source, the script at path, into the main.go of a Go program. path is
+// where the program resolves requires and load-file from, like the script would
+func Transpile(source string, path string) ([]byte, error) {
+	forms, err := interp.ReadAll(source)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	g := &generator{}
+	names := make([]string, 0, len(forms))
+	for i, form := range forms {
+		name := fmt.Sprintf("form%d", i)
+		g.body = &strings.Builder{}
+		res, err := g.emit(form, "env", false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		g.funcs = append(g.funcs, fmt.Sprintf("// %s\nfunc %s(env *rt.Env) (rt.Value, error) {\n%sreturn %s, nil\n}\n", summary(form), name, g.body.String(), res))
+		names = append(names, name)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by smack build from %s. DO NOT EDIT.\n\npackage main\n\n", path)
+	out.WriteString("import (\n\t\"log\"\n")
+	if g.uses_math {
+		out.WriteString("\t\"math\"\n")
+	}
+	fmt.Fprintf(&out, "\n\t%q\n)\n\n", rt_import)
+	fmt.Fprintf(&out, "const script = %s\n\n", strconv.Quote(path))
+	out.WriteString("func main() {\n\tenv := rt.NewCoreEnv()\n\tif _, err := rt.Run(script, env, forms); err != nil {\n\t\tlog.Fatal(err)\n\t}\n}\n\n")
+	fmt.Fprintf(&out, "var forms = []rt.Form{%s}\n\n", strings.Join(names, ", "))
+	for _, f := range g.funcs {
+		out.WriteString(f)
+		out.WriteString("\n")
+	}
+	if len(g.data) > 0 {
+		out.WriteString("var (\n")
+		for _, d := range g.data {
+			out.WriteString(d)
+			out.WriteString("\n")
+		}
+		out.WriteString(")\n")
+	}
+	return format.Source(out.Bytes())
+}
+
+// The start of form printed, for the comment above its func
+func summary(form interp.Value) string {
+	s := strings.ReplaceAll(interp.PrStr(form, true), "\n", " ")
+	if len(s) > 60 {
+		s = s[:57] + "..."
+	}
+	return s
+}
+
+func (g *generator) next() string {
+	g.tmp++
+	return fmt.Sprintf("v%d", g.tmp)
+}
+
+func (g *generator) line(format string, args ...interface{}) {
+	fmt.Fprintf(g.body, format, args...)
+	g.body.WriteString("\n")
+}
+
+// Emits `name, err := expr` with the error returned
+func (g *generator) assign(expr string) string {
+	name := g.next()
+	g.line("%s, err := %s", name, expr)
+	g.line("if err != nil {\nreturn rt.None(), err\n}")
+	return name
+}
+
+// Hoists form as data into a package level var, returning its name
+func (g *generator) hoist(form interp.Value) (string, error) {
+	expr, err := g.data_expr(form)
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("d%d", len(g.data))
+	g.data = append(g.data, fmt.Sprintf("%s = %s", name, expr))
+	return name, nil
+}
+
+// Go expression building form as the reader produced it
+func (g *generator) data_expr(form interp.Value) (string, error) {
+	var expr string
+	switch form.Type() {
+	case interp.VAL_NUMBER:
+		expr = fmt.Sprintf("rt.Num(%s)", g.number(form.AsNumber()))
+	case interp.VAL_STRING:
+		expr = fmt.Sprintf("rt.Str(%s)", strconv.Quote(form.AsString()))
+	case interp.VAL_BOOLEAN:
+		expr = fmt.Sprintf("rt.Bool(%t)", form.AsBool())
+	case interp.VAL_ATOM:
+		expr = fmt.Sprintf("rt.Keyword(%s)", strconv.Quote(form.AsAtom().Name()))
+	case interp.VAL_SYMBOL:
+		expr = fmt.Sprintf("rt.Sym(%s)", strconv.Quote(form.AsSymbol().Name()))
+	case interp.VAL_LIST, interp.VAL_ARRAY, interp.VAL_HASHMAP, interp.VAL_SET:
+		ctor := map[uint32]string{
+			interp.VAL_LIST:    "rt.List",
+			interp.VAL_ARRAY:   "rt.ArrayForm",
+			interp.VAL_HASHMAP: "rt.MapForm",
+			interp.VAL_SET:     "rt.SetForm",
+		}[form.Type()]
+		list := form.AsList()
+		if form.IsList() && len(list) == 0 {
+			expr = "rt.Nil()"
+			break
+		}
+		elems := make([]string, len(list))
+		for i, f := range list {
+			e, err := g.data_expr(f)
+			if err != nil {
+				return "", err
+			}
+			elems[i] = e
+		}
+		expr = fmt.Sprintf("%s(%s)", ctor, strings.Join(elems, ", "))
+	default:
+		return "", fmt.Errorf("transpile => Can not compile a %s form", form.TypeString())
+	}
+
+	if meta := form.Meta(); meta != nil {
+		kvs := make([]string, 0)
+		var err error
+		meta.Each(func(key interp.Value, val interp.Value) bool {
+			var k, v string
+			if k, err = g.data_expr(key); err != nil {
+				return false
+			}
+			if v, err = g.data_expr(val); err != nil {
+				return false
+			}
+			kvs = append(kvs, k, v)
+			return true
+		})
+		if err != nil {
+			return "", err
+		}
+		expr = fmt.Sprintf("rt.WithMeta(%s, %s)", expr, strings.Join(kvs, ", "))
+	}
+	return expr, nil
+}
+
+func (g *generator) number(n float64) string {
+	switch {
+	case math.IsInf(n, 1):
+		g.uses_math = true
+		return "math.Inf(1)"
+	case math.IsInf(n, -1):
+		g.uses_math = true
+		return "math.Inf(-1)"
+	case math.IsNaN(n):
+		g.uses_math = true
+		return "math.NaN()"
+	default:
+		return strconv.FormatFloat(n, 'g', -1, 64)
+	}
+}
+
+// Emits the statements evaluating form in the env named env, returning the
+// name of the Go variable holding its value
+// Emits the Go code evaluating form in env. tail is set for forms in the tail
+// position of a fn body, where calls are made by the caller of the body, see
+// emit_call
+func (g *generator) emit(form interp.Value, env string, tail bool) (string, error) {
+	switch form.Type() {
+	case interp.VAL_NUMBER, interp.VAL_STRING, interp.VAL_BOOLEAN, interp.VAL_ATOM:
+		return g.hoist(form)
+	case interp.VAL_SYMBOL:
+		return g.assign(fmt.Sprintf("rt.Lookup(%s, %s)", env, strconv.Quote(form.AsSymbol().Name()))), nil
+	case interp.VAL_ARRAY, interp.VAL_HASHMAP, interp.VAL_SET:
+		return g.emit_collection(form, env)
+	case interp.VAL_LIST:
+		return g.emit_list(form, env, tail)
+	default:
+		return "", fmt.Errorf("transpile => Can not compile a %s form", form.TypeString())
+	}
+}
+
+// Literals are evaluated element by element into a collection with the literal's metadata
+func (g *generator) emit_collection(form interp.Value, env string) (string, error) {
+	list := form.AsList()
+	if form.IsHashMap() && len(list)%2 != 0 {
+		// the key without a value is never evaluated
+		list = list[:len(list)-1]
+	}
+	vals := make([]string, len(list))
+	for i, f := range list {
+		v, err := g.emit(f, env, false)
+		if err != nil {
+			return "", err
+		}
+		vals[i] = v
+	}
+	meta := "rt.None()"
+	if form.Meta() != nil {
+		d, err := g.hoist(form)
+		if err != nil {
+			return "", err
+		}
+		meta = d
+	}
+	ctor := map[uint32]string{
+		interp.VAL_ARRAY:   "rt.Vector",
+		interp.VAL_HASHMAP: "rt.HashMap",
+		interp.VAL_SET:     "rt.Set",
+	}[form.Type()]
+	name := g.next()
+	g.line("%s := %s(%s)", name, ctor, strings.Join(append([]string{meta}, vals...), ", "))
+	return name, nil
+}
+
+// Evaluates form with the interpreter
+func (g *generator) emit_eval(form interp.Value, env string) (string, error) {
+	d, err := g.hoist(form)
+	if err != nil {
+		return "", err
+	}
+	return g.assign(fmt.Sprintf("rt.Eval(%s, %s)", d, env)), nil
+}
+
+func (g *generator) emit_list(form interp.Value, env string, tail bool) (string, error) {
+	list := form.AsList()
+	if len(list) == 0 {
+		return g.hoist(form)
+	}
+	if list[0].IsSymbol() {
+		switch list[0].AsSymbol().Name() {
+		case "def":
+			if len(list) >= 3 && list[1].IsSymbol() {
+				return g.emit_def(list, env)
+			}
+		case "let":
+			if len(list) >= 3 && list[1].IsListLike() && all_symbols(list[1].AsList(), 2) {
+				return g.emit_let(list, env, tail)
+			}
+		case "do":
+			if len(list) >= 2 {
+				return g.emit_do(list[1:], env, tail)
+			}
+		case "if":
+			if len(list) >= 3 {
+				return g.emit_if(list, env, tail)
+			}
+		case "fn":
+			if fn_shape(list[1:]) {
+				return g.emit_fn(form, env)
+			}
+		case "quot":
+			if len(list) >= 2 {
+				return g.hoist(list[1])
+			}
+		default:
+			// every other special form is left to the interpreter
+			if interp.IsSpecialForm(list[0].AsSymbol().Name()) {
+				return g.emit_eval(form, env)
+			}
+			return g.emit_call(form, env, tail)
+		}
+		// malformed special forms fail the way the interpreter fails them
+		return g.emit_eval(form, env)
+	}
+	return g.emit_call(form, env, tail)
+}
+
+// Every step-th element of forms is a symbol
+func all_symbols(forms []interp.Value, step int) bool {
+	for i := 0; i < len(forms); i = i + step {
+		if !forms[i].IsSymbol() {
+			return false
+		}
+	}
+	return true
+}
+
+// forms following fn are "doc"? params body
+func fn_shape(forms []interp.Value) bool {
+	if len(forms) > 2 && forms[0].IsString() {
+		forms = forms[1:]
+	}
+	return len(forms) >= 2 && forms[0].IsListLike()
+}
+
+func (g *generator) emit_def(list []interp.Value, env string) (string, error) {
+	val, err := g.emit(list[len(list)-1], env, false)
+	if err != nil {
+		return "", err
+	}
+	sym, err := g.hoist(list[1])
+	if err != nil {
+		return "", err
+	}
+	doc := "rt.None()"
+	if len(list) > 3 && list[2].IsString() {
+		if doc, err = g.hoist(list[2]); err != nil {
+			return "", err
+		}
+	}
+	name := g.next()
+	g.line("%s := rt.Define(%s, %s, %s, %s)", name, env, sym, doc, val)
+	return name, nil
+}
+
+func (g *generator) emit_let(list []interp.Value, env string, tail bool) (string, error) {
+	let_env := g.next()
+	g.line("%s := rt.NewEnv(%s)", let_env, env)
+	bindings := list[1].AsList()
+	for i := 1; i < len(bindings); i = i + 2 {
+		val, err := g.emit(bindings[i], let_env, false)
+		if err != nil {
+			return "", err
+		}
+		g.line("%s.Set(%s, %s)", let_env, strconv.Quote(bindings[i-1].AsSymbol().Name()), val)
+	}
+	return g.emit(list[2], let_env, tail)
+}
+
+func (g *generator) emit_do(forms []interp.Value, env string, tail bool) (string, error) {
+	for _, f := range forms[:len(forms)-1] {
+		v, err := g.emit(f, env, false)
+		if err != nil {
+			return "", err
+		}
+		g.line("_ = %s", v)
+	}
+	return g.emit(forms[len(forms)-1], env, tail)
+}
+
+func (g *generator) emit_if(list []interp.Value, env string, tail bool) (string, error) {
+	cond, err := g.emit(list[1], env, false)
+	if err != nil {
+		return "", err
+	}
+	name := g.next()
+	g.line("var %s rt.Value", name)
+	g.line("if rt.Truthy(%s) {", cond)
+	then, err := g.emit(list[2], env, tail)
+	if err != nil {
+		return "", err
+	}
+	g.line("%s = %s", name, then)
+	g.line("} else {")
+	if len(list) > 3 {
+		otherwise, err := g.emit(list[3], env, tail)
+		if err != nil {
+			return "", err
+		}
+		g.line("%s = %s", name, otherwise)
+	} else {
+		g.line("%s = rt.Nil()", name)
+	}
+	g.line("}")
+	return name, nil
+}
+
+func (g *generator) emit_fn(form interp.Value, env string) (string, error) {
+	d, err := g.hoist(form)
+	if err != nil {
+		return "", err
+	}
+	forms := form.AsList()[1:]
+	if len(forms) > 2 && forms[0].IsString() {
+		forms = forms[1:]
+	}
+
+	outer := g.body
+	g.body = &strings.Builder{}
+	res, err := g.emit(forms[1], "env", true)
+	if err != nil {
+		return "", err
+	}
+	body := g.body.String()
+	g.body = outer
+
+	name := g.next()
+	g.line("%s, err := rt.Fn(%s, %s, func(env *rt.Env) (rt.Value, error) {\n%sreturn %s, nil\n})", name, env, d, body, res)
+	g.line("if err != nil {\nreturn rt.None(), err\n}")
+	return name, nil
+}
+
+// (f args...), calls of macros are left to the interpreter as their expansion is
+// only known at run time. A call in tail position is handed back to the caller of
+// the fn body with rt.TailCall, so tail recursion does not grow the Go stack
+func (g *generator) emit_call(form interp.Value, env string, tail bool) (string, error) {
+	list := form.AsList()
+	head, err := g.emit(list[0], env, false)
+	if err != nil {
+		return "", err
+	}
+	name := g.next()
+	g.line("var %s rt.Value", name)
+	if list[0].IsSymbol() {
+		d, err := g.hoist(form)
+		if err != nil {
+			return "", err
+		}
+		g.line("if rt.IsMacro(%s) {", head)
+		g.line("%s = %s", name, g.assign(fmt.Sprintf("rt.Eval(%s, %s)", d, env)))
+		g.line("} else {")
+	}
+	args := make([]string, 0, len(list))
+	args = append(args, head)
+	for _, f := range list[1:] {
+		v, err := g.emit(f, env, false)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, v)
+	}
+	call := "rt.Call"
+	if tail {
+		call = "rt.TailCall"
+	}
+	g.line("%s = %s", name, g.assign(fmt.Sprintf("%s(%s, %s)", call, env, strings.Join(args, ", "))))
+	if list[0].IsSymbol() {
+		g.line("}")
+	}
+	return name, nil
+}